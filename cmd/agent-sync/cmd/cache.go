@@ -0,0 +1,433 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/cache"
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheExportOutput string
+	cacheLsProjects   bool
+	cacheGCMaxSize    string
+	cacheGCDryRun     bool
+	cacheServeAddr    string
+	cacheServeRO      bool
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the content-addressed cache",
+	Long: `Commands for inspecting and moving the content-addressed cache that backs
+sync and update.`,
+}
+
+var cacheExportCmd = &cobra.Command{
+	Use:   "export -o <bundle.tar>",
+	Short: "Pack the objects referenced by the lockfile into a bundle",
+	Long: `Writes a tar bundle containing exactly the cached objects referenced by the
+lockfile (selected with the global --lockfile flag), plus a manifest listing
+each object's hash and size. Use 'cache import' on another machine to seed
+its cache, for example before running 'sync --offline' in an air-gapped
+environment.
+
+Every referenced object must already be cached; run 'agent-sync sync' first
+if any are missing.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cacheExportOutput == "" {
+			return fmt.Errorf("--output is required")
+		}
+
+		lf, err := lock.Load(lockfilePath)
+		if err != nil {
+			return err
+		}
+
+		c, err := newCache()
+		if err != nil {
+			return err
+		}
+
+		// Build the bundle in memory so a failed export never leaves a partial file.
+		var buf bytes.Buffer
+		manifest, err := c.Export(&buf, lf.Hashes())
+		var missing *cache.MissingObjectsError
+		if errors.As(err, &missing) {
+			for _, line := range describeMissing(lf, missing.Hashes) {
+				errorf("%s", line)
+			}
+			return fmt.Errorf("%d object(s) referenced by %s are not cached — run 'agent-sync sync' first", len(missing.Hashes), lockfilePath)
+		}
+		if err != nil {
+			return err
+		}
+
+		if cacheExportOutput == "-" {
+			if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+				return fmt.Errorf("writing bundle: %w", err)
+			}
+			return nil
+		}
+		if err := os.WriteFile(cacheExportOutput, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("writing bundle: %w", err)
+		}
+
+		var total int64
+		for _, obj := range manifest.Objects {
+			total += obj.Size
+		}
+		info("Exported %d object(s) (%s) to %s", len(manifest.Objects), humanSize(total), cacheExportOutput)
+		return nil
+	},
+}
+
+var cacheImportCmd = &cobra.Command{
+	Use:   "import <bundle.tar>",
+	Short: "Verify and insert the objects from a bundle into the cache",
+	Long: `Reads a bundle produced by 'cache export'. Every object is checked against
+its SHA256 hash and the bundle manifest before anything is inserted; a
+corrupt or incomplete bundle leaves the cache untouched.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newCache()
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("opening bundle: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+
+		result, err := c.Import(r)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range result.Imported {
+			detail("imported sha256:%s", h)
+		}
+		info("Imported %d object(s), %d already cached.", len(result.Imported), result.Existing)
+		return nil
+	},
+}
+
+var cacheLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List cached objects",
+	Long: `Lists every cached object with its size, last-used time, and whether a
+registered lockfile references it. With --projects, lists the lockfiles
+registered in the cache index instead.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newCache()
+		if err != nil {
+			return err
+		}
+
+		if cacheLsProjects {
+			projects, err := c.Projects()
+			if err != nil {
+				return err
+			}
+			if len(projects) == 0 {
+				info("No lockfiles registered.")
+				return nil
+			}
+			fmt.Printf("%-20s %s\n", "LAST USED", "LOCKFILE")
+			for _, p := range projects {
+				fmt.Printf("%-20s %s\n", p.LastUsed.Local().Format("2006-01-02 15:04:05"), p.Lockfile)
+			}
+			return nil
+		}
+
+		projects, err := c.Projects()
+		if err != nil {
+			return err
+		}
+		keep, _, err := referencedHashes(projects)
+		if err != nil {
+			return err
+		}
+		objects, err := c.Objects()
+		if err != nil {
+			return err
+		}
+		if len(objects) == 0 {
+			info("Cache is empty.")
+			return nil
+		}
+
+		var total int64
+		fmt.Printf("%-64s %10s %-20s %s\n", "SHA256", "SIZE", "LAST USED", "REFERENCED")
+		for _, obj := range objects {
+			ref := "no"
+			if keep[obj.Hash] {
+				ref = "yes"
+			}
+			fmt.Printf("%-64s %10s %-20s %s\n", obj.Hash, humanSize(obj.Size), obj.ModTime.Local().Format("2006-01-02 15:04:05"), ref)
+			total += obj.Size
+		}
+		info("\n%d object(s), %s", len(objects), humanSize(total))
+		return nil
+	},
+}
+
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Rehash every cached object and remove corrupt entries",
+	Long: `Recomputes the SHA256 hash of every cached object. Entries whose content no
+longer matches their hash are removed so they are fetched again on next use.
+Exits non-zero if any corrupt entries were found.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newCache()
+		if err != nil {
+			return err
+		}
+
+		report, err := c.Verify()
+		if err != nil {
+			return err
+		}
+		for _, h := range report.Corrupt {
+			info("  removed corrupt  sha256:%s", h)
+		}
+		if len(report.Corrupt) > 0 {
+			return fmt.Errorf("%d of %d object(s) were corrupt and have been removed", len(report.Corrupt), report.Checked)
+		}
+		info("All %d object(s) verified.", report.Checked)
+		return nil
+	},
+}
+
+var cacheGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Evict cached objects not referenced by any registered lockfile",
+	Long: `Keeps every object referenced by a lockfile registered in the cache index
+(projects register their lockfile on sync and update) and evicts the rest,
+least recently used first.
+
+With a size cap (--max-size, or cache.max_size in config), eviction stops
+once the cache fits within the cap. Without one, every unreferenced object is
+evicted. Registered lockfiles that no longer exist are dropped from the index.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newCache()
+		if err != nil {
+			return err
+		}
+
+		maxSize, err := cacheMaxSize(cacheGCMaxSize)
+		if err != nil {
+			return err
+		}
+
+		result, err := c.GC(referencedHashes, maxSize, cacheGCDryRun)
+		if err != nil {
+			return err
+		}
+
+		if cacheGCDryRun {
+			info("Dry run — no objects removed.")
+		}
+
+		for _, obj := range result.Removed {
+			detail("evicted sha256:%s (%s)", obj.Hash, humanSize(obj.Size))
+		}
+		for _, p := range result.Forgotten {
+			detail("forgot missing lockfile %s", p)
+		}
+		info("Evicted %d object(s), freed %s; cache now %s.",
+			len(result.Removed), humanSize(result.Freed), humanSize(result.Remaining))
+		if maxSize > 0 && result.Remaining > maxSize {
+			info("Referenced objects alone exceed the %s cap.", humanSize(maxSize))
+		}
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached object",
+	Long: `Deletes all cached objects. Content is fetched again from sources (or the
+vendor directory) on next use. The index of registered lockfiles is kept.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newCache()
+		if err != nil {
+			return err
+		}
+
+		size, _ := c.Size()
+		if err := c.Clear(); err != nil {
+			return err
+		}
+		info("Cleared %s from %s.", humanSize(size), c.Path())
+		return nil
+	},
+}
+
+var cacheServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the cache over HTTP as a shared remote cache",
+	Long: `Serves the local cache so that other machines can use it as their
+cache.remote_cache. Objects are exchanged with GET and PUT on
+/objects/<sha256>; uploads are rejected unless their content matches the hash.
+
+Clients read through to the remote on a local miss and write newly cached
+objects back to it. Use --read-only to serve a cache that was populated
+another way (for example with 'cache import').`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newCache()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		ln, err := net.Listen("tcp", cacheServeAddr)
+		if err != nil {
+			return fmt.Errorf("listening on %s: %w", cacheServeAddr, err)
+		}
+		srv := &http.Server{
+			Handler:           &cache.Server{Cache: c, ReadOnly: cacheServeRO},
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		mode := "read-write"
+		if cacheServeRO {
+			mode = "read-only"
+		}
+		info("Serving %s (%s) on http://%s", c.Path(), mode, ln.Addr())
+
+		errCh := make(chan error, 1)
+		go func() { errCh <- srv.Serve(ln) }()
+
+		select {
+		case err := <-errCh:
+			return fmt.Errorf("cache server: %w", err)
+		case <-ctx.Done():
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("stopping cache server: %w", err)
+		}
+		return nil
+	},
+}
+
+// referencedHashes returns the set of objects referenced by every registered
+// lockfile (plus the current project's, if present), and the registered
+// lockfiles that no longer exist. It is a cache.Referenced.
+func referencedHashes(projects []cache.Project) (map[string]bool, []string, error) {
+	paths := make([]string, 0, len(projects)+1)
+	for _, p := range projects {
+		paths = append(paths, p.Lockfile)
+	}
+	paths = append(paths, lockfilePath)
+
+	keep := make(map[string]bool)
+	var stale []string
+	for i, path := range paths {
+		lf, err := lock.Load(path)
+		if errors.Is(err, os.ErrNotExist) {
+			if i < len(projects) {
+				stale = append(stale, path)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("registered lockfile %s: %w", path, err)
+		}
+		for _, h := range lf.Hashes() {
+			keep[h] = true
+		}
+	}
+	return keep, stale, nil
+}
+
+// cacheMaxSize returns the cache size cap from flagValue, or from config
+// when the flag is empty. Returns 0 if no cap is configured.
+func cacheMaxSize(flagValue string) (int64, error) {
+	value := flagValue
+	if value == "" {
+		// Cache commands also run outside projects; config is optional here.
+		if cfg, err := loadConfig(); err == nil {
+			value = cfg.Cache.MaxSize
+		} else {
+			detail("config: %s (ignored)", err)
+		}
+	}
+	if value == "" {
+		return 0, nil
+	}
+	size, err := config.ParseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("max size: %w", err)
+	}
+	return size, nil
+}
+
+// describeMissing maps missing hashes back to the locked files that reference them.
+func describeMissing(lf *lock.Lockfile, hashes []string) []string {
+	missing := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		missing[h] = true
+	}
+
+	var lines []string
+	for _, ls := range lf.Sources {
+		for relPath, fh := range ls.Resolved.Files {
+			if missing[fh.SHA256] {
+				lines = append(lines, fmt.Sprintf("not in cache: %s/%s sha256:%s", ls.Name, relPath, fh.SHA256))
+			}
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func init() {
+	cacheExportCmd.Flags().StringVarP(&cacheExportOutput, "output", "o", "", "bundle file to write ('-' for stdout)")
+
+	cacheLsCmd.Flags().BoolVar(&cacheLsProjects, "projects", false, "list registered lockfiles instead of objects")
+	cacheGCCmd.Flags().StringVar(&cacheGCMaxSize, "max-size", "", "evict down to this size (e.g. 500MB); overrides cache.max_size")
+	cacheGCCmd.Flags().BoolVar(&cacheGCDryRun, "dry-run", false, "show what would be evicted without removing anything")
+
+	cacheServeCmd.Flags().StringVar(&cacheServeAddr, "addr", "127.0.0.1:8080", "address to listen on (use ':8080' to accept remote connections)")
+	cacheServeCmd.Flags().BoolVar(&cacheServeRO, "read-only", false, "reject uploads")
+
+	cacheCmd.AddCommand(cacheLsCmd)
+	cacheCmd.AddCommand(cacheVerifyCmd)
+	cacheCmd.AddCommand(cacheGCCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheExportCmd)
+	cacheCmd.AddCommand(cacheImportCmd)
+	cacheCmd.AddCommand(cacheServeCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/bianoble/agent-sync/internal/lock"
+)
+
+func TestDescribeMissing(t *testing.T) {
+	lf := &lock.Lockfile{
+		Version: 1,
+		Sources: []lock.LockedSource{
+			{Name: "rules", Resolved: lock.ResolvedState{Files: map[string]lock.FileHash{
+				"b.md": {SHA256: "bbb"},
+				"a.md": {SHA256: "aaa"},
+			}}},
+		},
+	}
+
+	got := describeMissing(lf, []string{"bbb"})
+	if len(got) != 1 || got[0] != "not in cache: rules/b.md sha256:bbb" {
+		t.Errorf("describeMissing = %v", got)
+	}
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bianoble/agent-sync/internal/changelog"
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/source"
+	"github.com/spf13/cobra"
+)
+
+var (
+	changelogFrom   string
+	changelogTo     string
+	changelogFormat string
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog [source-name...]",
+	Short: "Show the upstream commits between locked and latest versions of git sources",
+	Long: `Lists the commits (git log A..B) that touch each git source's paths, grouped
+by Conventional Commit type (feat, fix, ...) where commit subjects follow it.
+
+By default A is the commit in the lockfile and B is the source's ref, so the
+changelog shows what 'agent-sync update' would bring in. --from and --to
+override either end with any commit, tag, or branch. No checkout of the
+source is needed: agent-sync clones the history itself.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if changelogFormat != "markdown" && changelogFormat != "json" {
+			return fmt.Errorf("unknown format %q (want markdown or json)", changelogFormat)
+		}
+		cfg, err := loadLockConfig()
+		if err != nil {
+			return err
+		}
+		lf, err := loadLockfile()
+		if err != nil {
+			return err
+		}
+
+		var sources []config.Source
+		if len(args) == 0 {
+			for _, s := range cfg.Sources {
+				if s.Type == "git" {
+					sources = append(sources, s)
+				}
+			}
+		} else {
+			byName := make(map[string]config.Source)
+			for _, s := range cfg.Sources {
+				byName[s.Name] = s
+			}
+			for _, name := range args {
+				s, ok := byName[name]
+				if !ok {
+					return fmt.Errorf("source '%s' not found in config", name)
+				}
+				if s.Type != "git" {
+					return fmt.Errorf("source '%s' is a %s source; changelogs need a git source", name, s.Type)
+				}
+				sources = append(sources, s)
+			}
+		}
+
+		reg := newRegistry()
+		var logs []*changelog.Changelog
+		var failed int
+		for _, src := range sources {
+			from, to := changelogFrom, changelogTo
+			if from == "" {
+				ls := lf.Source(src.Name)
+				if ls == nil || ls.Resolved.Commit == "" {
+					errorf("%s: not locked — run 'agent-sync update %s' first or pass --from", src.Name, src.Name)
+					failed++
+					continue
+				}
+				from = ls.Resolved.Commit
+			}
+			if to == "" {
+				to = src.Ref
+			}
+			log, err := sourceChangelog(cmd.Context(), reg, src, from, to)
+			if err != nil {
+				errorf("%s", err)
+				failed++
+				continue
+			}
+			logs = append(logs, log)
+		}
+
+		if changelogFormat == "json" {
+			if logs == nil {
+				logs = []*changelog.Changelog{}
+			}
+			out, err := json.MarshalIndent(logs, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(os.Stdout, string(out))
+		} else {
+			for i, log := range logs {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Print(log.Markdown())
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d source(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+// sourceChangelog builds the changelog of a git source between two
+// revisions.
+func sourceChangelog(ctx context.Context, reg *source.Registry, src config.Source, from, to string) (*changelog.Changelog, error) {
+	resolver, err := reg.Get(src.Type)
+	if err != nil {
+		return nil, err
+	}
+	history, ok := resolver.(source.HistoryResolver)
+	if !ok {
+		return nil, fmt.Errorf("%s: changelogs are only available for git sources", src.Name)
+	}
+	commits, err := history.Log(ctx, src, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return changelog.Build(src.Name, from, to, commits), nil
+}
+
+func init() {
+	changelogCmd.Flags().StringVar(&changelogFrom, "from", "", "start revision (default: the locked commit)")
+	changelogCmd.Flags().StringVar(&changelogTo, "to", "", "end revision (default: the source's ref)")
+	changelogCmd.Flags().StringVar(&changelogFormat, "format", "markdown", "output format: markdown or json")
+	rootCmd.AddCommand(changelogCmd)
+}
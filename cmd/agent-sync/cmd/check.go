@@ -23,6 +23,9 @@ Exit 0 if everything matches; exit non-zero on drift. Suitable for CI pipelines.
 		if err != nil {
 			return err
 		}
+		if err := enforceSigning(cfg, lf); err != nil {
+			return err
+		}
 
 		root, err := projectRoot()
 		if err != nil {
@@ -59,5 +62,6 @@ Exit 0 if everything matches; exit non-zero on drift. Suitable for CI pipelines.
 }
 
 func init() {
+	addWorkspaceFlag(checkCmd)
 	rootCmd.AddCommand(checkCmd)
 }
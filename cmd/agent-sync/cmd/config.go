@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configSchemaOutput string
+	configShowResolved bool
+	configShowOrigin   bool
+	configShowFormat   string
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the agent-sync.yaml configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective config",
+	Long: `Prints the config as commands see it: the system, user, and project layers
+merged, includes expanded, entries removed by 'remove' dropped, and the
+active profile (--profile or AGENT_SYNC_PROFILE) applied, leaving out
+disabled sources and targets.
+
+By default ${...} references and variable providers are shown as written.
+--resolved shows the values commands use instead; values read by variable
+providers, or from environment variables by ${...} references, are replaced
+with <redacted> wherever they appear.
+
+--origin annotates every source, target, override, transform, tool
+definition, variable, setting, and profile with the file, line, and layer
+that supplied it, or that replaced a lower layer's entry of the same name.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := loadConfigHierarchical()
+		if err != nil {
+			return err
+		}
+		cfg := result.Config
+		if !configShowResolved {
+			opts := hierarchicalOptions()
+			opts.Written = true
+			written, err := config.LoadHierarchical(opts)
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+			cfg = written.Config
+		}
+
+		view, err := applyProfile(cfg)
+		if err != nil {
+			return err
+		}
+		if activeProfile() != "" {
+			view.Profiles = nil // already applied
+		}
+
+		data, err := view.Render(config.RenderOptions{
+			Layers:  result.Layers,
+			Format:  configShowFormat,
+			Origins: configShowOrigin,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema for agent-sync.yaml",
+	Long: `Prints a JSON Schema describing agent-sync.yaml, for editor completion and
+validation. The same schema is published at:
+
+  ` + config.JSONSchemaURL + `
+
+Editors using the YAML language server pick it up from a comment at the top
+of the config file:
+
+  # yaml-language-server: $schema=` + config.JSONSchemaURL,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := config.JSONSchema()
+		if err != nil {
+			return err
+		}
+		if configSchemaOutput == "" || configSchemaOutput == "-" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(configSchemaOutput, data, 0644); err != nil {
+			return fmt.Errorf("writing schema: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	configSchemaCmd.Flags().StringVarP(&configSchemaOutput, "output", "o", "", "file to write instead of stdout")
+	configShowCmd.Flags().BoolVar(&configShowResolved, "resolved", false, "show resolved variable values instead of references and providers")
+	configShowCmd.Flags().BoolVar(&configShowOrigin, "origin", false, "annotate each entry with the file and layer that supplied it")
+	configShowCmd.Flags().StringVar(&configShowFormat, "format", "yaml", "output format: yaml or json")
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configSchemaCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bianoble/agent-sync/internal/cache"
+	"github.com/bianoble/agent-sync/internal/engine"
+	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/textdiff"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffContent bool
+	diffTargets bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [old.lock] [new.lock]",
+	Short: "Show changes between lockfiles, or what sync would change",
+	Long: `Compares two lockfiles source by source, listing each source's version change
+and the files added (+), removed (-), and modified (~). With no arguments the
+committed lockfile (git show HEAD:<lockfile>) is compared with the working
+copy, every source showing as added if it is not committed yet; with one
+argument that file is compared with the working copy.
+
+--content also prints unified diffs of the file contents, read from the
+vendor directory and the cache.
+
+--targets instead shows, line by line, what 'agent-sync sync' would change
+in the target files on disk.`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffTargets {
+			if len(args) > 0 {
+				return fmt.Errorf("--targets takes no lockfile arguments")
+			}
+			return diffPendingTargets(cmd)
+		}
+
+		var older, newer *lock.Lockfile
+		var err error
+		if len(args) == 0 {
+			older, err = lockfileAtHead()
+		} else {
+			older, err = lock.Load(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		if len(args) == 2 {
+			newer, err = lock.Load(args[1])
+		} else {
+			newer, err = lock.Load(lockfilePath)
+		}
+		if err != nil {
+			return err
+		}
+
+		diffs := lock.Diff(older, newer)
+		if len(diffs) == 0 {
+			info("No differences.")
+			return nil
+		}
+
+		var stores []*cache.Cache
+		if diffContent {
+			stores, err = diffStores()
+			if err != nil {
+				return err
+			}
+		}
+
+		for i, d := range diffs {
+			if i > 0 {
+				fmt.Println()
+			}
+			printSourceDiff(d)
+			if diffContent {
+				printContentDiffs(d, stores)
+			}
+		}
+		return nil
+	},
+}
+
+// lockfileAtHead reads the committed version of the lockfile. A lockfile
+// not committed yet, or in a repository without commits, reads as empty, so
+// that every source shows as added.
+func lockfileAtHead() (*lock.Lockfile, error) {
+	abs, err := filepath.Abs(lockfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("resolving lockfile path: %w", err)
+	}
+	dir, rev := filepath.Dir(abs), "HEAD:./"+filepath.Base(abs)
+	out, err := exec.Command("git", "-C", dir, "show", rev).Output()
+	if err != nil {
+		// rev-parse exits 1, rather than 128, only when the repository
+		// is fine but has no such object.
+		var exitErr *exec.ExitError
+		if verr := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", rev).Run(); errors.As(verr, &exitErr) && exitErr.ExitCode() == 1 {
+			return &lock.Lockfile{Version: lock.CurrentVersion}, nil
+		}
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("git show %s: %s", rev, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git show %s: %w", rev, err)
+	}
+	return lock.Parse("HEAD:"+lockfilePath, out)
+}
+
+func printSourceDiff(d lock.SourceDiff) {
+	switch {
+	case d.Before == nil:
+		fmt.Printf("%s (%s): added at %s\n", d.Name, d.After.Type, summarizeLockedSource(d.After))
+	case d.After == nil:
+		fmt.Printf("%s (%s): removed (was %s)\n", d.Name, d.Before.Type, summarizeLockedSource(d.Before))
+	default:
+		fmt.Printf("%s (%s): %s → %s\n", d.Name, d.After.Type, summarizeLockedSource(d.Before), summarizeLockedSource(d.After))
+	}
+	for _, p := range d.Added {
+		fmt.Printf("  + %s\n", p)
+	}
+	for _, p := range d.Removed {
+		fmt.Printf("  - %s\n", p)
+	}
+	for _, p := range d.Modified {
+		fmt.Printf("  ~ %s\n", p)
+	}
+}
+
+// printContentDiffs prints a unified diff for every changed file of a
+// source, in path order.
+func printContentDiffs(d lock.SourceDiff, stores []*cache.Cache) {
+	var paths []string
+	paths = append(paths, d.Added...)
+	paths = append(paths, d.Removed...)
+	paths = append(paths, d.Modified...)
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		before, oldLabel, err := fileContent(d.Before, p, "a/"+d.Name+"/"+p, stores)
+		if err == nil {
+			var after []byte
+			var newLabel string
+			after, newLabel, err = fileContent(d.After, p, "b/"+d.Name+"/"+p, stores)
+			if err == nil {
+				fmt.Print("\n" + textdiff.Unified(oldLabel, newLabel, before, after))
+				continue
+			}
+		}
+		fmt.Printf("\n(%s/%s: %s)\n", d.Name, p, err)
+	}
+}
+
+// fileContent returns the content and diff label of one side of a changed
+// file. A side without the file is labelled /dev/null.
+func fileContent(ls *lock.LockedSource, path, label string, stores []*cache.Cache) ([]byte, string, error) {
+	if ls == nil {
+		return nil, textdiff.DevNull, nil
+	}
+	fh, ok := ls.Resolved.Files[path]
+	if !ok {
+		return nil, textdiff.DevNull, nil
+	}
+	for _, s := range stores {
+		if content, found, err := s.Get(fh.SHA256); err == nil && found {
+			return content, label, nil
+		}
+	}
+	return nil, "", fmt.Errorf("content not in cache: sha256:%s", fh.SHA256)
+}
+
+// diffStores returns the stores file content is read from: the vendor
+// directory, if any, then the cache.
+func diffStores() ([]*cache.Cache, error) {
+	root, err := projectRoot()
+	if err != nil {
+		return nil, err
+	}
+	vendorStore, err := openVendor(root)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newCache()
+	if err != nil {
+		return nil, err
+	}
+	if vendorStore != nil {
+		return []*cache.Cache{vendorStore, c}, nil
+	}
+	return []*cache.Cache{c}, nil
+}
+
+// diffPendingTargets prints what sync would change in the target files.
+func diffPendingTargets(cmd *cobra.Command) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	lf, err := loadLockfile()
+	if err != nil {
+		return err
+	}
+	root, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	c, err := newProjectCache(cfg)
+	if err != nil {
+		return err
+	}
+	vendorStore, err := openVendor(root)
+	if err != nil {
+		return err
+	}
+
+	eng := &engine.SyncEngine{
+		Registry:    newRegistry(),
+		Cache:       c,
+		Vendor:      vendorStore,
+		ToolMap:     newToolMap(cfg),
+		ProjectRoot: root,
+	}
+	result, err := eng.Preview(cmd.Context(), *lf, *cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range result.Changes {
+		path := filepath.ToSlash(ch.Path)
+		oldLabel := "a/" + path
+		if !ch.Existed {
+			oldLabel = textdiff.DevNull
+		}
+		fmt.Print(textdiff.Unified(oldLabel, "b/"+path, ch.Before, ch.After))
+	}
+	for _, e := range result.Errors {
+		errorf("%s: %s", e.Source, e.Err)
+	}
+
+	if len(result.Changes) == 0 {
+		info("Targets are up to date.")
+	} else {
+		info("\n%d file(s) would change.", len(result.Changes))
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d source(s) failed", len(result.Errors))
+	}
+	return nil
+}
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffContent, "content", false, "show unified diffs of changed file contents from the cache")
+	diffCmd.Flags().BoolVar(&diffTargets, "targets", false, "show what sync would change in target files")
+	rootCmd.AddCommand(diffCmd)
+}
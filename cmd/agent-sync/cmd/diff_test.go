@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockfileAtHeadUncommitted(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+
+	saved := lockfilePath
+	t.Cleanup(func() { lockfilePath = saved })
+	lockfilePath = filepath.Join(dir, "agent-sync.lock")
+	if err := os.WriteFile(lockfilePath, []byte("version: 1\nsources: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// No commits yet, then a commit without the lockfile.
+	for _, step := range []func(){func() {}, func() { git("commit", "-q", "--allow-empty", "-m", "init") }} {
+		step()
+		lf, err := lockfileAtHead()
+		if err != nil {
+			t.Fatalf("lockfileAtHead: %v", err)
+		}
+		if len(lf.Sources) != 0 {
+			t.Errorf("sources = %v, want an empty lockfile", lf.Sources)
+		}
+	}
+
+	lockfilePath = filepath.Join(t.TempDir(), "agent-sync.lock")
+	if _, err := lockfileAtHead(); err == nil {
+		t.Error("expected an error outside a git repository")
+	}
+}
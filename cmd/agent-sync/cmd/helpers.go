@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -8,38 +9,82 @@ import (
 
 	"github.com/bianoble/agent-sync/internal/cache"
 	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/engine"
 	"github.com/bianoble/agent-sync/internal/lock"
 	"github.com/bianoble/agent-sync/internal/source"
 	"github.com/bianoble/agent-sync/internal/target"
+	"github.com/bianoble/agent-sync/internal/vendored"
 )
 
 // loadConfig reads and validates the config file using hierarchical resolution.
 // System and user configs are merged below the project config unless --no-inherit
-// is set or AGENT_SYNC_NO_INHERIT is enabled.
+// is set or AGENT_SYNC_NO_INHERIT is enabled. The profile selected with
+// --profile or AGENT_SYNC_PROFILE is applied.
 func loadConfig() (*config.Config, error) {
 	result, err := loadConfigHierarchical()
 	if err != nil {
 		return nil, err
 	}
-	return result.Config, nil
+	return applyProfile(result.Config)
 }
 
-// loadConfigHierarchical returns both the merged config and layer metadata.
-func loadConfigHierarchical() (*config.HierarchicalResult, error) {
-	inherit := !noInherit && !config.EnvNoInherit()
+// loadLockConfig loads the config for commands that write the lockfile: it
+// holds the sources of every profile, so that the lockfile serves them all
+// and switching profiles never needs an update.
+func loadLockConfig() (*config.Config, error) {
+	result, err := loadConfigHierarchical()
+	if err != nil {
+		return nil, err
+	}
+	return result.Config.Union(), nil
+}
 
-	opts := config.HierarchicalOptions{
+// activeProfile returns the profile selected with --profile or
+// AGENT_SYNC_PROFILE, or "" for none.
+func activeProfile() string {
+	if profile != "" {
+		return profile
+	}
+	return config.EnvProfile()
+}
+
+// applyProfile returns cfg as seen by the active profile.
+func applyProfile(cfg *config.Config) (*config.Config, error) {
+	name := activeProfile()
+	view, err := cfg.WithProfile(name)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if name != "" {
+		detail("config: using profile %s", name)
+	}
+	return view, nil
+}
+
+// hierarchicalOptions returns the config loading options selected by the
+// global flags and environment.
+func hierarchicalOptions() config.HierarchicalOptions {
+	return config.HierarchicalOptions{
 		ProjectPath:      configPath,
 		SystemConfigPath: os.Getenv("AGENT_SYNC_SYSTEM_CONFIG"),
 		UserConfigPath:   os.Getenv("AGENT_SYNC_USER_CONFIG"),
-		NoInherit:        !inherit,
+		NoInherit:        noInherit || config.EnvNoInherit(),
+		AllowCommands:    allowCommands || config.EnvAllowCommands(),
 	}
+}
+
+// loadConfigHierarchical returns both the merged config and layer metadata.
+func loadConfigHierarchical() (*config.HierarchicalResult, error) {
+	opts := hierarchicalOptions()
+	inherit := !opts.NoInherit
 
 	result, err := config.LoadHierarchical(opts)
 	if err != nil {
 		return nil, fmt.Errorf("loading config: %w", err)
 	}
 
+	warnMigrations(result.Config.Migrations())
+
 	if verbose && inherit {
 		for _, l := range result.Layers {
 			if l.Loaded {
@@ -71,6 +116,7 @@ func loadLockfile() (*lock.Lockfile, error) {
 	if err != nil {
 		return nil, fmt.Errorf("loading lockfile %s: %w", lockfilePath, err)
 	}
+	warnMigrations(lf.Migrations())
 	return lf, nil
 }
 
@@ -88,25 +134,209 @@ func projectRoot() (string, error) {
 	return filepath.Dir(abs), nil
 }
 
+// lockProject takes the project operation lock for a mutating command,
+// honoring --wait, and rolls forward any operation that a previous run left
+// unfinished. The returned function releases the lock.
+func lockProject(ctx context.Context, operation string) (func(), error) {
+	unlock, err := acquireProjectLock(ctx, operation)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := recoverInterrupted(ctx, engine.RecoverOptions{}); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// editConfig applies edit to the project config under the project lock and
+// writes the result, keeping comments and key order, if the config still
+// loads and validates with the other layers merged in.
+func editConfig(ctx context.Context, operation string, edit func(*config.Editor) error) error {
+	unlock, err := lockProject(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e, err := config.EditFile(configPath)
+	if err != nil {
+		return err
+	}
+	if err := edit(e); err != nil {
+		return err
+	}
+	data, err := e.Bytes()
+	if err != nil {
+		return err
+	}
+	opts := hierarchicalOptions()
+	opts.ProjectData = data
+	if _, err := config.LoadHierarchical(opts); err != nil {
+		return fmt.Errorf("not writing %s, the change would make the config invalid: %w", configPath, err)
+	}
+	return writeFilePreservingMode(configPath, data)
+}
+
+// acquireProjectLock takes the project operation lock, honoring --wait.
+// The returned function releases it.
+func acquireProjectLock(ctx context.Context, operation string) (func(), error) {
+	root, err := projectRoot()
+	if err != nil {
+		return nil, err
+	}
+	l, err := engine.LockProject(ctx, root, operation, lockWait)
+	var locked *engine.ProjectLockedError
+	if errors.As(err, &locked) {
+		if lockWait > 0 {
+			return nil, fmt.Errorf("%w (gave up after %s)", err, lockWait)
+		}
+		return nil, fmt.Errorf("%w — wait for it to finish or pass --wait 30s", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := l.Unlock(); err != nil {
+			detail("%s", err)
+		}
+	}, nil
+}
+
+// recoverInterrupted finishes or undoes an operation left unfinished by a
+// crash, reporting what it did. The caller must hold the project lock.
+func recoverInterrupted(ctx context.Context, opts engine.RecoverOptions) (*engine.RecoverResult, error) {
+	root, err := projectRoot()
+	if err != nil {
+		return nil, err
+	}
+	eng := &engine.RecoverEngine{ProjectRoot: root}
+	result, err := eng.Recover(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("recovering interrupted operation: %w", err)
+	}
+	if result == nil {
+		return nil, nil
+	}
+	if result.Discarded {
+		info("Discarded the journal of the interrupted %s from %s; files left as they are.",
+			result.Operation, result.Started.Local().Format("2006-01-02 15:04:05"))
+		return result, nil
+	}
+
+	verb := "Completed"
+	if result.RolledBack {
+		verb = "Rolled back"
+	}
+	info("%s interrupted %s from %s: %d file(s) restored, %d already in place.",
+		verb, result.Operation, result.Started.Local().Format("2006-01-02 15:04:05"), len(result.Applied), result.Unchanged)
+	for _, p := range result.Applied {
+		detail("  restored  %s", p)
+	}
+	for _, p := range result.Conflicts {
+		errorf("%s changed since the interruption; left as is", p)
+	}
+	if len(result.Conflicts) > 0 {
+		return result, fmt.Errorf("%d file(s) were changed since the interruption and need attention — restore them and run 'agent-sync recover' again, or keep them with 'agent-sync recover --discard'", len(result.Conflicts))
+	}
+	return result, nil
+}
+
 // newToolMap creates a ToolMap from the config's custom definitions.
 func newToolMap(cfg *config.Config) *target.ToolMap {
 	return target.NewToolMap(cfg.ToolDefinitions)
 }
 
 // newRegistry creates a source registry with all built-in resolvers.
+// In offline mode the network-backed resolvers are replaced so that any
+// clone or HTTP request fails immediately. While a command runs across a
+// workspace, every member gets the same shared registry.
 func newRegistry() *source.Registry {
+	if workspaceRegistry != nil {
+		return workspaceRegistry
+	}
 	reg := source.NewRegistry()
-	reg.Register("git", &source.GitResolver{})
-	reg.Register("url", &source.URLResolver{})
+	if offlineMode() {
+		reg.Register("git", source.OfflineResolver{})
+		reg.Register("url", source.OfflineResolver{})
+	} else {
+		reg.Register("git", &source.GitResolver{})
+		reg.Register("url", &source.URLResolver{})
+	}
 	reg.Register("local", &source.LocalResolver{})
 	return reg
 }
 
+// offlineMode reports whether network access is forbidden, via --offline
+// or AGENT_SYNC_OFFLINE.
+func offlineMode() bool {
+	return offline || config.EnvOffline()
+}
+
 // newCache creates or opens the content-addressed cache.
 func newCache() (*cache.Cache, error) {
 	return cache.New(cache.DefaultDir())
 }
 
+// newProjectCache opens the cache and attaches the configured remote cache,
+// unless offline mode forbids network access.
+func newProjectCache(cfg *config.Config) (*cache.Cache, error) {
+	c, err := newCache()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Cache.RemoteCache == "" || offlineMode() {
+		return c, nil
+	}
+	remote, err := cache.NewRemote(cfg.Cache.RemoteCache)
+	if err != nil {
+		return nil, err
+	}
+	remote.OnError = func(err error) { detail("%s", err) }
+	c.SetRemote(remote)
+	detail("cache: using remote %s", remote.URL())
+	return c, nil
+}
+
+// recordCacheUse registers the project lockfile in the cache index and
+// enforces the configured cache size cap. Failures are reported in verbose
+// mode but never fail the command.
+func recordCacheUse(c *cache.Cache, cfg *config.Config) {
+	if err := c.RegisterLockfile(lockfilePath); err != nil {
+		detail("cache: %s", err)
+		return
+	}
+	if cfg.Cache.MaxSize == "" {
+		return
+	}
+	maxSize, err := config.ParseSize(cfg.Cache.MaxSize)
+	if err != nil {
+		return // reported by config validation
+	}
+	size, err := c.Size()
+	if err != nil || size <= maxSize {
+		return
+	}
+	result, err := c.GC(referencedHashes, maxSize, false)
+	if err != nil {
+		detail("cache: %s", err)
+		return
+	}
+	detail("cache: evicted %d object(s) to stay under %s", len(result.Removed), cfg.Cache.MaxSize)
+}
+
+// openVendor returns the project's vendor store, or nil if it has none.
+func openVendor(root string) (*cache.Cache, error) {
+	store, err := vendored.Open(root)
+	if err != nil {
+		return nil, err
+	}
+	if store != nil {
+		detail("vendor: using %s", store.Path())
+	}
+	return store, nil
+}
+
 // info prints a line unless quiet mode is active.
 func info(format string, args ...any) {
 	if !quiet {
@@ -121,6 +351,18 @@ func detail(format string, args ...any) {
 	}
 }
 
+// warnMigrations reports schema migrations applied in memory while loading.
+func warnMigrations(notes []string) {
+	for _, n := range notes {
+		warnf("%s (run 'agent-sync migrate' to rewrite it)", n)
+	}
+}
+
+// warnf prints a warning to stderr.
+func warnf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+}
+
 // errorf prints an error message to stderr.
 func errorf(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/bianoble/agent-sync/internal/config"
 	"github.com/bianoble/agent-sync/internal/engine"
@@ -61,6 +62,13 @@ cache directory and size, and known tool definitions (built-in and custom).`,
 			fmt.Printf("  config:        %s\n", result.ConfigPath)
 		}
 
+		if cfg != nil && len(cfg.Profiles) > 0 {
+			active := activeProfile()
+			if active == "" {
+				active = "none"
+			}
+			fmt.Printf("  profiles:      %s (active: %s)\n", strings.Join(cfg.ProfileNames(), ", "), active)
+		}
 		fmt.Printf("  lockfile:      %s\n", result.LockPath)
 		fmt.Printf("  cache dir:     %s\n", result.CacheDir)
 		fmt.Printf("  cache size:    %s\n", humanSize(result.CacheSize))
@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-var initForce bool
+var (
+	initForce       bool
+	initMergeDriver bool
+)
 
 // initTemplate is the default agent-sync.yaml scaffold.
 // It includes a working git source example and commented-out alternatives.
@@ -71,7 +77,10 @@ var initCmd = &cobra.Command{
 template including a git source example and documented alternatives for URL and
 local sources.
 
-Use --force to overwrite an existing configuration file.`,
+Use --force to overwrite an existing configuration file.
+
+Inside a git repository, init offers to register the lockfile merge driver
+(see 'agent-sync lock merge'); --merge-driver registers it without asking.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		outPath := configPath
 		if !filepath.IsAbs(outPath) {
@@ -93,6 +102,13 @@ Use --force to overwrite an existing configuration file.`,
 		}
 
 		info("Created %s", outPath)
+
+		if initMergeDriver || offerMergeDriver(filepath.Dir(outPath)) {
+			if err := setupMergeDriver(); err != nil {
+				errorf("registering merge driver: %s", err)
+			}
+		}
+
 		info("")
 		info("Next steps:")
 		info("  1. Edit the file to point at your sources")
@@ -102,7 +118,27 @@ Use --force to overwrite an existing configuration file.`,
 	},
 }
 
+// offerMergeDriver asks whether to register the lockfile merge driver. It
+// only asks when dir is in a git repository and stdin is a terminal.
+func offerMergeDriver(dir string) bool {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if err := exec.Command("git", "-C", dir, "rev-parse", "--git-dir").Run(); err != nil {
+		return false
+	}
+
+	fmt.Print("Register a git merge driver so lockfile changes on different branches merge cleanly? [Y/n] ")
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		return false
+	}
+	answer := strings.TrimSpace(strings.ToLower(scanner.Text()))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
 func init() {
 	initCmd.Flags().BoolVar(&initForce, "force", false, "overwrite existing config file")
+	initCmd.Flags().BoolVar(&initMergeDriver, "merge-driver", false, "register the lockfile merge driver without asking")
 	rootCmd.AddCommand(initCmd)
 }
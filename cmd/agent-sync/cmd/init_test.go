@@ -91,3 +91,28 @@ func TestInitTemplateIsValidYAML(t *testing.T) {
 		t.Error("template should contain 'version'")
 	}
 }
+
+func TestAddGitAttributeIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gitattributes")
+	if err := os.WriteFile(path, []byte("*.png binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []bool{true, false} {
+		added, err := addGitAttribute(path, "/agent-sync.lock", "merge=agent-sync")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != want {
+			t.Errorf("call %d: added = %v, want %v", i, added, want)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "*.png binary\n/agent-sync.lock merge=agent-sync\n" {
+		t.Errorf(".gitattributes = %q", got)
+	}
+}
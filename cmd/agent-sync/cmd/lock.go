@@ -0,0 +1,375 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/signing"
+	"github.com/spf13/cobra"
+)
+
+// envSigningKey names the private key used by 'lock sign' when --key is not given.
+const envSigningKey = "AGENT_SYNC_SIGNING_KEY"
+
+var (
+	lockSignKey    string
+	lockSignSigner string
+	lockKeygenOut  string
+	lockKeygenName string
+	lockMergeSetup bool
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Sign, verify, and merge the lockfile",
+	Long:  `Commands that operate on the lockfile itself rather than on synced files.`,
+}
+
+var lockSignCmd = &cobra.Command{
+	Use:   "sign --key <private-key>",
+	Short: "Sign the lockfile with an ed25519 key",
+	Long: `Writes a detached ed25519 signature over the canonical lockfile content to
+<lockfile>` + signing.SignatureSuffix + `. Commit the signature file next to the lockfile.
+
+The key may be a PKCS#8 PEM key (see 'lock keygen') or an unencrypted
+OpenSSH ed25519 key. If --key is not given, ` + envSigningKey + ` is used.
+Signing replaces any earlier signature by the same key and drops signatures
+that no longer match the lockfile.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyPath := lockSignKey
+		if keyPath == "" {
+			keyPath = os.Getenv(envSigningKey)
+		}
+		if keyPath == "" {
+			return fmt.Errorf("--key is required (or set %s)", envSigningKey)
+		}
+
+		keyData, err := os.ReadFile(keyPath)
+		if err != nil {
+			return fmt.Errorf("reading signing key: %w", err)
+		}
+		priv, err := signing.ParsePrivateKey(keyData)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", keyPath, err)
+		}
+
+		lf, err := lock.Load(lockfilePath)
+		if err != nil {
+			return err
+		}
+		canonical, err := signing.Canonical(lf)
+		if err != nil {
+			return err
+		}
+
+		sigPath := signing.SignaturePath(lockfilePath)
+		sf, err := signing.LoadSignatures(sigPath)
+		if err != nil {
+			return err
+		}
+		if sf == nil {
+			sf = &signing.SignatureFile{Version: 1}
+		}
+		if dropped := sf.DropInvalid(canonical); dropped > 0 {
+			detail("dropped %d stale signature(s)", dropped)
+		}
+		sf.Add(signing.Sign(canonical, priv, lockSignSigner))
+		if err := signing.SaveSignatures(sigPath, sf); err != nil {
+			return err
+		}
+
+		pub, _ := priv.Public().(ed25519.PublicKey)
+		info("Signed %s with %s → %s", lockfilePath, signing.Fingerprint(pub), sigPath)
+
+		// Warn if the key would not satisfy the project's policy.
+		if cfg, err := loadConfig(); err == nil && len(cfg.Signing.AllowedKeys) > 0 {
+			if _, err := signing.VerifyLockfile(lockfilePath, lf, cfg.Signing); err != nil {
+				errorf("this key is not in signing.allowed_keys; sync and check will not accept the signature")
+			}
+		}
+		return nil
+	},
+}
+
+var lockVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the lockfile signature against the allowed keys",
+	Long: `Verifies the detached lockfile signature against signing.allowed_keys and
+prints the signer. Exits non-zero unless an allowed key has signed the
+current lockfile content.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		lf, err := lock.Load(lockfilePath)
+		if err != nil {
+			return err
+		}
+		signer, err := signing.VerifyLockfile(lockfilePath, lf, cfg.Signing)
+		if err != nil {
+			return fmt.Errorf("%s: %w", lockfilePath, err)
+		}
+		info("%s is signed by %s (%s).", lockfilePath, signer.Name, signer.Fingerprint)
+		return nil
+	},
+}
+
+var lockKeygenCmd = &cobra.Command{
+	Use:   "keygen -o <private-key>",
+	Short: "Generate an ed25519 key pair for lockfile signing",
+	Long: `Writes a new ed25519 private key (PKCS#8 PEM, mode 0600) to the given path
+and its public key to <path>.pub. Add the printed public key to
+signing.allowed_keys.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if lockKeygenOut == "" {
+			return fmt.Errorf("--output is required")
+		}
+		if _, err := os.Stat(lockKeygenOut); err == nil {
+			return fmt.Errorf("%s already exists", lockKeygenOut)
+		}
+
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("generating key: %w", err)
+		}
+		pemData, err := signing.MarshalPrivateKey(priv)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(lockKeygenOut, pemData, 0600); err != nil {
+			return fmt.Errorf("writing private key: %w", err)
+		}
+		pubLine := signing.FormatPublicKey(pub, lockKeygenName)
+		if err := os.WriteFile(lockKeygenOut+".pub", []byte(pubLine+"\n"), 0644); err != nil {
+			return fmt.Errorf("writing public key: %w", err)
+		}
+
+		info("Private key: %s", lockKeygenOut)
+		info("Public key:  %s.pub (%s)", lockKeygenOut, signing.Fingerprint(pub))
+		fmt.Println(pubLine)
+		return nil
+	},
+}
+
+var lockMergeCmd = &cobra.Command{
+	Use:   "merge <base> <ours> <theirs>",
+	Short: "Merge lockfiles by source (git merge driver)",
+	Long: `Three-way merges lockfiles by source name and writes the result to <ours>.
+This is the git merge driver for the lockfile; git invokes it as
+'agent-sync lock merge %O %A %B'.
+
+A source changed on only one branch, or changed identically on both, merges
+cleanly. Only a source that both branches changed to different states is a
+conflict: it keeps our state, the conflicts are listed, and the command
+exits non-zero so git marks the lockfile as conflicted. Resolve by running
+'agent-sync update <source>' and committing the lockfile.
+
+Use --setup to register the driver for this repository: it adds the
+lockfile to .gitattributes and sets merge.agent-sync.driver in the local
+git config. Each clone needs the git config part; run --setup once per clone.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if lockMergeSetup {
+			if len(args) != 0 {
+				return fmt.Errorf("--setup takes no arguments")
+			}
+			return setupMergeDriver()
+		}
+		if len(args) != 3 {
+			return fmt.Errorf("expected <base> <ours> <theirs>, got %d argument(s)", len(args))
+		}
+
+		base, err := loadMergeInput(args[0])
+		if err != nil {
+			return err
+		}
+		ours, err := loadMergeInput(args[1])
+		if err != nil {
+			return err
+		}
+		theirs, err := loadMergeInput(args[2])
+		if err != nil {
+			return err
+		}
+		if ours == nil || theirs == nil {
+			return fmt.Errorf("both sides of the merge must hold a lockfile")
+		}
+
+		merged, conflicts, err := lock.Merge(base, ours, theirs)
+		if err != nil {
+			return err
+		}
+		if err := lock.Save(args[1], merged); err != nil {
+			return err
+		}
+		if len(conflicts) == 0 {
+			return nil
+		}
+
+		for _, c := range conflicts {
+			errorf("lockfile conflict: %s", c)
+		}
+		errorf("kept our state for conflicting sources; run 'agent-sync update <source>' to re-resolve them")
+		return fmt.Errorf("%d lockfile conflict(s)", len(conflicts))
+	},
+}
+
+// mergeDriver is the name the merge driver is registered under in git.
+const mergeDriver = "agent-sync"
+
+// loadMergeInput reads one side of a lockfile merge. Git passes an empty
+// file as the base when the lockfile has no common ancestor; that yields nil.
+func loadMergeInput(path string) (*lock.Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, nil
+	}
+	return lock.Load(path)
+}
+
+// setupMergeDriver registers the lockfile merge driver for the repository
+// containing the project: a .gitattributes entry next to the config and the
+// driver command in the local git config.
+func setupMergeDriver() error {
+	root, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	if out, err := exec.Command("git", "-C", root, "rev-parse", "--git-dir").CombinedOutput(); err != nil {
+		return fmt.Errorf("%s is not in a git repository: %s", root, strings.TrimSpace(string(out)))
+	}
+
+	lockAbs, err := filepath.Abs(lockfilePath)
+	if err != nil {
+		return fmt.Errorf("resolving lockfile path: %w", err)
+	}
+	rel, err := filepath.Rel(root, lockAbs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("lockfile %s is outside the project root %s", lockfilePath, root)
+	}
+	added, err := addGitAttribute(filepath.Join(root, ".gitattributes"), "/"+filepath.ToSlash(rel), "merge="+mergeDriver)
+	if err != nil {
+		return err
+	}
+	if added {
+		info("Added the lockfile merge driver to .gitattributes (commit this file).")
+	}
+
+	for _, kv := range [][2]string{
+		{"merge." + mergeDriver + ".name", "agent-sync lockfile merge"},
+		{"merge." + mergeDriver + ".driver", "agent-sync lock merge %O %A %B"},
+	} {
+		if out, err := exec.Command("git", "-C", root, "config", kv[0], kv[1]).CombinedOutput(); err != nil {
+			return fmt.Errorf("git config %s: %s", kv[0], strings.TrimSpace(string(out)))
+		}
+	}
+	info("Registered the lockfile merge driver in the local git config.")
+	return nil
+}
+
+// addGitAttribute appends "pattern attr" to a .gitattributes file unless a
+// line for pattern already sets attr. Reports whether the file changed.
+func addGitAttribute(path, pattern, attr string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[0] == pattern && slices.Contains(fields[1:], attr) {
+			return false, nil
+		}
+	}
+
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, pattern+" "+attr+"\n"...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return false, fmt.Errorf("writing %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// enforceSigning refuses to continue when the config requires a signed
+// lockfile and the lockfile lacks a valid signature from an allowed key.
+func enforceSigning(cfg *config.Config, lf *lock.Lockfile) error {
+	if !cfg.Signing.Require {
+		return nil
+	}
+	signer, err := signing.VerifyLockfile(lockfilePath, lf, cfg.Signing)
+	if errors.Is(err, signing.ErrUnsigned) || errors.Is(err, signing.ErrUntrusted) {
+		return fmt.Errorf("%s: %w — signing.require is set; sign it with 'agent-sync lock sign --key <key>'", lockfilePath, err)
+	}
+	if err != nil {
+		return err
+	}
+	detail("lockfile signed by %s (%s)", signer.Name, signer.Fingerprint)
+	return nil
+}
+
+// describeSignature summarizes the lockfile's signature state for status.
+// Returns "" when the lockfile is unsigned and signing is not required.
+func describeSignature(cfg *config.Config, lf *lock.Lockfile) string {
+	canonical, err := signing.Canonical(lf)
+	if err != nil {
+		return err.Error()
+	}
+	sf, err := signing.LoadSignatures(signing.SignaturePath(lockfilePath))
+	if err != nil {
+		return err.Error()
+	}
+	signers, err := signing.Check(canonical, sf, cfg.Signing.AllowedKeys)
+	switch {
+	case errors.Is(err, signing.ErrUnsigned):
+		if cfg.Signing.Require {
+			return "unsigned (signing is required)"
+		}
+		return ""
+	case err != nil:
+		return err.Error()
+	case len(signers) == 0:
+		return "signature does not match the lockfile content"
+	}
+
+	for _, s := range signers {
+		if s.Trusted {
+			return fmt.Sprintf("signed by %s (%s)", s.Name, s.Fingerprint)
+		}
+	}
+	name := signers[0].Name
+	if name == "" {
+		name = "unknown signer"
+	}
+	return fmt.Sprintf("signed by %s (%s), not an allowed key", name, signers[0].Fingerprint)
+}
+
+func init() {
+	lockSignCmd.Flags().StringVar(&lockSignKey, "key", "", "ed25519 private key file (default: $"+envSigningKey+")")
+	lockSignCmd.Flags().StringVar(&lockSignSigner, "signer", "", "signer name recorded with the signature")
+	lockKeygenCmd.Flags().StringVarP(&lockKeygenOut, "output", "o", "", "private key file to write")
+	lockKeygenCmd.Flags().StringVar(&lockKeygenName, "name", "", "comment appended to the public key")
+	lockMergeCmd.Flags().BoolVar(&lockMergeSetup, "setup", false, "register the merge driver in .gitattributes and the local git config")
+
+	lockCmd.AddCommand(lockSignCmd)
+	lockCmd.AddCommand(lockVerifyCmd)
+	lockCmd.AddCommand(lockKeygenCmd)
+	lockCmd.AddCommand(lockMergeCmd)
+	rootCmd.AddCommand(lockCmd)
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/signing"
+	"github.com/spf13/cobra"
+)
+
+var migrateDryRun bool
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Rewrite the config and lockfile in the current schema version",
+	Long: `Config and lockfile files written for an older schema version are upgraded
+in memory whenever they are loaded, with a warning. migrate rewrites the
+project config and the lockfile in the current version so the warnings go
+away. Comments in the config are preserved.
+
+Files declaring a version newer than this agent-sync supports are rejected;
+upgrade agent-sync to read them. System and user config layers are only
+reported, never rewritten.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !migrateDryRun {
+			unlock, err := lockProject(cmd.Context(), "migrate")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+
+		changed := false
+
+		data, notes, err := config.Migrate(configPath)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			changed = true
+			for _, n := range notes {
+				info("%s", n)
+			}
+			if !migrateDryRun {
+				if err := writeFilePreservingMode(configPath, data); err != nil {
+					return err
+				}
+				info("Rewrote %s in version %d.", configPath, config.CurrentVersion)
+			}
+		}
+
+		lf, err := lock.Load(lockfilePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if lf != nil && len(lf.Migrations()) > 0 {
+			changed = true
+			for _, n := range lf.Migrations() {
+				info("%s", n)
+			}
+			if !migrateDryRun {
+				if err := saveLockfile(lf); err != nil {
+					return fmt.Errorf("saving lockfile: %w", err)
+				}
+				info("Rewrote %s in version %d.", lockfilePath, lock.CurrentVersion)
+				if _, err := os.Stat(signing.SignaturePath(lockfilePath)); err == nil {
+					info("The lockfile changed; re-sign it with 'agent-sync lock sign'.")
+				}
+			}
+		}
+
+		switch {
+		case !changed:
+			info("Config and lockfile are already at the current schema version.")
+		case migrateDryRun:
+			info("\nDry run — no files modified.")
+		}
+		return nil
+	},
+}
+
+// writeFilePreservingMode replaces a file's content, keeping its
+// permissions. The content goes to a temp file in the same directory that
+// is renamed over the file, so a crash leaves either the old or the new
+// content. A symlink is followed, so that the file it points to is
+// replaced rather than the link.
+func writeFilePreservingMode(path string, data []byte) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(real)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(real)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(real)+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	tmpPath := tmp.Name()
+	success := false
+	defer func() {
+		if !success {
+			_ = tmp.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Chmod(tmpPath, fi.Mode().Perm()); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, real); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	success = true
+	return nil
+}
+
+func init() {
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "show what would be migrated without writing")
+	rootCmd.AddCommand(migrateCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFilePreservingMode(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "real.yaml")
+	if err := os.WriteFile(real, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "agent-sync.yaml")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skip("symlinks not supported")
+	}
+
+	if err := writeFilePreservingMode(link, []byte("new\n")); err != nil {
+		t.Fatalf("writeFilePreservingMode: %v", err)
+	}
+	if data, _ := os.ReadFile(real); string(data) != "new\n" {
+		t.Errorf("content = %q", data)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink was replaced: %v", err)
+	}
+	if fi, _ := os.Stat(real); fi.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", fi.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("directory has %d entries, want no temp file left behind", len(entries))
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/signing"
+	"github.com/spf13/cobra"
+)
+
+var pinReason string
+
+var pinCmd = &cobra.Command{
+	Use:   "pin <source-name>",
+	Short: "Freeze a source at its locked version",
+	Long: `Marks a locked source as pinned. 'agent-sync update' without source names
+skips pinned sources and says so; sync and check are unaffected. Naming a
+pinned source explicitly ('agent-sync update <source>') still updates it, and
+it stays pinned at the new version.
+
+Use --reason to record why, for example the upstream release that broke it.
+The reason is stored in the lockfile and shown by status, outdated, and update.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setPin(cmd, args[0], true, pinReason)
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin <source-name>",
+	Short: "Release a pinned source so update resolves it again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setPin(cmd, args[0], false, "")
+	},
+}
+
+// setPin pins or unpins a locked source and saves the lockfile.
+func setPin(cmd *cobra.Command, name string, pinned bool, reason string) error {
+	unlock, err := lockProject(cmd.Context(), "pin")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	lf, err := loadLockfile()
+	if err != nil {
+		return err
+	}
+
+	ls := lf.Source(name)
+	if ls == nil {
+		return fmt.Errorf("source '%s' is not in the lockfile — run 'agent-sync update %s' first", name, name)
+	}
+
+	switch {
+	case pinned && ls.Pinned() && ls.PinReason == reason:
+		info("%s is already pinned at %s.", name, summarizeLockedSource(ls))
+		return nil
+	case !pinned && !ls.Pinned():
+		info("%s is not pinned.", name)
+		return nil
+	case pinned:
+		ls.Status = lock.StatusPinned
+		ls.PinReason = reason
+	default:
+		ls.Status = lock.StatusOK
+		ls.PinReason = ""
+	}
+
+	if err := saveLockfile(lf); err != nil {
+		return fmt.Errorf("saving lockfile: %w", err)
+	}
+	if pinned {
+		info("Pinned %s at %s%s.", name, summarizeLockedSource(ls), pinReasonSuffix(reason))
+	} else {
+		info("Unpinned %s; 'agent-sync update' will resolve it again.", name)
+	}
+	if _, err := os.Stat(signing.SignaturePath(lockfilePath)); err == nil || cfg.Signing.Require {
+		info("The lockfile changed; re-sign it with 'agent-sync lock sign'.")
+	}
+	return nil
+}
+
+// pinReasonSuffix formats a pin reason for appending to a message.
+func pinReasonSuffix(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return " (" + reason + ")"
+}
+
+func init() {
+	pinCmd.Flags().StringVar(&pinReason, "reason", "", "why the source is pinned")
+	rootCmd.AddCommand(pinCmd)
+	rootCmd.AddCommand(unpinCmd)
+}
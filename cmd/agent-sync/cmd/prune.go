@@ -16,7 +16,15 @@ var pruneCmd = &cobra.Command{
 Removes files that were previously synced but are no longer in the config.
 Use --dry-run to see what would be removed without acting.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := loadConfig()
+		if !pruneDryRun {
+			unlock, err := lockProject(cmd.Context(), "prune")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+
+		cfg, err := loadLockConfig()
 		if err != nil {
 			return err
 		}
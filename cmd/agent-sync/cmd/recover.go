@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/bianoble/agent-sync/internal/engine"
+	"github.com/bianoble/agent-sync/internal/journal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recoverRollback bool
+	recoverDiscard  bool
+)
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Finish or undo an operation that was interrupted mid-write",
+	Long: `Before writing target files, sync records the planned changes and the
+previous content of every file in ` + journal.Dir + `/. If the process is
+killed or the machine loses power mid-write, that journal remains.
+
+recover rolls the interrupted operation forward, completing it, or with
+--rollback restores every file to its state before the operation. Files
+changed by hand since the interruption are left as they are and reported,
+and the journal is kept until they are dealt with: restore them and run
+recover again, or run recover --discard to drop the journal and keep every
+file as it is.
+
+Mutating commands (sync, update, prune, vendor) roll an interrupted
+operation forward automatically before they start.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if recoverRollback && recoverDiscard {
+			return fmt.Errorf("--rollback and --discard cannot be used together")
+		}
+		unlock, err := acquireProjectLock(cmd.Context(), "recover")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		result, err := recoverInterrupted(cmd.Context(), engine.RecoverOptions{Rollback: recoverRollback, Discard: recoverDiscard})
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			info("Nothing to recover.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	recoverCmd.Flags().BoolVar(&recoverRollback, "rollback", false, "restore files to their state before the interrupted operation")
+	recoverCmd.Flags().BoolVar(&recoverDiscard, "discard", false, "drop the journal and leave every file as it is")
+	rootCmd.AddCommand(recoverCmd)
+}
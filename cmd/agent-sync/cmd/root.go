@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -16,12 +17,16 @@ var (
 
 // Global flags.
 var (
-	configPath   string
-	lockfilePath string
-	verbose      bool
-	quiet        bool
-	noColor      bool
-	noInherit    bool
+	configPath    string
+	lockfilePath  string
+	verbose       bool
+	quiet         bool
+	noColor       bool
+	noInherit     bool
+	offline       bool
+	allowCommands bool
+	profile       string
+	lockWait      time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -53,6 +58,10 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "minimal output (errors only)")
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable colored output")
 	rootCmd.PersistentFlags().BoolVar(&noInherit, "no-inherit", false, "disable hierarchical config resolution; use only the project config")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "forbid network access; serve content only from local storage")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "config profile to apply (default: AGENT_SYNC_PROFILE)")
+	rootCmd.PersistentFlags().BoolVar(&allowCommands, "allow-commands", false, "run command variable providers in the config, and let file providers read outside the project")
+	rootCmd.PersistentFlags().DurationVar(&lockWait, "wait", 0, "wait up to this long for another agent-sync operation on the project to finish (e.g. 30s)")
 
 	rootCmd.AddCommand(versionCmd)
 }
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/sbom"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sbomFormat  string
+	sbomOutput  string
+	sbomProject string
+)
+
+var sbomCmd = &cobra.Command{
+	Use:   "sbom",
+	Short: "Export a bill of materials for the synced agent content",
+	Long: `Writes a CycloneDX 1.5 or SPDX 2.3 JSON document describing every locked
+source: its repository, commit or URL, and hashes, each of its files with the
+project paths it is synced to, and the transforms applied to it. The config
+layers the configuration was merged from, and the fragments they include,
+are recorded with their hashes.
+
+The output depends only on the lockfile and config, so it is reproducible.
+SPDX requires a creation time: it is taken from SOURCE_DATE_EPOCH if set,
+and is otherwise the Unix epoch.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hr, err := loadConfigHierarchical()
+		if err != nil {
+			return err
+		}
+		cfg, err := applyProfile(hr.Config)
+		if err != nil {
+			return err
+		}
+		lf, err := loadLockfile()
+		if err != nil {
+			return err
+		}
+		root, err := projectRoot()
+		if err != nil {
+			return err
+		}
+
+		created, err := sourceDateEpoch()
+		if err != nil {
+			return err
+		}
+		in := sbom.Input{
+			Created:     created,
+			Lockfile:    lf,
+			Config:      cfg,
+			ToolMap:     newToolMap(cfg),
+			Project:     sbomProject,
+			ToolVersion: version,
+		}
+		if in.Project == "" {
+			in.Project = filepath.Base(root)
+		}
+		for _, l := range hr.Layers {
+			if !l.Loaded {
+				continue
+			}
+			layer, err := sbomLayer(root, string(l.Level), l.Path)
+			if err != nil {
+				return err
+			}
+			in.Layers = append(in.Layers, layer)
+			for _, inc := range l.Includes {
+				layer, err := sbomLayer(root, string(l.Level), inc)
+				if err != nil {
+					return err
+				}
+				in.Layers = append(in.Layers, layer)
+			}
+		}
+
+		out, err := sbom.Generate(sbomFormat, in)
+		if err != nil {
+			return err
+		}
+		if sbomOutput == "" || sbomOutput == "-" {
+			_, err = os.Stdout.Write(out)
+			return err
+		}
+		if err := os.WriteFile(sbomOutput, out, 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", sbomOutput, err)
+		}
+		info("Wrote %s SBOM to %s", sbomFormat, sbomOutput)
+		return nil
+	},
+}
+
+// sourceDateEpoch returns the time set by SOURCE_DATE_EPOCH, the
+// reproducible-builds convention, or the Unix epoch if it is unset.
+func sourceDateEpoch() (time.Time, error) {
+	v := os.Getenv("SOURCE_DATE_EPOCH")
+	if v == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: must be an integer number of seconds", v)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// sbomLayer describes a loaded config file. Paths inside the project are
+// made relative so the SBOM does not depend on where the project is checked
+// out.
+func sbomLayer(root, level, path string) (sbom.Layer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sbom.Layer{}, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+
+	display := path
+	if abs, err := filepath.Abs(path); err == nil {
+		if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			display = filepath.ToSlash(rel)
+		}
+	}
+	return sbom.Layer{Level: level, Path: display, SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+func init() {
+	sbomCmd.Flags().StringVar(&sbomFormat, "format", sbom.FormatCycloneDX, "output format: cyclonedx or spdx")
+	sbomCmd.Flags().StringVarP(&sbomOutput, "output", "o", "", "write to file instead of stdout")
+	sbomCmd.Flags().StringVar(&sbomProject, "name", "", "project name recorded in the SBOM (default: project directory name)")
+	rootCmd.AddCommand(sbomCmd)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sourceAddGit         string
+	sourceAddRef         string
+	sourceAddURL         string
+	sourceAddChecksum    string
+	sourceAddLocal       string
+	sourceAddPaths       []string
+	sourceAddTools       []string
+	sourceAddDestination string
+	sourceAddUpdate      bool
+)
+
+var sourceCmd = &cobra.Command{
+	Use:   "source",
+	Short: "Add or remove sources in agent-sync.yaml",
+}
+
+var sourceAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a source, and optionally a target for it",
+	Long: `Adds a source to the project config. Exactly one of --git, --url, or --local
+gives its location. --tools or --destination also adds a target that syncs
+the source to those tools or that directory.
+
+The config is edited in place, keeping its comments and key order, and is
+only written if it still validates with the system and user layers merged
+in. --update then resolves the new source and records it in the lockfile,
+as 'agent-sync update <name>' would, without asking for confirmation.
+
+  agent-sync source add team-rules --git https://github.com/org/rules.git \
+    --ref v1 --tools cursor,claude-code`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		src := config.Source{Name: name, Paths: sourceAddPaths}
+		locations := 0
+		if sourceAddGit != "" {
+			src.Type, src.Repo, src.Ref = "git", sourceAddGit, sourceAddRef
+			locations++
+		}
+		if sourceAddURL != "" {
+			src.Type, src.URL, src.Checksum = "url", sourceAddURL, sourceAddChecksum
+			locations++
+		}
+		if sourceAddLocal != "" {
+			src.Type, src.Path = "local", sourceAddLocal
+			locations++
+		}
+		if locations != 1 {
+			return errors.New("exactly one of --git, --url, or --local is required")
+		}
+
+		addTarget := len(sourceAddTools) > 0 || sourceAddDestination != ""
+		err := editConfig(cmd.Context(), "source add", func(e *config.Editor) error {
+			if err := e.AddSource(src); err != nil {
+				return err
+			}
+			if !addTarget {
+				return nil
+			}
+			return e.AddTarget(config.Target{Source: name, Tools: sourceAddTools, Destination: sourceAddDestination})
+		})
+		if err != nil {
+			return err
+		}
+		if addTarget {
+			info("Added source '%s' and a target for it to %s.", name, configPath)
+		} else {
+			info("Added source '%s' to %s.", name, configPath)
+		}
+
+		if !sourceAddUpdate {
+			detail("Run 'agent-sync update %s' to lock it.", name)
+			return nil
+		}
+		updateYes = true
+		return updateCmd.RunE(cmd, []string{name})
+	},
+}
+
+var sourceRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a source and its targets and transforms",
+	Long: `Removes a source from the project config, together with the targets and
+transforms there that use it. Only sources defined in the project config
+itself can be removed this way; to drop a source inherited from the system
+or user config, list it under 'remove: sources:' instead.
+
+Run 'agent-sync prune' to delete the files it synced; the next
+'agent-sync update' drops it from the lockfile.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		err := editConfig(cmd.Context(), "source remove", func(e *config.Editor) error {
+			return e.RemoveSource(name)
+		})
+		if err != nil {
+			return err
+		}
+		info("Removed source '%s' from %s.", name, configPath)
+		return nil
+	},
+}
+
+func init() {
+	sourceAddCmd.Flags().StringVar(&sourceAddGit, "git", "", "git repository URL")
+	sourceAddCmd.Flags().StringVar(&sourceAddRef, "ref", "", "git tag, branch, or commit to track")
+	sourceAddCmd.Flags().StringVar(&sourceAddURL, "url", "", "URL of a single file")
+	sourceAddCmd.Flags().StringVar(&sourceAddChecksum, "checksum", "", "expected checksum of the URL content (sha256:...)")
+	sourceAddCmd.Flags().StringVar(&sourceAddLocal, "local", "", "local directory, relative to the project")
+	sourceAddCmd.Flags().StringSliceVar(&sourceAddPaths, "paths", nil, "paths within the git repository to sync (comma-separated)")
+	sourceAddCmd.Flags().StringSliceVar(&sourceAddTools, "tools", nil, "add a target syncing to these tools (comma-separated)")
+	sourceAddCmd.Flags().StringVar(&sourceAddDestination, "destination", "", "add a target syncing to this directory")
+	sourceAddCmd.Flags().BoolVar(&sourceAddUpdate, "update", false, "resolve the new source and lock it")
+	sourceCmd.AddCommand(sourceAddCmd)
+	sourceCmd.AddCommand(sourceRemoveCmd)
+	rootCmd.AddCommand(sourceCmd)
+}
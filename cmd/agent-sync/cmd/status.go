@@ -46,12 +46,29 @@ and sync state (synced, drifted, missing, pending) for all or named sources.`,
 
 		// Print table header.
 		fmt.Printf("%-20s %-8s %-16s %-30s %s\n", "SOURCE", "TYPE", "PINNED AT", "TARGETS", "STATE")
+		var pinned []engine.SourceStatus
 		for _, s := range statuses {
 			targets := strings.Join(s.Targets, ", ")
 			if len(targets) > 30 {
 				targets = targets[:27] + "..."
 			}
-			fmt.Printf("%-20s %-8s %-16s %-30s %s\n", s.Name, s.Type, s.PinnedAt, targets, s.State)
+			state := s.State
+			if s.Pinned {
+				state += " (pinned)"
+				pinned = append(pinned, s)
+			}
+			fmt.Printf("%-20s %-8s %-16s %-30s %s\n", s.Name, s.Type, s.PinnedAt, targets, state)
+		}
+
+		if len(pinned) > 0 {
+			fmt.Println("\nPinned (skipped by update):")
+			for _, s := range pinned {
+				fmt.Printf("  %s%s\n", s.Name, pinReasonSuffix(s.PinReason))
+			}
+		}
+
+		if sig := describeSignature(cfg, lf); sig != "" {
+			fmt.Printf("\nLockfile: %s\n", sig)
 		}
 
 		return nil
@@ -7,15 +7,30 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var syncDryRun bool
+var (
+	syncDryRun bool
+	syncAtomic bool
+)
 
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Synchronize files to targets using the lockfile",
-	Long: `Reads the lockfile as the source of truth, fetches content from cache or
-sources as needed, and writes files to target locations. Does NOT modify the
-lockfile — only 'update' and 'prune' modify the lockfile.`,
+	Long: `Reads the lockfile as the source of truth, fetches content from the vendor
+directory, cache, or sources as needed, and writes files to target locations. Does NOT modify the
+lockfile — only 'update' and 'prune' modify the lockfile.
+
+With --atomic (or sync.atomic: true in config), nothing is written unless
+every source fetches and renders successfully. A failure while writing always
+rolls back every file written so far.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if !syncDryRun {
+			unlock, err := lockProject(cmd.Context(), "sync")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+
 		cfg, err := loadConfig()
 		if err != nil {
 			return err
@@ -25,13 +40,21 @@ lockfile — only 'update' and 'prune' modify the lockfile.`,
 		if err != nil {
 			return err
 		}
+		if err := enforceSigning(cfg, lf); err != nil {
+			return err
+		}
 
 		root, err := projectRoot()
 		if err != nil {
 			return err
 		}
 
-		c, err := newCache()
+		c, err := newProjectCache(cfg)
+		if err != nil {
+			return err
+		}
+
+		vendorStore, err := openVendor(root)
 		if err != nil {
 			return err
 		}
@@ -39,18 +62,31 @@ lockfile — only 'update' and 'prune' modify the lockfile.`,
 		eng := &engine.SyncEngine{
 			Registry:    newRegistry(),
 			Cache:       c,
+			Vendor:      vendorStore,
 			ToolMap:     newToolMap(cfg),
 			ProjectRoot: root,
 		}
 
-		opts := engine.SyncOptions{DryRun: syncDryRun}
+		atomic := cfg.Sync.IsAtomic()
+		if cmd.Flags().Changed("atomic") {
+			atomic = syncAtomic
+		}
+
+		opts := engine.SyncOptions{DryRun: syncDryRun, Atomic: atomic}
 		result, err := eng.Sync(cmd.Context(), *lf, *cfg, opts)
 		if err != nil {
+			if result != nil {
+				for _, e := range result.Errors {
+					errorf("%s: %s", e.Source, e.Err)
+				}
+			}
 			return err
 		}
 
 		if syncDryRun {
 			info("Dry run — no files written.")
+		} else {
+			recordCacheUse(c, cfg)
 		}
 
 		for _, f := range result.Written {
@@ -78,5 +114,7 @@ lockfile — only 'update' and 'prune' modify the lockfile.`,
 
 func init() {
 	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "show what would change without writing files")
+	syncCmd.Flags().BoolVar(&syncAtomic, "atomic", false, "write nothing unless every source succeeds; overrides sync.atomic")
+	addWorkspaceFlag(syncCmd)
 	rootCmd.AddCommand(syncCmd)
 }
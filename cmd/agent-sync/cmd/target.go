@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	targetAddName        string
+	targetAddTools       []string
+	targetAddDestination string
+)
+
+var targetCmd = &cobra.Command{
+	Use:   "target",
+	Short: "Add targets to agent-sync.yaml",
+}
+
+var targetAddCmd = &cobra.Command{
+	Use:   "add <source-name>",
+	Short: "Add a target syncing a source to tools or a directory",
+	Long: `Adds a target to the project config that syncs the named source to the
+tools given with --tools or to the directory given with --destination.
+--name names the target, so that profiles can select it.
+
+The config is edited in place, keeping its comments and key order, and is
+only written if it still validates with the system and user layers merged
+in. Run 'agent-sync sync' to write the new target's files.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(targetAddTools) == 0 && targetAddDestination == "" {
+			return errors.New("one of --tools or --destination is required")
+		}
+		t := config.Target{
+			Name:        targetAddName,
+			Source:      args[0],
+			Tools:       targetAddTools,
+			Destination: targetAddDestination,
+		}
+		err := editConfig(cmd.Context(), "target add", func(e *config.Editor) error {
+			return e.AddTarget(t)
+		})
+		if err != nil {
+			return err
+		}
+		info("Added a target for source '%s' to %s.", t.Source, configPath)
+		return nil
+	},
+}
+
+func init() {
+	targetAddCmd.Flags().StringVar(&targetAddName, "name", "", "target name, for selecting it in profiles")
+	targetAddCmd.Flags().StringSliceVar(&targetAddTools, "tools", nil, "tools to sync to (comma-separated)")
+	targetAddCmd.Flags().StringVar(&targetAddDestination, "destination", "", "directory to sync to")
+	targetCmd.AddCommand(targetAddCmd)
+	rootCmd.AddCommand(targetCmd)
+}
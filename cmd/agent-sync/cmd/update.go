@@ -4,16 +4,26 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
 
+	"github.com/bianoble/agent-sync/internal/config"
 	"github.com/bianoble/agent-sync/internal/engine"
 	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/signing"
+	"github.com/bianoble/agent-sync/internal/source"
+	"github.com/bianoble/agent-sync/internal/textdiff"
 	"github.com/spf13/cobra"
 )
 
 var (
 	updateDryRun bool
 	updateYes    bool
+	updateDiff   bool
+
+	updateIgnoreCooldown bool
+	updateChangelog      bool
 )
 
 var updateCmd = &cobra.Command{
@@ -21,9 +31,30 @@ var updateCmd = &cobra.Command{
 	Short: "Resolve sources against upstream and update the lockfile",
 	Long: `Resolves each source to its current upstream state, shows a diff of lockfile
 changes, and updates the lockfile. If source names are provided, only those
-sources are updated; others are left unchanged.`,
+sources are updated; others are left unchanged. Pinned sources (see
+'agent-sync pin') are skipped unless named.
+
+Git sources with a min_age cooldown (per source, or update.min_age) are not
+locked at a commit younger than min_age by committer date: the newest
+release tag old enough is used instead, and the source fails if there is
+none. --ignore-cooldown bypasses this; each bypass is reported and recorded
+in the lockfile.
+
+Before asking for confirmation, each changed source is listed with its
+files added (+), removed (-), and modified (~) and their line counts. At the
+prompt, answer d to see unified diffs or s to accept or reject each source
+in turn. --diff prints the unified diffs up front, and --changelog the
+upstream commits of each updated git source (see 'agent-sync changelog').`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := loadConfig()
+		if !updateDryRun {
+			unlock, err := lockProject(cmd.Context(), "update")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+
+		cfg, err := loadLockConfig()
 		if err != nil {
 			return err
 		}
@@ -38,7 +69,7 @@ sources are updated; others are left unchanged.`,
 			return err
 		}
 
-		c, err := newCache()
+		c, err := newProjectCache(cfg)
 		if err != nil {
 			return err
 		}
@@ -53,6 +84,8 @@ sources are updated; others are left unchanged.`,
 			DryRun:      updateDryRun,
 			AutoConfirm: updateYes,
 			SourceNames: args,
+
+			IgnoreCooldown: updateIgnoreCooldown,
 		}
 
 		result, err := eng.Update(cmd.Context(), *cfg, lf, opts)
@@ -60,19 +93,53 @@ sources are updated; others are left unchanged.`,
 			return err
 		}
 
+		for _, p := range result.Pinned {
+			info("  %-20s  pinned, skipped%s", p.Name, pinReasonSuffix(p.Reason))
+		}
+		for _, h := range result.Cooldowns {
+			tooYoung := fmt.Sprintf("latest commit %.8s is %s old, younger than min_age %s", h.Commit, config.FormatAge(h.Age), config.FormatAge(h.MinAge))
+			switch {
+			case h.Ignored:
+				warnf("%s: %s; locking it anyway (--ignore-cooldown)", h.Name, tooYoung)
+			case h.Kept:
+				info("  %-20s  %s; keeping the locked version", h.Name, tooYoung)
+			default:
+				info("  %-20s  %s; using release %s", h.Name, tooYoung, h.Fallback)
+			}
+		}
+
 		// Display changes.
-		if len(result.Updated) == 0 && len(result.Failed) == 0 {
-			info("All sources are up to date.")
+		var reviews []*updateReview
+		for _, u := range result.Updated {
+			if !u.Changed() {
+				detail("%-20s  %s (unchanged)", u.Name, summarizeLockedSource(u.After))
+				continue
+			}
+			reviews = append(reviews, &updateReview{update: u, diff: lock.DiffSource(u.Before, u.After)})
+		}
+		if len(reviews) == 0 && len(result.Failed) == 0 && (result.Lockfile == nil || reflect.DeepEqual(result.Lockfile.Sources, lf.Sources)) {
+			if len(result.Pinned) > 0 {
+				info("All unpinned sources are up to date.")
+			} else {
+				info("All sources are up to date.")
+			}
 			return nil
 		}
 
-		for _, u := range result.Updated {
-			before := "(new)"
-			if u.Before != nil {
-				before = summarizeLockedSource(u.Before)
+		// Per-file summaries need both versions' content, which may mean
+		// fetching the old version; skip that when nobody will read it.
+		review := !updateYes || updateDiff
+		for _, r := range reviews {
+			if review {
+				r.load(cmd, eng, *cfg)
+			}
+			r.printSummary(review)
+			if updateChangelog {
+				r.printChangelog(cmd, eng.Registry, cfg)
+			}
+			if updateDiff {
+				r.printDiffs()
 			}
-			after := summarizeLockedSource(u.After)
-			info("  %-20s  %s → %s", u.Name, before, after)
 		}
 		for _, e := range result.Failed {
 			errorf("%s: %s", e.Source, e.Err)
@@ -84,15 +151,15 @@ sources are updated; others are left unchanged.`,
 		}
 
 		// Confirm unless --yes.
-		if !updateYes && len(result.Updated) > 0 {
-			fmt.Printf("\nApply %d update(s) to lockfile? [y/N] ", len(result.Updated))
-			scanner := bufio.NewScanner(os.Stdin)
-			if scanner.Scan() {
-				answer := strings.TrimSpace(strings.ToLower(scanner.Text()))
-				if answer != "y" && answer != "yes" {
-					info("Aborted.")
-					return nil
-				}
+		if !updateYes && len(reviews) > 0 {
+			accepted, ok := confirmUpdates(reviews, result)
+			if !ok {
+				info("Aborted.")
+				return nil
+			}
+			if accepted == 0 {
+				info("No updates selected; lockfile not modified.")
+				return nil
 			}
 		}
 
@@ -102,6 +169,10 @@ sources are updated; others are left unchanged.`,
 				return fmt.Errorf("saving lockfile: %w", err)
 			}
 			info("\nLockfile updated.")
+			if _, err := os.Stat(signing.SignaturePath(lockfilePath)); err == nil || cfg.Signing.Require {
+				info("The lockfile changed; re-sign it with 'agent-sync lock sign'.")
+			}
+			recordCacheUse(c, cfg)
 		}
 
 		if len(result.Failed) > 0 {
@@ -111,6 +182,174 @@ sources are updated; others are left unchanged.`,
 	},
 }
 
+// updateReview holds what is shown to the user about one source update.
+type updateReview struct {
+	before map[string][]byte // file content, when available
+	after  map[string][]byte
+	diff   lock.SourceDiff
+	update engine.SourceUpdate
+}
+
+func (r *updateReview) load(cmd *cobra.Command, eng *engine.UpdateEngine, cfg config.Config) {
+	if r.update.Before != nil {
+		r.before = eng.Contents(cmd.Context(), cfg, r.update.Before)
+	}
+	r.after = eng.Contents(cmd.Context(), cfg, r.update.After)
+}
+
+// printSummary prints the version change and, if files is set, one line
+// per added, removed, or modified file with its line counts.
+func (r *updateReview) printSummary(files bool) {
+	before := "(new)"
+	if r.update.Before != nil {
+		before = summarizeLockedSource(r.update.Before)
+	}
+	info("  %-20s  %s → %s", r.update.Name, before, summarizeLockedSource(r.update.After))
+	if !files {
+		return
+	}
+
+	for _, p := range r.diff.Added {
+		info("      + %-40s %s", p, r.lineStat(p))
+	}
+	for _, p := range r.diff.Removed {
+		info("      - %-40s %s", p, r.lineStat(p))
+	}
+	for _, p := range r.diff.Modified {
+		info("      ~ %-40s %s", p, r.lineStat(p))
+	}
+}
+
+// lineStat formats the lines added and removed in a file, or notes that
+// its content is unavailable.
+func (r *updateReview) lineStat(path string) string {
+	before, hadBefore := r.before[path]
+	after, hasAfter := r.after[path]
+	if (!hadBefore && r.isIn(r.update.Before, path)) || (!hasAfter && r.isIn(r.update.After, path)) {
+		return "(content unavailable)"
+	}
+	added, removed := textdiff.Stat(before, after)
+	switch {
+	case removed == 0:
+		return fmt.Sprintf("(+%d)", added)
+	case added == 0:
+		return fmt.Sprintf("(-%d)", removed)
+	default:
+		return fmt.Sprintf("(+%d -%d)", added, removed)
+	}
+}
+
+func (r *updateReview) isIn(ls *lock.LockedSource, path string) bool {
+	if ls == nil {
+		return false
+	}
+	_, ok := ls.Resolved.Files[path]
+	return ok
+}
+
+// printChangelog prints the upstream commits between the old and new
+// commit of a git source.
+func (r *updateReview) printChangelog(cmd *cobra.Command, reg *source.Registry, cfg *config.Config) {
+	before, after := r.update.Before, r.update.After
+	if before == nil || before.Resolved.Commit == "" || after.Resolved.Commit == "" || before.Resolved.Commit == after.Resolved.Commit {
+		return
+	}
+	for _, src := range cfg.Sources {
+		if src.Name != r.update.Name {
+			continue
+		}
+		log, err := sourceChangelog(cmd.Context(), reg, src, before.Resolved.Commit, after.Resolved.Commit)
+		if err != nil {
+			warnf("changelog for %s: %s", src.Name, err)
+			return
+		}
+		fmt.Print("\n" + log.Markdown() + "\n")
+		return
+	}
+}
+
+// printDiffs prints a unified diff for every changed file whose content is
+// available.
+func (r *updateReview) printDiffs() {
+	var paths []string
+	paths = append(paths, r.diff.Added...)
+	paths = append(paths, r.diff.Removed...)
+	paths = append(paths, r.diff.Modified...)
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		before, hadBefore := r.before[p]
+		after, hasAfter := r.after[p]
+		oldLabel, newLabel := "a/"+r.update.Name+"/"+p, "b/"+r.update.Name+"/"+p
+		if !r.isIn(r.update.Before, p) {
+			oldLabel = textdiff.DevNull
+		} else if !hadBefore {
+			continue
+		}
+		if !r.isIn(r.update.After, p) {
+			newLabel = textdiff.DevNull
+		} else if !hasAfter {
+			continue
+		}
+		fmt.Print("\n" + textdiff.Unified(oldLabel, newLabel, before, after))
+	}
+	fmt.Println()
+}
+
+// confirmUpdates asks whether to apply the reviewed updates, optionally
+// showing diffs or going through the sources one by one. Rejected sources
+// are dropped from result. It returns the number of updates accepted and
+// false if the user aborted.
+func confirmUpdates(reviews []*updateReview, result *engine.UpdateResult) (int, bool) {
+	scanner := bufio.NewScanner(os.Stdin)
+	ask := func(prompt string) (string, bool) {
+		fmt.Print(prompt)
+		if !scanner.Scan() {
+			return "", false
+		}
+		return strings.TrimSpace(strings.ToLower(scanner.Text())), true
+	}
+
+	for {
+		answer, ok := ask(fmt.Sprintf("\nApply %d update(s) to lockfile? [y]es, [N]o, [d]iffs, [s]elect per source: ", len(reviews)))
+		if !ok {
+			// No input to read (stdin closed): keep the historical behavior of applying.
+			return len(reviews), true
+		}
+		switch answer {
+		case "y", "yes":
+			return len(reviews), true
+		case "d", "diff", "diffs":
+			for _, r := range reviews {
+				info("%s:", r.update.Name)
+				r.printDiffs()
+			}
+		case "s", "select":
+			accepted := 0
+			for _, r := range reviews {
+				for {
+					answer, ok := ask(fmt.Sprintf("  Apply %s? [y/n/d] ", r.update.Name))
+					if !ok || answer == "n" || answer == "no" {
+						result.Reject(r.update.Name)
+						break
+					}
+					if answer == "d" {
+						r.printDiffs()
+						continue
+					}
+					if answer == "y" || answer == "yes" {
+						accepted++
+						break
+					}
+				}
+			}
+			return accepted, true
+		default:
+			return 0, false
+		}
+	}
+}
+
 func summarizeLockedSource(ls *lock.LockedSource) string {
 	if ls == nil {
 		return "(none)"
@@ -138,5 +377,9 @@ func summarizeLockedSource(ls *lock.LockedSource) string {
 func init() {
 	updateCmd.Flags().BoolVar(&updateDryRun, "dry-run", false, "show what would change without updating the lockfile")
 	updateCmd.Flags().BoolVar(&updateYes, "yes", false, "skip interactive confirmation")
+	updateCmd.Flags().BoolVar(&updateDiff, "diff", false, "show unified diffs of changed files")
+	updateCmd.Flags().BoolVar(&updateChangelog, "changelog", false, "show the upstream commits for each updated git source")
+	updateCmd.Flags().BoolVar(&updateIgnoreCooldown, "ignore-cooldown", false, "lock commits younger than min_age")
+	addWorkspaceFlag(updateCmd)
 	rootCmd.AddCommand(updateCmd)
 }
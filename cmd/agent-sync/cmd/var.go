@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var varCmd = &cobra.Command{
+	Use:   "var",
+	Short: "Set variables in agent-sync.yaml",
+}
+
+var varSetCmd = &cobra.Command{
+	Use:   "set <name> <value>",
+	Short: "Set a variable to a literal value",
+	Long: `Sets a variable in the project config to a literal value, replacing its
+value or variable provider if the project config already defines it. The
+value is written as is: ${...} references in it are resolved when the config
+is loaded.
+
+The config is edited in place, keeping its comments and key order, and is
+only written if it still validates with the system and user layers merged
+in, so a variable enforced by a lower layer cannot be set.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, value := args[0], args[1]
+		err := editConfig(cmd.Context(), "var set", func(e *config.Editor) error {
+			return e.SetVariable(name, value)
+		})
+		if err != nil {
+			return err
+		}
+		info("Set variable '%s' in %s.", name, configPath)
+		return nil
+	},
+}
+
+func init() {
+	varCmd.AddCommand(varSetCmd)
+	rootCmd.AddCommand(varCmd)
+}
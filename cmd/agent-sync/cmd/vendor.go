@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/bianoble/agent-sync/internal/engine"
+	"github.com/bianoble/agent-sync/internal/vendored"
+	"github.com/spf13/cobra"
+)
+
+var vendorDryRun bool
+
+var vendorCmd = &cobra.Command{
+	Use:   "vendor",
+	Short: "Copy all locked content into the project's vendor directory",
+	Long: `Writes every object referenced by the lockfile into ` + vendored.Dir + `/,
+a content-addressed store meant to be committed alongside the lockfile, and
+rewrites its index. Objects the lockfile no longer references are removed.
+
+Once vendored, 'sync' reads content from the vendor directory before the user
+cache, so it needs no git or network access. Content is still verified
+against the lockfile hashes on every read.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !vendorDryRun {
+			unlock, err := lockProject(cmd.Context(), "vendor")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+
+		lf, err := loadLockfile()
+		if err != nil {
+			return err
+		}
+		if len(lf.Sources) == 0 {
+			return fmt.Errorf("lockfile %s has no sources — run 'agent-sync update' first", lockfilePath)
+		}
+
+		root, err := projectRoot()
+		if err != nil {
+			return err
+		}
+
+		c, err := newCache()
+		if err != nil {
+			return err
+		}
+
+		eng := &engine.VendorEngine{
+			Registry:    newRegistry(),
+			Cache:       c,
+			ProjectRoot: root,
+		}
+
+		cfg, err := loadLockConfig()
+		if err != nil {
+			return err
+		}
+
+		result, err := eng.Vendor(cmd.Context(), *lf, engine.VendorOptions{Config: cfg, DryRun: vendorDryRun})
+		if err != nil {
+			return err
+		}
+
+		if vendorDryRun {
+			info("Dry run — vendor directory not modified.")
+		}
+
+		for _, f := range result.Added {
+			info("  added    %s", f)
+		}
+		for _, h := range result.Removed {
+			info("  removed  sha256:%s", h)
+		}
+		for _, e := range result.Errors {
+			errorf("%s: %s", e.Source, e.Err)
+		}
+
+		info("")
+		info("Vendor complete: %d added, %d unchanged, %d removed, %d errors.",
+			len(result.Added), result.Unchanged, len(result.Removed), len(result.Errors))
+
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("%d object(s) could not be vendored", len(result.Errors))
+		}
+		return nil
+	},
+}
+
+func init() {
+	vendorCmd.Flags().BoolVar(&vendorDryRun, "dry-run", false, "show what would change without writing the vendor directory")
+	rootCmd.AddCommand(vendorCmd)
+}
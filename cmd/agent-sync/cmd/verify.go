@@ -8,11 +8,15 @@ import (
 )
 
 var verifyCmd = &cobra.Command{
-	Use:   "verify [source-name...]",
-	Short: "Verify the lockfile against upstream sources",
+	Use:     "verify [source-name...]",
+	Aliases: []string{"outdated"},
+	Short:   "Verify the lockfile against upstream sources",
 	Long: `Checks whether upstream sources have changed since the lockfile was last written.
 Reports which sources have newer content available. Does NOT modify the lockfile
-or target files. Exit 0 if all sources match; exit non-zero if changes are available.`,
+or target files. Exit 0 if all sources match; exit non-zero if changes are available.
+
+Pinned sources (see 'agent-sync pin') are listed separately with their pin
+reason; an upstream change to a pinned source does not fail verify.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := loadConfig()
 		if err != nil {
@@ -45,6 +49,13 @@ or target files. Exit 0 if all sources match; exit non-zero if changes are avail
 		for _, d := range result.Changed {
 			info("  ✗ %-20s  %s → %s", d.Source, d.Before, d.After)
 		}
+		for _, d := range result.Pinned {
+			upstream := "upstream unchanged"
+			if d.After != "" {
+				upstream = "upstream at " + d.After
+			}
+			info("  - %-20s  pinned at %s, %s%s", d.Source, d.Before, upstream, pinReasonSuffix(d.PinReason))
+		}
 		for _, e := range result.Errors {
 			errorf("%s: %s", e.Source, e.Err)
 		}
@@ -53,11 +64,16 @@ or target files. Exit 0 if all sources match; exit non-zero if changes are avail
 			return fmt.Errorf("%d source(s) have upstream changes", len(result.Changed)+len(result.Errors))
 		}
 
-		info("\nAll sources match upstream.")
+		if len(result.Pinned) > 0 {
+			info("\nAll unpinned sources match upstream.")
+		} else {
+			info("\nAll sources match upstream.")
+		}
 		return nil
 	},
 }
 
 func init() {
+	addWorkspaceFlag(verifyCmd)
 	rootCmd.AddCommand(verifyCmd)
 }
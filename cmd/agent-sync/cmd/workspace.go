@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/source"
+	"github.com/bianoble/agent-sync/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var workspaceMode bool
+
+// workspaceRegistry, while a command runs across a workspace, is the
+// registry shared by its members; see newRegistry.
+var workspaceRegistry *source.Registry
+
+// addWorkspaceFlag adds --workspace to a command, which then runs once in
+// every member project of the workspace instead of in the current project.
+func addWorkspaceFlag(c *cobra.Command) {
+	run := c.RunE
+	c.Flags().BoolVar(&workspaceMode, "workspace", false, "run in every project of the workspace (see "+workspace.FileName+")")
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		if !workspaceMode {
+			return run(cmd, args)
+		}
+		return runWorkspace(cmd, args, run)
+	}
+}
+
+// memberStatus is the outcome of a command in one workspace member.
+type memberStatus struct {
+	err     error
+	name    string
+	skipped bool
+}
+
+// runWorkspace runs a command in each member of the workspace around the
+// current directory, one after another, and reports how each went. Members
+// share source resolution and git fetches. It fails if any member does.
+func runWorkspace(cmd *cobra.Command, args []string, run func(*cobra.Command, []string) error) error {
+	if cmd.Flags().Changed("config") || cmd.Flags().Changed("lockfile") {
+		return errors.New("--workspace cannot be combined with --config or --lockfile; each member uses its own")
+	}
+	ws, err := workspace.Open(".")
+	if err != nil {
+		return err
+	}
+	if len(ws.Members) == 0 {
+		if ws.Path != "" {
+			return fmt.Errorf("workspace %s has no member projects", ws.Path)
+		}
+		return fmt.Errorf("no %s found below the current directory", workspace.ConfigFile)
+	}
+	if ws.Path != "" {
+		detail("workspace: %s", ws.Path)
+	}
+
+	mirrors := source.NewGitMirrors()
+	reg := newRegistry()
+	if !offlineMode() {
+		reg.Register("git", &source.GitResolver{Mirrors: mirrors})
+	}
+	savedConfig, savedLockfile := configPath, lockfilePath
+	workspaceRegistry = reg.Shared()
+	defer func() {
+		workspaceRegistry = nil
+		configPath, lockfilePath = savedConfig, savedLockfile
+		if err := mirrors.Close(); err != nil {
+			detail("removing git mirrors: %s", err)
+		}
+	}()
+
+	var statuses []memberStatus
+	matched := len(args) == 0
+	for i, m := range ws.Members {
+		configPath, lockfilePath = m.ConfigPath(), m.LockfilePath()
+		if i > 0 {
+			info("")
+		}
+		info("==> %s", m.Name)
+
+		memberArgs := memberSources(args)
+		if len(args) > 0 && len(memberArgs) == 0 {
+			info("No sources named %s; skipped.", strings.Join(args, ", "))
+			statuses = append(statuses, memberStatus{name: m.Name, skipped: true})
+			continue
+		}
+		matched = true
+		err := run(cmd, memberArgs)
+		if err != nil {
+			errorf("%s", err)
+		}
+		statuses = append(statuses, memberStatus{name: m.Name, err: err})
+	}
+	if !matched {
+		return fmt.Errorf("no workspace member defines source(s) %s", strings.Join(args, ", "))
+	}
+
+	return reportWorkspace(statuses, mirrors)
+}
+
+// memberSources returns the named sources that the current member defines.
+// Sources are named only for update and verify, which fail on names they
+// do not know; the rest of the workspace may well define them.
+func memberSources(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	opts := hierarchicalOptions()
+	opts.Written = true
+	result, err := config.LoadHierarchical(opts)
+	if err != nil {
+		return names // let the command report it
+	}
+	defined := make(map[string]bool, len(result.Config.Sources))
+	for _, s := range result.Config.Sources {
+		defined[s.Name] = true
+	}
+	var out []string
+	for _, name := range names {
+		if defined[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// reportWorkspace prints the outcome in each member and fails if any
+// member failed.
+func reportWorkspace(statuses []memberStatus, mirrors *source.GitMirrors) error {
+	width := 0
+	for _, s := range statuses {
+		width = max(width, len(s.name))
+	}
+	var failed []string
+	info("")
+	info("Workspace:")
+	for _, s := range statuses {
+		switch {
+		case s.skipped:
+			info("  - %-*s  skipped", width, s.name)
+		case s.err != nil:
+			info("  ✗ %-*s  %s", width, s.name, firstLine(s.err.Error()))
+			failed = append(failed, s.name)
+		default:
+			info("  ✓ %-*s  ok", width, s.name)
+		}
+	}
+	if n := mirrors.Fetched(); n > 0 {
+		detail("fetched %d git repo(s) once for %d member(s)", n, len(statuses))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d workspace member(s) failed: %s", len(failed), len(statuses), strings.Join(failed, ", "))
+	}
+	info("\nAll %d member(s) succeeded.", len(statuses))
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
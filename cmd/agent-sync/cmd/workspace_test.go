@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bianoble/agent-sync/internal/source"
+)
+
+func TestReportWorkspace(t *testing.T) {
+	quiet = true
+	defer func() { quiet = false }()
+	mirrors := source.NewGitMirrors()
+
+	err := reportWorkspace([]memberStatus{
+		{name: "services/api"},
+		{name: "services/web", err: errors.New("check failed: 1 file(s) out of sync")},
+		{name: "tools/cli", skipped: true},
+		{name: "tools/gen", err: errors.New("loading config: no such file")},
+	}, mirrors)
+	want := "2 of 4 workspace member(s) failed: services/web, tools/gen"
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+
+	if err := reportWorkspace([]memberStatus{{name: "a"}, {name: "b", skipped: true}}, mirrors); err != nil {
+		t.Errorf("got %v, want success", err)
+	}
+}
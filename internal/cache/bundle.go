@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BundleManifestName is the tar entry holding the bundle manifest.
+// It is always the first entry of a bundle.
+const BundleManifestName = "manifest.yaml"
+
+// maxBundleManifestSize bounds how much of a bundle is read as the manifest.
+const maxBundleManifestSize = 16 << 20
+
+// BundleManifest lists the objects packed into a bundle.
+type BundleManifest struct {
+	Objects []BundleObject `yaml:"objects"`
+	Version int            `yaml:"version"`
+}
+
+// BundleObject describes a single object in a bundle.
+type BundleObject struct {
+	SHA256 string `yaml:"sha256"`
+	Size   int64  `yaml:"size"`
+}
+
+// MissingObjectsError reports hashes that were requested but are not cached.
+type MissingObjectsError struct {
+	Hashes []string
+}
+
+func (e *MissingObjectsError) Error() string {
+	return fmt.Sprintf("%d object(s) not in cache: %s", len(e.Hashes), strings.Join(e.Hashes, ", "))
+}
+
+// ImportResult holds the outcome of importing a bundle.
+type ImportResult struct {
+	Imported []string // hashes newly added to the cache
+	Existing int      // objects that were already cached
+}
+
+// Export writes a tar bundle containing a manifest and exactly the given
+// objects. Duplicate hashes are packed once. The output is deterministic for
+// a given set of hashes. If any object is not cached, nothing is written and
+// a *MissingObjectsError lists every missing hash.
+func (c *Cache) Export(w io.Writer, hashes []string) (*BundleManifest, error) {
+	unique := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		unique[h] = true
+	}
+	sorted := make([]string, 0, len(unique))
+	for h := range unique {
+		sorted = append(sorted, h)
+	}
+	sort.Strings(sorted)
+
+	manifest := &BundleManifest{Version: 1}
+	contents := make([][]byte, 0, len(sorted))
+	var missing []string
+	for _, h := range sorted {
+		data, found, err := c.Get(h)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			missing = append(missing, h)
+			continue
+		}
+		manifest.Objects = append(manifest.Objects, BundleObject{SHA256: h, Size: int64(len(data))})
+		contents = append(contents, data)
+	}
+	if len(missing) > 0 {
+		return nil, &MissingObjectsError{Hashes: missing}
+	}
+
+	manifestData, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling bundle manifest: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeTarEntry(tw, BundleManifestName, manifestData); err != nil {
+		return nil, err
+	}
+	for i, obj := range manifest.Objects {
+		if err := writeTarEntry(tw, bundleObjectName(obj.SHA256), contents[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("finishing bundle: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// Import reads a bundle produced by Export. Every object is verified against
+// its hash and the manifest before anything is inserted, so a corrupt or
+// incomplete bundle leaves the cache untouched.
+func (c *Cache) Import(r io.Reader) (*ImportResult, error) {
+	tr := tar.NewReader(r)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle: %w", err)
+	}
+	if hdr.Name != BundleManifestName {
+		return nil, fmt.Errorf("invalid bundle: first entry is %q, expected %s", hdr.Name, BundleManifestName)
+	}
+	manifestData, err := io.ReadAll(io.LimitReader(tr, maxBundleManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle manifest: %w", err)
+	}
+	var manifest BundleManifest
+	if err := yaml.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing bundle manifest: %w", err)
+	}
+	if manifest.Version != 1 {
+		return nil, fmt.Errorf("unsupported bundle version %d — only version 1 is supported", manifest.Version)
+	}
+
+	expected := make(map[string]int64, len(manifest.Objects))
+	for _, obj := range manifest.Objects {
+		expected[obj.SHA256] = obj.Size
+	}
+
+	objects := make(map[string][]byte, len(expected))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("invalid bundle: unexpected entry %q", hdr.Name)
+		}
+
+		hash := path.Base(hdr.Name)
+		size, listed := expected[hash]
+		if !listed || hdr.Name != bundleObjectName(hash) {
+			return nil, fmt.Errorf("invalid bundle: entry %q is not listed in the manifest", hdr.Name)
+		}
+		if hdr.Size != size {
+			return nil, fmt.Errorf("invalid bundle: object %s is %d bytes, manifest says %d", hash, hdr.Size, size)
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, size))
+		if err != nil {
+			return nil, fmt.Errorf("reading bundle object %s: %w", hash, err)
+		}
+		if actual := computeHash(data); actual != hash {
+			return nil, fmt.Errorf("invalid bundle: object %s has content hash %s", hash, actual)
+		}
+		objects[hash] = data
+	}
+
+	var absent []string
+	for _, obj := range manifest.Objects {
+		if _, ok := objects[obj.SHA256]; !ok {
+			absent = append(absent, obj.SHA256)
+		}
+	}
+	if len(absent) > 0 {
+		return nil, fmt.Errorf("invalid bundle: %d object(s) listed in the manifest are missing: %s", len(absent), strings.Join(absent, ", "))
+	}
+
+	result := &ImportResult{}
+	for _, obj := range manifest.Objects {
+		if c.Has(obj.SHA256) {
+			result.Existing++
+			continue
+		}
+		if err := c.Put(obj.SHA256, objects[obj.SHA256]); err != nil {
+			return result, err
+		}
+		result.Imported = append(result.Imported, obj.SHA256)
+	}
+
+	return result, nil
+}
+
+func bundleObjectName(hash string) string {
+	if len(hash) < 2 {
+		return "objects/" + hash
+	}
+	return "objects/" + hash[:2] + "/" + hash
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Unix(0, 0).UTC(),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("writing bundle entry %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("writing bundle entry %s: %w", name, err)
+	}
+	return nil
+}
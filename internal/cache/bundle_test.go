@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func putAll(t *testing.T, c *Cache, contents ...string) []string {
+	t.Helper()
+	var hashes []string
+	for _, s := range contents {
+		h := ComputeHash([]byte(s))
+		if err := c.Put(h, []byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src, _ := New(t.TempDir())
+	hashes := putAll(t, src, "alpha", "beta", "unrelated")
+
+	var buf bytes.Buffer
+	manifest, err := src.Export(&buf, []string{hashes[1], hashes[0], hashes[0]})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(manifest.Objects) != 2 {
+		t.Fatalf("manifest objects = %d, want 2", len(manifest.Objects))
+	}
+
+	// Export is deterministic.
+	var again bytes.Buffer
+	if _, err := src.Export(&again, []string{hashes[0], hashes[1]}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("exporting the same objects should produce identical bundles")
+	}
+
+	dst, _ := New(t.TempDir())
+	putAll(t, dst, "alpha")
+	result, err := dst.Import(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(result.Imported) != 1 || result.Imported[0] != hashes[1] {
+		t.Errorf("imported = %v, want [%s]", result.Imported, hashes[1])
+	}
+	if result.Existing != 1 {
+		t.Errorf("existing = %d, want 1", result.Existing)
+	}
+	if dst.Has(hashes[2]) {
+		t.Error("unrelated object should not be exported")
+	}
+}
+
+func TestExportMissingObjects(t *testing.T) {
+	c, _ := New(t.TempDir())
+	hashes := putAll(t, c, "present")
+
+	var buf bytes.Buffer
+	_, err := c.Export(&buf, []string{hashes[0], "deadbeef"})
+	var missing *MissingObjectsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("error = %v, want MissingObjectsError", err)
+	}
+	if len(missing.Hashes) != 1 || missing.Hashes[0] != "deadbeef" {
+		t.Errorf("missing = %v", missing.Hashes)
+	}
+	if buf.Len() != 0 {
+		t.Error("nothing should be written when objects are missing")
+	}
+}
+
+func TestImportRejectsCorruptObject(t *testing.T) {
+	good := []byte("good")
+	goodHash := ComputeHash(good)
+	evilHash := ComputeHash([]byte("expected"))
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	manifest := "version: 1\nobjects:\n  - sha256: " + goodHash + "\n    size: 4\n  - sha256: " + evilHash + "\n    size: 8\n"
+	for _, e := range []struct {
+		name string
+		data []byte
+	}{
+		{BundleManifestName, []byte(manifest)},
+		{bundleObjectName(goodHash), good},
+		{bundleObjectName(evilHash), []byte("tampered")},
+	} {
+		if err := writeTarEntry(tw, e.name, e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := New(t.TempDir())
+	_, err := c.Import(&buf)
+	if err == nil || !strings.Contains(err.Error(), "content hash") {
+		t.Fatalf("expected hash verification error, got %v", err)
+	}
+	if c.Has(goodHash) {
+		t.Error("no object should be inserted from a bundle that fails verification")
+	}
+}
+
+func TestImportRejectsUnlistedEntry(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	data := []byte("sneaky")
+	if err := writeTarEntry(tw, BundleManifestName, []byte("version: 1\nobjects: []\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTarEntry(tw, "../../etc/"+ComputeHash(data), data); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := New(t.TempDir())
+	if _, err := c.Import(&buf); err == nil {
+		t.Fatal("expected error for entry not listed in the manifest")
+	}
+}
+
+func TestImportRequiresManifestFirst(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := writeTarEntry(tw, "objects/aa/aa", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := New(t.TempDir())
+	_, err := c.Import(&buf)
+	if err == nil || !strings.Contains(err.Error(), BundleManifestName) {
+		t.Fatalf("expected manifest error, got %v", err)
+	}
+}
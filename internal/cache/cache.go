@@ -5,15 +5,20 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
+	"time"
 )
 
 // Cache provides content-addressed file storage.
 // Files are stored by their SHA256 hash and verified on retrieval.
 type Cache struct {
-	dir string
+	remote *Remote
+	dir    string
 }
 
 // New creates a Cache at the given directory.
@@ -42,6 +47,13 @@ func DefaultDir() string {
 	return filepath.Join(home, ".cache", "agent-sync")
 }
 
+// SetRemote attaches a shared remote cache. Get reads through to it on a
+// local miss and Put writes newly stored objects back to it. Pass nil to
+// detach.
+func (c *Cache) SetRemote(r *Remote) {
+	c.remote = r
+}
+
 // Get retrieves a cached file by its SHA256 hash.
 // Returns the content and true if found and verified.
 // Returns nil, false if not cached.
@@ -50,7 +62,7 @@ func (c *Cache) Get(hash string) ([]byte, bool, error) {
 	path := c.objectPath(hash)
 	data, err := os.ReadFile(path)
 	if errors.Is(err, os.ErrNotExist) {
-		return nil, false, nil
+		return c.getRemote(hash)
 	}
 	if err != nil {
 		return nil, false, fmt.Errorf("reading cache entry %s: %w", hash, err)
@@ -61,9 +73,10 @@ func (c *Cache) Get(hash string) ([]byte, bool, error) {
 	if actual != hash {
 		// Self-healing: remove corrupt entry.
 		_ = os.Remove(path)
-		return nil, false, nil
+		return c.getRemote(hash)
 	}
 
+	c.touch(path)
 	return data, true, nil
 }
 
@@ -81,9 +94,44 @@ func (c *Cache) Put(hash string, content []byte) error {
 
 	// Already cached — immutable, no overwrite needed.
 	if _, err := os.Stat(path); err == nil {
+		c.touch(path)
 		return nil
 	}
 
+	if err := c.store(path, content); err != nil {
+		return err
+	}
+
+	if c.remote != nil {
+		if err := c.remote.Put(hash, content); err != nil {
+			c.remote.reportError(err)
+		}
+	}
+	return nil
+}
+
+// getRemote reads an object through from the remote cache, storing it
+// locally. Remote failures are reported and treated as a miss.
+func (c *Cache) getRemote(hash string) ([]byte, bool, error) {
+	if c.remote == nil {
+		return nil, false, nil
+	}
+	data, found, err := c.remote.Get(hash)
+	if err != nil {
+		c.remote.reportError(err)
+		return nil, false, nil
+	}
+	if !found {
+		return nil, false, nil
+	}
+	if err := c.store(c.objectPath(hash), data); err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// store atomically writes verified content to path.
+func (c *Cache) store(path string, content []byte) error {
 	// Create subdirectory.
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -144,6 +192,48 @@ func (c *Cache) Size() (int64, error) {
 	return total, err
 }
 
+// Object describes a single stored cache entry.
+type Object struct {
+	ModTime time.Time
+	Hash    string
+	Size    int64
+}
+
+// Objects lists every stored object, sorted by hash.
+// Temp files left behind by interrupted writes are ignored.
+func (c *Cache) Objects() ([]Object, error) {
+	var objects []Object
+	root := filepath.Join(c.dir, "objects")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Hash: d.Name(), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing cache objects: %w", err)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Hash < objects[j].Hash })
+	return objects, nil
+}
+
+// Remove deletes a cached object. Removing an absent object is not an error.
+func (c *Cache) Remove(hash string) error {
+	err := os.Remove(c.objectPath(hash))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing cache entry %s: %w", hash, err)
+	}
+	return nil
+}
+
 // Path returns the cache directory path.
 func (c *Cache) Path() string {
 	return c.dir
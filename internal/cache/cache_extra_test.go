@@ -125,3 +125,49 @@ func TestSizeWalkError(t *testing.T) {
 		t.Fatal("expected error when cache dir is removed")
 	}
 }
+
+func TestObjectsAndRemove(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hashes []string
+	for _, content := range []string{"one", "two"} {
+		hash := ComputeHash([]byte(content))
+		if putErr := c.Put(hash, []byte(content)); putErr != nil {
+			t.Fatal(putErr)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	// A leftover temp file must not be listed.
+	tmp := filepath.Join(c.Path(), "objects", hashes[0][:2], ".tmp-123")
+	if err := os.WriteFile(tmp, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := c.Objects()
+	if err != nil {
+		t.Fatalf("Objects: %v", err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("objects = %d, want 2", len(objects))
+	}
+	if objects[0].Hash > objects[1].Hash {
+		t.Error("objects should be sorted by hash")
+	}
+	if objects[0].Size != 3 {
+		t.Errorf("size = %d, want 3", objects[0].Size)
+	}
+
+	if err := c.Remove(hashes[0]); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if c.Has(hashes[0]) {
+		t.Error("object should be gone after Remove")
+	}
+	if err := c.Remove(hashes[0]); err != nil {
+		t.Errorf("removing an absent object should not error: %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/flock"
+	"gopkg.in/yaml.v3"
+)
+
+// projectsFile is the cache index of lockfiles whose objects gc must keep.
+const projectsFile = "projects.yaml"
+
+// lockFile is the advisory lock serializing changes to the index and
+// evictions across processes sharing the cache.
+const lockFile = "lock"
+
+// lockWait is how long to wait for another process holding the cache lock.
+// Index updates and evictions are short, so a holder past this is stuck.
+const lockWait = 30 * time.Second
+
+// Project records a lockfile that synced from this cache.
+type Project struct {
+	LastUsed time.Time `yaml:"last_used"`
+	Lockfile string    `yaml:"lockfile"`
+}
+
+type projectIndex struct {
+	Projects []Project `yaml:"projects"`
+}
+
+// VerifyReport holds the outcome of an integrity sweep.
+type VerifyReport struct {
+	Corrupt []string // hashes of removed entries
+	Checked int
+}
+
+// GCResult holds the outcome of a garbage collection.
+type GCResult struct {
+	Removed   []Object
+	Forgotten []string // registered lockfiles dropped from the index
+	Freed     int64
+	Remaining int64 // total size of objects left in the cache
+}
+
+// Referenced returns the objects that the registered lockfiles reference,
+// and those of the lockfiles that no longer exist.
+type Referenced func(projects []Project) (keep map[string]bool, stale []string, err error)
+
+// RegisterLockfile records a lockfile in the cache index so that gc keeps
+// the objects it references. Re-registering refreshes its last-used time.
+func (c *Cache) RegisterLockfile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("resolving lockfile path: %w", err)
+	}
+
+	unlock, err := c.lock("register")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	projects, err := c.Projects()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	found := false
+	for i := range projects {
+		if projects[i].Lockfile == abs {
+			projects[i].LastUsed = now
+			found = true
+		}
+	}
+	if !found {
+		projects = append(projects, Project{Lockfile: abs, LastUsed: now})
+	}
+
+	return c.saveProjects(projects)
+}
+
+// Projects returns the lockfiles registered in the cache index, sorted by path.
+func (c *Cache) Projects() ([]Project, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, projectsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cache index: %w", err)
+	}
+
+	var idx projectIndex
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parsing cache index: %w", err)
+	}
+	sort.Slice(idx.Projects, func(i, j int) bool { return idx.Projects[i].Lockfile < idx.Projects[j].Lockfile })
+	return idx.Projects, nil
+}
+
+// ForgetLockfiles removes lockfiles from the cache index.
+func (c *Cache) ForgetLockfiles(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	unlock, err := c.lock("gc")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return c.forget(paths)
+}
+
+// forget removes lockfiles from the cache index. The caller holds the
+// cache lock.
+func (c *Cache) forget(paths []string) error {
+	drop := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		drop[p] = true
+	}
+	projects, err := c.Projects()
+	if err != nil {
+		return err
+	}
+	kept := projects[:0]
+	for _, p := range projects {
+		if !drop[p.Lockfile] {
+			kept = append(kept, p)
+		}
+	}
+	return c.saveProjects(kept)
+}
+
+// lock takes the cache lock, waiting for another process holding it. The
+// returned function releases it.
+func (c *Cache) lock(operation string) (func(), error) {
+	holder := fmt.Sprintf("%s (pid %d)", operation, os.Getpid())
+	path := filepath.Join(c.dir, lockFile)
+	l, err := flock.Acquire(context.Background(), path, holder, lockWait)
+	if errors.Is(err, flock.ErrLocked) {
+		if h := flock.Holder(path); h != "" {
+			return nil, fmt.Errorf("another agent-sync operation (%s) holds the cache lock %s", h, path)
+		}
+		return nil, fmt.Errorf("another agent-sync operation holds the cache lock %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("locking cache: %w", err)
+	}
+	return func() { _ = l.Unlock() }, nil
+}
+
+// saveProjects replaces the index through a temp file, so that readers
+// never see a partial write. The caller holds the cache lock.
+func (c *Cache) saveProjects(projects []Project) error {
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Lockfile < projects[j].Lockfile })
+	data, err := yaml.Marshal(projectIndex{Projects: projects})
+	if err != nil {
+		return fmt.Errorf("marshaling cache index: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".projects-*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing cache index: %w", err)
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("writing cache index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("writing cache index: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(c.dir, projectsFile)); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("writing cache index: %w", err)
+	}
+	return nil
+}
+
+// Verify rehashes every object and removes entries whose content no longer
+// matches their hash.
+func (c *Cache) Verify() (*VerifyReport, error) {
+	objects, err := c.Objects()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{}
+	for _, obj := range objects {
+		data, err := os.ReadFile(c.objectPath(obj.Hash))
+		if err != nil {
+			return report, fmt.Errorf("reading cache entry %s: %w", obj.Hash, err)
+		}
+		report.Checked++
+		if computeHash(data) == obj.Hash {
+			continue
+		}
+		if err := c.Remove(obj.Hash); err != nil {
+			return report, err
+		}
+		report.Corrupt = append(report.Corrupt, obj.Hash)
+	}
+	return report, nil
+}
+
+// GC evicts objects that referenced does not keep, least recently used
+// first, and drops the lockfiles it reports stale from the index. It calls
+// referenced with the cache lock held, so that a lockfile registered
+// meanwhile cannot lose its objects. With maxSize > 0, eviction stops once
+// the cache's objects fit within maxSize bytes; with maxSize <= 0 every
+// unreferenced object is evicted. Kept objects are never evicted, even if
+// they alone exceed maxSize.
+func (c *Cache) GC(referenced Referenced, maxSize int64, dryRun bool) (*GCResult, error) {
+	unlock, err := c.lock("gc")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	projects, err := c.Projects()
+	if err != nil {
+		return nil, err
+	}
+	keep, stale, err := referenced(projects)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := c.Objects()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GCResult{Forgotten: stale}
+	var candidates []Object
+	for _, obj := range objects {
+		result.Remaining += obj.Size
+		if !keep[obj.Hash] {
+			candidates = append(candidates, obj)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].ModTime.Before(candidates[j].ModTime)
+	})
+
+	for _, obj := range candidates {
+		if maxSize > 0 && result.Remaining <= maxSize {
+			break
+		}
+		if !dryRun {
+			if err := c.Remove(obj.Hash); err != nil {
+				return result, err
+			}
+		}
+		result.Removed = append(result.Removed, obj)
+		result.Freed += obj.Size
+		result.Remaining -= obj.Size
+	}
+
+	if !dryRun && len(stale) > 0 {
+		if err := c.forget(stale); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Clear removes every cached object. The lockfile index is kept.
+func (c *Cache) Clear() error {
+	unlock, err := c.lock("clear")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	objDir := filepath.Join(c.dir, "objects")
+	if err := os.RemoveAll(objDir); err != nil {
+		return fmt.Errorf("clearing cache: %w", err)
+	}
+	if err := os.MkdirAll(objDir, 0755); err != nil {
+		return fmt.Errorf("clearing cache: %w", err)
+	}
+	return nil
+}
+
+// touch records that an object was just used, for least-recently-used eviction.
+func (c *Cache) touch(path string) {
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
@@ -0,0 +1,251 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegisterLockfile(t *testing.T) {
+	c, _ := New(t.TempDir())
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a", "agent-sync.lock")
+	b := filepath.Join(dir, "b", "agent-sync.lock")
+
+	for _, p := range []string{b, a, a} {
+		if err := c.RegisterLockfile(p); err != nil {
+			t.Fatalf("RegisterLockfile: %v", err)
+		}
+	}
+
+	projects, err := c.Projects()
+	if err != nil {
+		t.Fatalf("Projects: %v", err)
+	}
+	if len(projects) != 2 {
+		t.Fatalf("projects = %d, want 2 (re-registering should not duplicate)", len(projects))
+	}
+	if projects[0].Lockfile != a || projects[1].Lockfile != b {
+		t.Errorf("projects = %+v, want sorted [a b]", projects)
+	}
+	if projects[0].LastUsed.IsZero() {
+		t.Error("last used time should be recorded")
+	}
+
+	if err := c.ForgetLockfiles([]string{a}); err != nil {
+		t.Fatalf("ForgetLockfiles: %v", err)
+	}
+	projects, _ = c.Projects()
+	if len(projects) != 1 || projects[0].Lockfile != b {
+		t.Errorf("after forget = %+v, want [b]", projects)
+	}
+}
+
+func TestRegisterLockfileConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	lockfiles := t.TempDir()
+
+	// Separate Cache values stand in for separate processes sharing dir.
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := New(dir)
+			if err == nil {
+				err = c.RegisterLockfile(filepath.Join(lockfiles, fmt.Sprint(i), "agent-sync.lock"))
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("RegisterLockfile: %v", err)
+		}
+	}
+
+	c, _ := New(dir)
+	projects, err := c.Projects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != n {
+		t.Errorf("projects = %d, want %d: concurrent registrations were lost", len(projects), n)
+	}
+}
+
+func TestVerifyRemovesCorrupt(t *testing.T) {
+	c, _ := New(t.TempDir())
+	hashes := putAll(t, c, "fine", "doomed")
+
+	if err := os.WriteFile(c.objectPath(hashes[1]), []byte("bit rot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if report.Checked != 2 {
+		t.Errorf("checked = %d, want 2", report.Checked)
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0] != hashes[1] {
+		t.Errorf("corrupt = %v, want [%s]", report.Corrupt, hashes[1])
+	}
+	if c.Has(hashes[1]) {
+		t.Error("corrupt object should be removed")
+	}
+	if !c.Has(hashes[0]) {
+		t.Error("valid object should be kept")
+	}
+}
+
+func TestGCEvictsUnreferencedLRUFirst(t *testing.T) {
+	c, _ := New(t.TempDir())
+	hashes := putAll(t, c, "kept-kept", "oldest-1", "newest-1")
+
+	// Age the objects so LRU order is well defined.
+	base := time.Now().Add(-time.Hour)
+	for i, h := range hashes {
+		ts := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(c.objectPath(h), ts, ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keep := map[string]bool{hashes[0]: true}
+
+	// Cap of 17 bytes: total is 25, evicting the oldest unreferenced (8 bytes) suffices.
+	result, err := c.GC(keeping(keep), 17, false)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].Hash != hashes[1] {
+		t.Fatalf("removed = %+v, want only the oldest unreferenced object", result.Removed)
+	}
+	if result.Freed != 8 || result.Remaining != 17 {
+		t.Errorf("freed=%d remaining=%d, want 8 and 17", result.Freed, result.Remaining)
+	}
+
+	// No cap: every unreferenced object goes, referenced ones stay.
+	result, err = c.GC(keeping(keep), 0, false)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].Hash != hashes[2] {
+		t.Errorf("removed = %+v, want the remaining unreferenced object", result.Removed)
+	}
+	if !c.Has(hashes[0]) {
+		t.Error("referenced object must never be evicted")
+	}
+}
+
+func TestGCDryRun(t *testing.T) {
+	c, _ := New(t.TempDir())
+	hashes := putAll(t, c, "unreferenced")
+
+	result, err := c.GC(keeping(nil), 0, true)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if len(result.Removed) != 1 {
+		t.Errorf("removed = %d, want 1", len(result.Removed))
+	}
+	if !c.Has(hashes[0]) {
+		t.Error("dry run should not remove objects")
+	}
+}
+
+func TestGCForgetsStaleLockfilesUnderLock(t *testing.T) {
+	c, _ := New(t.TempDir())
+	hashes := putAll(t, c, "referenced", "unreferenced")
+	gone := filepath.Join(t.TempDir(), "agent-sync.lock")
+	if err := c.RegisterLockfile(gone); err != nil {
+		t.Fatal(err)
+	}
+
+	registered := make(chan error, 1)
+	result, err := c.GC(func(projects []Project) (map[string]bool, []string, error) {
+		if len(projects) != 1 || projects[0].Lockfile != gone {
+			t.Errorf("projects = %+v, want the registered lockfile", projects)
+		}
+		// The index is locked: a sync registering its lockfile now must wait.
+		go func() { registered <- c.RegisterLockfile(filepath.Join(t.TempDir(), "other.lock")) }()
+		select {
+		case err := <-registered:
+			t.Errorf("RegisterLockfile ran during GC")
+			registered <- err
+		case <-time.After(100 * time.Millisecond):
+		}
+		return map[string]bool{hashes[0]: true}, []string{gone}, nil
+	}, 0, false)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if err := <-registered; err != nil {
+		t.Errorf("RegisterLockfile after GC: %v", err)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].Hash != hashes[1] {
+		t.Errorf("removed = %+v, want the unreferenced object", result.Removed)
+	}
+	if len(result.Forgotten) != 1 || result.Forgotten[0] != gone {
+		t.Errorf("forgotten = %v, want [%s]", result.Forgotten, gone)
+	}
+	projects, _ := c.Projects()
+	for _, p := range projects {
+		if p.Lockfile == gone {
+			t.Errorf("stale lockfile still registered: %+v", projects)
+		}
+	}
+}
+
+func keeping(keep map[string]bool) Referenced {
+	return func([]Project) (map[string]bool, []string, error) { return keep, nil, nil }
+}
+
+func TestGetRefreshesLastUsed(t *testing.T) {
+	c, _ := New(t.TempDir())
+	hashes := putAll(t, c, "used")
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(c.objectPath(hashes[0]), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Get(hashes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, _ := c.Objects()
+	if !objects[0].ModTime.After(old.Add(time.Hour)) {
+		t.Errorf("Get should refresh last-used time, got %v", objects[0].ModTime)
+	}
+}
+
+func TestClear(t *testing.T) {
+	c, _ := New(t.TempDir())
+	putAll(t, c, "a", "b")
+	if err := c.RegisterLockfile(filepath.Join(t.TempDir(), "agent-sync.lock")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	objects, err := c.Objects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("objects = %d after clear, want 0", len(objects))
+	}
+	projects, _ := c.Projects()
+	if len(projects) != 1 {
+		t.Error("clear should keep the lockfile index")
+	}
+}
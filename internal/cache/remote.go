@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxObjectSize bounds the size of a single object exchanged with a remote cache.
+const maxObjectSize = 512 << 20
+
+// Remote is a client for a shared HTTP cache that stores objects under
+// /objects/<sha256> (GET to read, PUT to write).
+type Remote struct {
+	// Client performs the requests. Defaults to a client with a 30s timeout.
+	Client *http.Client
+
+	// OnError, if set, is called when a remote operation fails. Remote
+	// failures never fail the local operation; the remote is an optimization.
+	OnError func(error)
+
+	baseURL string
+}
+
+// NewRemote returns a client for the remote cache at baseURL.
+func NewRemote(baseURL string) (*Remote, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("remote cache must be an http:// or https:// URL, got %q", baseURL)
+	}
+	return &Remote{
+		Client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// Get downloads an object. Returns nil, false if the remote does not have it.
+// Content whose hash does not match is rejected with an error.
+func (r *Remote) Get(hash string) ([]byte, bool, error) {
+	if !validHash(hash) {
+		return nil, false, fmt.Errorf("remote cache: invalid object hash %q", hash)
+	}
+	resp, err := r.Client.Get(r.objectURL(hash))
+	if err != nil {
+		return nil, false, fmt.Errorf("remote cache: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("remote cache: GET %s: %s", hash, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxObjectSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("remote cache: reading %s: %w", hash, err)
+	}
+	if len(data) > maxObjectSize {
+		return nil, false, fmt.Errorf("remote cache: object %s exceeds %d bytes", hash, maxObjectSize)
+	}
+	if actual := computeHash(data); actual != hash {
+		return nil, false, fmt.Errorf("remote cache: object %s has content hash %s", hash, actual)
+	}
+	return data, true, nil
+}
+
+// Put uploads an object.
+func (r *Remote) Put(hash string, content []byte) error {
+	if !validHash(hash) {
+		return fmt.Errorf("remote cache: invalid object hash %q", hash)
+	}
+	req, err := http.NewRequest(http.MethodPut, r.objectURL(hash), bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("remote cache: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote cache: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("remote cache: PUT %s: %s", hash, resp.Status)
+	}
+	return nil
+}
+
+// URL returns the remote cache base URL.
+func (r *Remote) URL() string {
+	return r.baseURL
+}
+
+func (r *Remote) objectURL(hash string) string {
+	return r.baseURL + "/objects/" + hash
+}
+
+func (r *Remote) reportError(err error) {
+	if r.OnError != nil {
+		r.OnError(err)
+	}
+}
+
+// validHash reports whether s is a lowercase hex SHA256 digest.
+func validHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, ch := range s {
+		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newRemotePair starts an in-process cache server and returns its backing
+// cache plus a client cache that uses it as a remote.
+func newRemotePair(t *testing.T, readOnly bool) (server, client *Cache, remote *Remote) {
+	t.Helper()
+	server, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(&Server{Cache: server, ReadOnly: readOnly})
+	t.Cleanup(ts.Close)
+
+	client, err = New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err = NewRemote(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetRemote(remote)
+	return server, client, remote
+}
+
+func TestRemoteReadThrough(t *testing.T) {
+	server, client, _ := newRemotePair(t, false)
+
+	content := []byte("shared rules")
+	hash := ComputeHash(content)
+	if err := server.Put(hash, content); err != nil {
+		t.Fatal(err)
+	}
+
+	got, found, err := client.Get(hash)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !found || !bytes.Equal(got, content) {
+		t.Fatalf("expected read-through hit, got found=%v content=%q", found, got)
+	}
+
+	// The object is now stored locally.
+	client.SetRemote(nil)
+	if !client.Has(hash) {
+		t.Error("read-through object should be stored in the local cache")
+	}
+}
+
+func TestRemoteWriteBack(t *testing.T) {
+	server, client, _ := newRemotePair(t, false)
+
+	content := []byte("fetched from git")
+	hash := ComputeHash(content)
+	if err := client.Put(hash, content); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, found, err := server.Get(hash)
+	if err != nil || !found || !bytes.Equal(got, content) {
+		t.Fatalf("expected object on server, got found=%v err=%v", found, err)
+	}
+}
+
+func TestRemoteMissAndReadOnly(t *testing.T) {
+	server, client, remote := newRemotePair(t, true)
+	var errs []error
+	remote.OnError = func(err error) { errs = append(errs, err) }
+
+	hash := ComputeHash([]byte("nowhere"))
+	if _, found, err := client.Get(hash); err != nil || found {
+		t.Fatalf("expected clean miss, got found=%v err=%v", found, err)
+	}
+
+	// Write-back to a read-only server fails, but the local put succeeds.
+	content := []byte("local only")
+	h := ComputeHash(content)
+	if err := client.Put(h, content); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if !client.Has(h) {
+		t.Error("local put should succeed despite remote failure")
+	}
+	if server.Has(h) {
+		t.Error("read-only server should not store uploads")
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "405") {
+		t.Errorf("expected one 405 error reported, got %v", errs)
+	}
+}
+
+func TestRemoteRejectsWrongContent(t *testing.T) {
+	// A misbehaving server that returns the wrong bytes for every object.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tampered"))
+	}))
+	defer ts.Close()
+
+	client, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := NewRemote(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []error
+	remote.OnError = func(err error) { errs = append(errs, err) }
+	client.SetRemote(remote)
+
+	hash := ComputeHash([]byte("genuine"))
+	if _, found, err := client.Get(hash); err != nil || found {
+		t.Fatalf("tampered content must be treated as a miss, got found=%v err=%v", found, err)
+	}
+	if client.Has(hash) {
+		t.Error("tampered content must not be stored locally")
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "content hash") {
+		t.Errorf("expected hash mismatch reported, got %v", errs)
+	}
+}
+
+func TestServerValidatesRequests(t *testing.T) {
+	c, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(&Server{Cache: c})
+	defer ts.Close()
+
+	content := []byte("payload")
+	hash := ComputeHash(content)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"bad hash", http.MethodGet, "/objects/../../etc/passwd", "", http.StatusNotFound},
+		{"unknown path", http.MethodGet, "/other/" + hash, "", http.StatusNotFound},
+		{"missing object", http.MethodGet, "/objects/" + hash, "", http.StatusNotFound},
+		{"hash mismatch", http.MethodPut, "/objects/" + hash, "other", http.StatusBadRequest},
+		{"upload", http.MethodPut, "/objects/" + hash, "payload", http.StatusCreated},
+		{"re-upload", http.MethodPut, "/objects/" + hash, "payload", http.StatusNoContent},
+		{"head", http.MethodHead, "/objects/" + hash, "", http.StatusOK},
+		{"delete", http.MethodDelete, "/objects/" + hash, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	if got, found, _ := c.Get(hash); !found || !bytes.Equal(got, content) {
+		t.Error("uploaded object should be stored")
+	}
+}
+
+func TestNewRemoteRejectsBadURL(t *testing.T) {
+	for _, u := range []string{"", "ftp://cache", "cache.internal", "https://"} {
+		if _, err := NewRemote(u); err == nil {
+			t.Errorf("NewRemote(%q) should fail", u)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Server serves a cache over HTTP for use as a remote cache:
+//
+//	GET  /objects/<sha256>  returns the object, or 404 if absent
+//	HEAD /objects/<sha256>  reports whether the object exists
+//	PUT  /objects/<sha256>  stores the request body after verifying its hash
+type Server struct {
+	Cache *Cache
+
+	// ReadOnly rejects uploads with 405 Method Not Allowed.
+	ReadOnly bool
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hash, ok := strings.CutPrefix(r.URL.Path, "/objects/")
+	if !ok || !validHash(hash) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.serveObject(w, r, hash)
+	case http.MethodPut:
+		if s.ReadOnly {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "cache is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		s.storeObject(w, r, hash)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, hash string) {
+	data, found, err := s.Cache.Get(hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(data)
+}
+
+func (s *Server) storeObject(w http.ResponseWriter, r *http.Request, hash string) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxObjectSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("reading object: %s", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if actual := computeHash(data); actual != hash {
+		http.Error(w, fmt.Sprintf("content hash %s does not match %s", actual, hash), http.StatusBadRequest)
+		return
+	}
+	if s.Cache.Has(hash) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := s.Cache.Put(hash, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
@@ -0,0 +1,144 @@
+// Package changelog turns the commits between two locked versions of a git
+// source into a changelog, grouping Conventional Commits
+// (https://www.conventionalcommits.org) by type.
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/source"
+)
+
+// Changelog lists the changes to one source between two commits.
+type Changelog struct {
+	Source string  `json:"source"`
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Groups []Group `json:"groups"`
+}
+
+// Group holds the entries of one commit type, newest first.
+type Group struct {
+	Type    string  `json:"type"` // conventional type, or "" for other commits
+	Title   string  `json:"title"`
+	Entries []Entry `json:"entries"`
+}
+
+// Entry is one commit.
+type Entry struct {
+	Date        time.Time `json:"date"`
+	Commit      string    `json:"commit"`
+	Author      string    `json:"author"`
+	Scope       string    `json:"scope,omitempty"`
+	Description string    `json:"description"`
+	Breaking    bool      `json:"breaking,omitempty"`
+}
+
+// knownTypes lists the conventional types with a title, in display order.
+// Other types follow alphabetically, then commits without a type.
+var knownTypes = []struct{ typ, title string }{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"revert", "Reverts"},
+	{"docs", "Documentation"},
+	{"style", "Style"},
+	{"test", "Tests"},
+	{"build", "Build"},
+	{"ci", "CI"},
+	{"chore", "Chores"},
+}
+
+// conventional matches "type(scope)!: description".
+var conventional = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?: +(.+)$`)
+
+// Build groups commits, given newest first as git log lists them.
+func Build(sourceName, from, to string, commits []source.Commit) *Changelog {
+	c := &Changelog{Source: sourceName, From: from, To: to, Groups: []Group{}}
+
+	byType := make(map[string][]Entry)
+	for _, commit := range commits {
+		e := Entry{
+			Date:        commit.Date,
+			Commit:      commit.Hash,
+			Author:      commit.Author,
+			Description: commit.Subject,
+		}
+		typ := ""
+		if m := conventional.FindStringSubmatch(commit.Subject); m != nil {
+			typ = strings.ToLower(m[1])
+			e.Scope = m[2]
+			e.Breaking = m[3] == "!"
+			e.Description = m[4]
+		}
+		if strings.Contains(commit.Body, "BREAKING CHANGE:") || strings.Contains(commit.Body, "BREAKING-CHANGE:") {
+			e.Breaking = true
+		}
+		byType[typ] = append(byType[typ], e)
+	}
+
+	for _, k := range knownTypes {
+		if entries, ok := byType[k.typ]; ok {
+			c.Groups = append(c.Groups, Group{Type: k.typ, Title: k.title, Entries: entries})
+			delete(byType, k.typ)
+		}
+	}
+	other := byType[""]
+	delete(byType, "")
+	var rest []string
+	for typ := range byType {
+		rest = append(rest, typ)
+	}
+	sort.Strings(rest)
+	for _, typ := range rest {
+		c.Groups = append(c.Groups, Group{Type: typ, Title: typ, Entries: byType[typ]})
+	}
+	if len(other) > 0 {
+		c.Groups = append(c.Groups, Group{Title: "Other Changes", Entries: other})
+	}
+	return c
+}
+
+// Empty reports whether there are no changes.
+func (c *Changelog) Empty() bool {
+	return len(c.Groups) == 0
+}
+
+// Markdown renders the changelog as a markdown section.
+func (c *Changelog) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s: %s..%s\n", c.Source, short(c.From), short(c.To))
+	if c.Empty() {
+		b.WriteString("\nNo changes.\n")
+		return b.String()
+	}
+	for _, g := range c.Groups {
+		fmt.Fprintf(&b, "\n### %s\n\n", g.Title)
+		for _, e := range g.Entries {
+			b.WriteString("- ")
+			if e.Breaking {
+				b.WriteString("**BREAKING** ")
+			}
+			if e.Scope != "" {
+				fmt.Fprintf(&b, "**%s:** ", e.Scope)
+			}
+			fmt.Fprintf(&b, "%s (%s)\n", e.Description, short(e.Commit))
+		}
+	}
+	return b.String()
+}
+
+// short abbreviates a full commit hash; other revisions are kept whole.
+func short(rev string) string {
+	if fullHash.MatchString(rev) {
+		return rev[:8]
+	}
+	return rev
+}
+
+var fullHash = regexp.MustCompile(`^[0-9a-f]{40}$`)
@@ -0,0 +1,85 @@
+package changelog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/source"
+)
+
+const (
+	hashA = "aaaaaaaa11111111111111111111111111111111"
+	hashB = "bbbbbbbb22222222222222222222222222222222"
+	hashC = "cccccccc33333333333333333333333333333333"
+	hashD = "dddddddd44444444444444444444444444444444"
+	hashE = "eeeeeeee55555555555555555555555555555555"
+)
+
+func testCommits() []source.Commit {
+	date := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	return []source.Commit{
+		{Hash: hashA, Subject: "Tidy README", Date: date},
+		{Hash: hashB, Subject: "fix(rules): correct typo", Date: date},
+		{Hash: hashC, Subject: "feat!: rename rule files", Date: date},
+		{Hash: hashD, Subject: "wip: experiment", Date: date},
+		{Hash: hashE, Subject: "feat(agents): add reviewer", Body: "BREAKING CHANGE: drops old agent", Date: date},
+	}
+}
+
+func TestBuildGroupsByType(t *testing.T) {
+	c := Build("rules", hashA, "main", testCommits())
+
+	var titles []string
+	for _, g := range c.Groups {
+		titles = append(titles, g.Title)
+	}
+	if got := strings.Join(titles, ","); got != "Features,Bug Fixes,wip,Other Changes" {
+		t.Fatalf("groups = %s", got)
+	}
+
+	feats := c.Groups[0].Entries
+	if len(feats) != 2 || feats[0].Commit != hashC || feats[1].Scope != "agents" {
+		t.Errorf("features = %+v", feats)
+	}
+	if !feats[0].Breaking || !feats[1].Breaking {
+		t.Error("expected both features to be breaking ('!' and BREAKING CHANGE footer)")
+	}
+	if fix := c.Groups[1].Entries[0]; fix.Breaking || fix.Description != "correct typo" {
+		t.Errorf("fix = %+v", fix)
+	}
+	if other := c.Groups[3].Entries[0]; other.Description != "Tidy README" {
+		t.Errorf("other = %+v", other)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	got := Build("rules", hashA, "main", testCommits()[:3]).Markdown()
+	want := `## rules: aaaaaaaa..main
+
+### Features
+
+- **BREAKING** rename rule files (cccccccc)
+
+### Bug Fixes
+
+- **rules:** correct typo (bbbbbbbb)
+
+### Other Changes
+
+- Tidy README (aaaaaaaa)
+`
+	if got != want {
+		t.Errorf("Markdown() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	c := Build("rules", hashA, hashB, nil)
+	if !c.Empty() {
+		t.Error("expected empty changelog")
+	}
+	if got := c.Markdown(); !strings.Contains(got, "No changes.") {
+		t.Errorf("Markdown() = %q", got)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseAge parses a minimum age such as "72h", "3d", "1w", or "0". It
+// accepts everything time.ParseDuration does plus whole or fractional days
+// (d) and weeks (w), which ParseDuration lacks.
+func ParseAge(s string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var d time.Duration
+	var err error
+	switch unit := trimmed[len(trimmed)-1]; unit {
+	case 'd', 'w':
+		var n float64
+		n, err = strconv.ParseFloat(trimmed[:len(trimmed)-1], 64)
+		day := 24 * time.Hour
+		if unit == 'w' {
+			day *= 7
+		}
+		d = time.Duration(n * float64(day))
+	default:
+		d, err = time.ParseDuration(trimmed)
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration '%s' — expected e.g. 72h, 3d, or 1w", s)
+	}
+	return d, nil
+}
+
+// FormatAge formats a duration to the second, leaving out zero minutes and
+// seconds: "72h", "96h12m", "30s". The result is accepted by ParseAge.
+func FormatAge(d time.Duration) string {
+	s := d.Truncate(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
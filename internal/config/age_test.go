@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"72h", 72 * time.Hour},
+		{"3d", 72 * time.Hour},
+		{"1w", 7 * 24 * time.Hour},
+		{"1.5d", 36 * time.Hour},
+		{"0", 0},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "3", "-1h", "xd"} {
+		if _, err := ParseAge(bad); err == nil {
+			t.Errorf("ParseAge(%q) should fail", bad)
+		}
+	}
+	if got := FormatAge(96*time.Hour + 12*time.Minute); got != "96h12m" {
+		t.Errorf("FormatAge = %q", got)
+	}
+}
+
+func TestMinAge(t *testing.T) {
+	cfg := &Config{Update: UpdateSettings{MinAge: "3d"}}
+	if got := cfg.MinAge(Source{Type: "git"}); got != 72*time.Hour {
+		t.Errorf("global = %v", got)
+	}
+	if got := cfg.MinAge(Source{Type: "git", MinAge: "0"}); got != 0 {
+		t.Errorf("per-source override = %v", got)
+	}
+	if got := cfg.MinAge(Source{Type: "url"}); got != 0 {
+		t.Errorf("url source = %v", got)
+	}
+}
+
+func TestValidateMinAge(t *testing.T) {
+	cfg := &Config{
+		Version: 1,
+		Update:  UpdateSettings{MinAge: "soon"},
+		Sources: []Source{
+			{Name: "doc", Type: "url", URL: "https://example.com/a.md", Checksum: "sha256:abc", MinAge: "1d"},
+			{Name: "rules", Type: "git", Repo: "https://example.com/r.git", Ref: "main", MinAge: "3x"},
+		},
+		Targets: []Target{{Source: "doc", Destination: "out/"}},
+	}
+	errs := Validate(cfg)
+	for _, want := range []string{"update: invalid min_age", "'min_age' is only supported for git sources", "source 'rules': invalid min_age"} {
+		if !containsSubstring(errs, want) {
+			t.Errorf("missing %q in %v", want, errs)
+		}
+	}
+}
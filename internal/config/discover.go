@@ -21,10 +21,11 @@ const (
 
 // ConfigLayerInfo describes a discovered config file and its load status.
 type ConfigLayerInfo struct {
-	Err    error // non-nil if the file exists but failed to load
-	Path   string
-	Level  ConfigLevel
-	Loaded bool
+	Err      error // non-nil if the file exists but failed to load
+	Path     string
+	Level    ConfigLevel
+	Includes []string // fragment files merged into this layer
+	Loaded   bool
 }
 
 // DiscoverOptions controls how config paths are discovered.
@@ -114,6 +115,21 @@ func EnvNoInherit() bool {
 	return envBoolTrue("AGENT_SYNC_NO_INHERIT")
 }
 
+// EnvOffline returns true if AGENT_SYNC_OFFLINE is set to "1" or "true".
+func EnvOffline() bool {
+	return envBoolTrue("AGENT_SYNC_OFFLINE")
+}
+
+// EnvAllowCommands returns true if AGENT_SYNC_ALLOW_COMMANDS is set to "1" or "true".
+func EnvAllowCommands() bool {
+	return envBoolTrue("AGENT_SYNC_ALLOW_COMMANDS")
+}
+
+// EnvProfile returns the profile named by AGENT_SYNC_PROFILE, if any.
+func EnvProfile() string {
+	return strings.TrimSpace(os.Getenv("AGENT_SYNC_PROFILE"))
+}
+
 // envBoolTrue returns true if the env var is set to "1" or "true" (case-insensitive).
 func envBoolTrue(key string) bool {
 	v := os.Getenv(key)
@@ -0,0 +1,301 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/bianoble/agent-sync/internal/textdiff"
+	"gopkg.in/yaml.v3"
+)
+
+// Editor makes changes to one config file through its YAML node tree, so
+// that comments, key order, blank lines, the indentation width, and the
+// spacing before inline comments are kept. Other formatting is normalized
+// when the file is written back: sequences are indented under their key,
+// and comment lines take the indentation of the entry they belong to. Only
+// the file itself is edited: entries defined in included fragments or
+// other layers are out of reach. Changes are not validated; see
+// HierarchicalOptions.ProjectData.
+type Editor struct {
+	root     *yaml.Node // the top-level mapping
+	doc      yaml.Node
+	path     string
+	original []byte
+}
+
+// EditFile reads the config file at path for editing.
+func EditFile(path string) (*Editor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	e := &Editor{path: path, original: data}
+	if err := yaml.Unmarshal(data, &e.doc); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	if len(e.doc.Content) == 0 {
+		e.doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	e.root = e.doc.Content[0]
+	if e.root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config %s: top level is not a mapping", path)
+	}
+	return e, nil
+}
+
+// Bytes returns the edited file content.
+func (e *Editor) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(detectIndent(e.original))
+	if err := enc.Encode(&e.doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return keepFormatting(e.original, buf.Bytes()), nil
+}
+
+// detectIndent returns the number of spaces by which data indents the
+// first nested block, or 2 if it has none. Sequences written at the same
+// column as their key say nothing about it.
+func detectIndent(data []byte) int {
+	parent := -1 // column of the key opening a block on the previous line
+	for _, line := range strings.Split(string(data), "\n") {
+		rest := strings.TrimLeft(line, " ")
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			continue
+		}
+		if indent := len(line) - len(rest); parent >= 0 && indent > parent {
+			if d := indent - parent; d >= 2 && d <= 9 {
+				return d
+			}
+		}
+		for strings.HasPrefix(rest, "- ") {
+			rest = strings.TrimLeft(rest[2:], " ")
+		}
+		content, _, _ := strings.Cut(rest, " #")
+		parent = -1
+		if strings.HasSuffix(strings.TrimSpace(content), ":") {
+			parent = len(line) - len(rest)
+		}
+	}
+	return 2
+}
+
+// keepFormatting puts back what encoding changed in lines kept from
+// original: the blank lines before them, and the spacing before their
+// inline comments. Lines are paired up by a longest common subsequence, so
+// that entries added or removed in between do not matter.
+func keepFormatting(original, encoded []byte) []byte {
+	before := strings.Split(string(original), "\n")
+	after := strings.Split(string(encoded), "\n")
+
+	blank := make(map[int]bool) // lines of after to put a blank line before
+	textdiff.Match(lineKeys(before, "\n"), lineKeys(after, ""), func(i, j int) {
+		if !sameLine(before[i], after[j]) {
+			return
+		}
+		if i > 0 && strings.TrimSpace(before[i-1]) == "" && (j == 0 || after[j-1] != "") {
+			blank[j] = true
+		}
+		after[j] = before[i]
+	})
+
+	var out strings.Builder
+	for j, line := range after {
+		if blank[j] {
+			out.WriteString("\n")
+		}
+		out.WriteString(line)
+		if j < len(after)-1 {
+			out.WriteString("\n")
+		}
+	}
+	return []byte(out.String())
+}
+
+// lineKeys returns lines with the spaces before each " #" reduced to one,
+// so that lines sameLine pairs compare equal; it may also equate a few it
+// does not, such as quoted values differing in such spaces, which
+// keepFormatting then leaves alone. Blank lines, which are never paired,
+// get the key blank; the original's use one no encoded line has.
+func lineKeys(lines []string, blank string) []string {
+	keys := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			keys[i] = blank
+			continue
+		}
+		keys[i] = commentGap.ReplaceAllString(line, " #")
+	}
+	return keys
+}
+
+var commentGap = regexp.MustCompile(` +#`)
+
+// sameLine reports whether the encoded line is the non-blank original
+// line, or differs from it only in the spaces before an inline comment.
+func sameLine(original, encoded string) bool {
+	if strings.TrimSpace(original) == "" {
+		return false
+	}
+	if original == encoded {
+		return true
+	}
+	for i := 0; ; {
+		k := strings.Index(encoded[i:], " #")
+		if k < 0 {
+			return false
+		}
+		i += k
+		value, comment := encoded[:i], encoded[i+1:]
+		if gap, ok := strings.CutPrefix(original, value); ok && strings.HasSuffix(gap, comment) {
+			if spaces := gap[:len(gap)-len(comment)]; spaces != "" && strings.TrimSpace(spaces) == "" {
+				return true
+			}
+		}
+		i++
+	}
+}
+
+// AddSource appends a source. A source of the same name in this file is
+// an error; one in another layer is replaced, as when written by hand.
+func (e *Editor) AddSource(src Source) error {
+	if _, ok := e.find("sources", "name", src.Name); ok {
+		return fmt.Errorf("source '%s' already exists in %s", src.Name, e.path)
+	}
+	return e.appendEntry("sources", src)
+}
+
+// RemoveSource removes a source along with the targets and transforms in
+// this file that use it.
+func (e *Editor) RemoveSource(name string) error {
+	i, ok := e.find("sources", "name", name)
+	if !ok {
+		return fmt.Errorf("source '%s' is not defined in %s — to drop an inherited source, list it under 'remove: sources:'", name, e.path)
+	}
+	seq := mappingValue(e.root, "sources")
+	seq.Content = append(seq.Content[:i], seq.Content[i+1:]...)
+	for _, key := range []string{"targets", "transforms"} {
+		for {
+			j, ok := e.find(key, "source", name)
+			if !ok {
+				break
+			}
+			seq := mappingValue(e.root, key)
+			seq.Content = append(seq.Content[:j], seq.Content[j+1:]...)
+		}
+	}
+	return nil
+}
+
+// AddTarget appends a target. A named target whose name is already used
+// in this file is an error.
+func (e *Editor) AddTarget(t Target) error {
+	if t.Name != "" {
+		if _, ok := e.find("targets", "name", t.Name); ok {
+			return fmt.Errorf("target '%s' already exists in %s", t.Name, e.path)
+		}
+	}
+	return e.appendEntry("targets", t)
+}
+
+// SetVariable sets a variable to a literal value, replacing its value or
+// provider if the file already defines it.
+func (e *Editor) SetVariable(name, value string) error {
+	vars := mappingValue(e.root, "variables")
+	if vars == nil || vars.Kind != yaml.MappingNode {
+		vars = &yaml.Node{Kind: yaml.MappingNode}
+		e.setKey("variables", vars)
+	}
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	for i := 0; i+1 < len(vars.Content); i += 2 {
+		if vars.Content[i].Value == name {
+			old := vars.Content[i+1]
+			node.LineComment, node.FootComment = old.LineComment, old.FootComment
+			vars.Content[i+1] = node
+			return nil
+		}
+	}
+	vars.Style = 0 // an empty {} becomes a block mapping
+	vars.Content = append(vars.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, node)
+	return nil
+}
+
+// find returns the index of the entry in the key sequence whose field is
+// value.
+func (e *Editor) find(key, field, value string) (int, bool) {
+	seq := mappingValue(e.root, key)
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return 0, false
+	}
+	for i, item := range seq.Content {
+		if v := mappingValue(item, field); v != nil && v.Value == value {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// appendEntry encodes entry and appends it to the key sequence, creating
+// the sequence if the file has none. Lists in the entry are written in the
+// style of the same field in the entries before it, or in flow style, as
+// in the documentation examples.
+func (e *Editor) appendEntry(key string, entry any) error {
+	var node yaml.Node
+	if err := node.Encode(entry); err != nil {
+		return err
+	}
+	seq := mappingValue(e.root, key)
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		seq = &yaml.Node{Kind: yaml.SequenceNode}
+		e.setKey(key, seq)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		field, value := node.Content[i].Value, node.Content[i+1]
+		if value.Kind != yaml.SequenceNode {
+			continue
+		}
+		value.Style = yaml.FlowStyle
+		for _, item := range seq.Content {
+			if v := mappingValue(item, field); v != nil && v.Kind == yaml.SequenceNode {
+				value.Style = v.Style
+			}
+		}
+	}
+	seq.Style = 0 // an empty [] becomes a block sequence
+	seq.Content = append(seq.Content, &node)
+	return nil
+}
+
+// setKey sets the value of key in the top-level mapping. A new key goes
+// before the first key that follows it in the Config type, and after
+// version, so that the file keeps the usual section order.
+func (e *Editor) setKey(key string, value *yaml.Node) {
+	for i := 0; i+1 < len(e.root.Content); i += 2 {
+		if e.root.Content[i].Value == key {
+			e.root.Content[i+1] = value
+			return
+		}
+	}
+	order := make(map[string]int)
+	for i, f := range yamlFields(reflect.TypeOf(Config{})) {
+		order[f.Name] = i
+	}
+	order["version"] = -1
+	at := len(e.root.Content)
+	for i := 0; i+1 < len(e.root.Content); i += 2 {
+		if j, ok := order[e.root.Content[i].Value]; ok && j > order[key] {
+			at = i
+			break
+		}
+	}
+	pair := []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value}
+	e.root.Content = append(e.root.Content[:at], append(pair, e.root.Content[at:]...)...)
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const editedConfig = `# Project config.
+version: 1
+
+sources:
+  # Shared rules.
+  - name: rules
+    type: local
+    path: ./rules/ # kept in the repo
+  - name: old
+    type: local
+    path: ./old/
+    paths:
+      - old/
+
+targets:
+  - source: rules
+    tools: [claude-code]
+  - source: old
+    destination: docs/old/
+`
+
+func TestEditorKeepsComments(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent-sync.yaml")
+	writeFiles(t, dir, map[string]string{"agent-sync.yaml": editedConfig})
+
+	e, err := EditFile(path)
+	if err != nil {
+		t.Fatalf("EditFile: %v", err)
+	}
+	if err := e.AddSource(Source{Name: "team", Type: "git", Repo: "https://github.com/org/rules.git", Ref: "v1", Paths: []string{"rules/"}}); err != nil {
+		t.Fatalf("AddSource: %v", err)
+	}
+	if err := e.AddTarget(Target{Source: "team", Tools: []string{"cursor", "claude-code"}}); err != nil {
+		t.Fatalf("AddTarget: %v", err)
+	}
+	if err := e.RemoveSource("old"); err != nil {
+		t.Fatalf("RemoveSource: %v", err)
+	}
+	if err := e.SetVariable("org", "acme"); err != nil {
+		t.Fatalf("SetVariable: %v", err)
+	}
+	data, err := e.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+
+	want := `# Project config.
+version: 1
+variables:
+  org: acme
+
+sources:
+  # Shared rules.
+  - name: rules
+    type: local
+    path: ./rules/ # kept in the repo
+  - name: team
+    type: git
+    repo: https://github.com/org/rules.git
+    ref: v1
+    paths:
+      - rules/
+
+targets:
+  - source: rules
+    tools: [claude-code]
+  - source: team
+    tools: [cursor, claude-code]
+`
+	if string(data) != want {
+		t.Errorf("edited config:\n%s\nwant:\n%s", data, want)
+	}
+
+	result, err := LoadHierarchical(HierarchicalOptions{ProjectPath: path, NoInherit: true, ProjectData: data})
+	if err != nil {
+		t.Fatalf("LoadHierarchical: %v", err)
+	}
+	if n := len(result.Config.Sources); n != 2 {
+		t.Errorf("got %d sources, want 2", n)
+	}
+}
+
+func TestEditorErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent-sync.yaml")
+	writeFiles(t, dir, map[string]string{"agent-sync.yaml": editedConfig})
+	e, err := EditFile(path)
+	if err != nil {
+		t.Fatalf("EditFile: %v", err)
+	}
+	if err := e.AddSource(Source{Name: "rules", Type: "local", Path: "x"}); err == nil || !strings.Contains(err.Error(), "source 'rules' already exists") {
+		t.Errorf("AddSource duplicate: got %v", err)
+	}
+	if err := e.RemoveSource("missing"); err == nil || !strings.Contains(err.Error(), "source 'missing' is not defined in") {
+		t.Errorf("RemoveSource missing: got %v", err)
+	}
+}
+
+func TestEditorKeepsIndentationAndCommentSpacing(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent-sync.yaml")
+	original := `version: 1  # schema
+
+cache:
+    max_size: 1GB   # plenty
+
+sources:
+    - name: rules
+      type: local
+      path: ./rules/  # kept in the repo
+
+targets:
+    - source: rules
+      destination: out/
+`
+	writeFiles(t, dir, map[string]string{"agent-sync.yaml": original})
+
+	e, err := EditFile(path)
+	if err != nil {
+		t.Fatalf("EditFile: %v", err)
+	}
+	if err := e.AddSource(Source{Name: "more", Type: "local", Path: "./more/"}); err != nil {
+		t.Fatalf("AddSource: %v", err)
+	}
+	data, err := e.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+
+	want := `version: 1  # schema
+
+cache:
+    max_size: 1GB   # plenty
+
+sources:
+    - name: rules
+      type: local
+      path: ./rules/  # kept in the repo
+    - name: more
+      type: local
+      path: ./more/
+
+targets:
+    - source: rules
+      destination: out/
+`
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestKeepFormattingLargeFile(t *testing.T) {
+	// A table of every line pair would need gigabytes here.
+	const n = 50000
+	var original, encoded strings.Builder
+	original.WriteString("variables:\n")
+	encoded.WriteString("variables:\n")
+	for i := 0; i < n; i++ {
+		if i%10 == 0 {
+			original.WriteString("\n")
+		}
+		fmt.Fprintf(&original, "  v%d: x   # note\n", i)
+		fmt.Fprintf(&encoded, "  v%d: x # note\n", i)
+	}
+	if got := keepFormatting([]byte(original.String()), []byte(encoded.String())); string(got) != original.String() {
+		t.Error("formatting not kept")
+	}
+}
+
+func TestDetectIndent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"version: 1\n", 2},
+		{"a:\n  b: 1\n", 2},
+		{"a:\n    b: 1\n", 4},
+		{"# note:\nsources:\n- name: x\n  env:\n     A: b\n", 3},
+		{"sources:\n    - name: x\n", 4},
+	}
+	for _, tt := range tests {
+		if got := detectIndent([]byte(tt.in)); got != tt.want {
+			t.Errorf("detectIndent(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEditorSetVariableReplacesProvider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent-sync.yaml")
+	writeFiles(t, dir, map[string]string{"agent-sync.yaml": `version: 1
+variables:
+  token: {env: TOKEN} # from CI
+sources: []
+targets: []
+`})
+	e, err := EditFile(path)
+	if err != nil {
+		t.Fatalf("EditFile: %v", err)
+	}
+	if err := e.SetVariable("token", "true"); err != nil {
+		t.Fatalf("SetVariable: %v", err)
+	}
+	data, err := e.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	if want := `  token: "true" # from CI`; !strings.Contains(string(data), want) {
+		t.Errorf("edited config:\n%s\nwant it to contain %q", data, want)
+	}
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Removal drops entries inherited from lower-precedence layers, such as a
+// source from the user config that a project does not want. Names that no
+// lower layer defines are ignored, since which layers exist varies between
+// machines. Enforced entries cannot be removed.
+type Removal struct {
+	Sources   []string `yaml:"sources,omitempty"`   // also drops their targets and transforms
+	Targets   []string `yaml:"targets,omitempty"`   // by name, or by source for unnamed targets
+	Overrides []string `yaml:"overrides,omitempty"` // by target file
+	Variables []string `yaml:"variables,omitempty"`
+	Origin    Origin   `yaml:"-"`
+}
+
+func (r Removal) empty() bool {
+	return len(r.Sources) == 0 && len(r.Targets) == 0 && len(r.Overrides) == 0 && len(r.Variables) == 0
+}
+
+// apply returns base without the removed entries. base is not modified.
+func (r Removal) apply(base *Config) *Config {
+	if r.empty() {
+		return base
+	}
+	sources := toSet(r.Sources)
+	targets := toSet(r.Targets)
+	overrides := toSet(r.Overrides)
+
+	result := *base
+	result.Sources = nil
+	for _, s := range base.Sources {
+		if !sources[s.Name] {
+			result.Sources = append(result.Sources, s)
+		}
+	}
+	result.Targets = nil
+	for _, t := range base.Targets {
+		if !sources[t.Source] && !targets[t.selector()] {
+			result.Targets = append(result.Targets, t)
+		}
+	}
+	result.Transforms = nil
+	for _, tx := range base.Transforms {
+		if !sources[tx.Source] {
+			result.Transforms = append(result.Transforms, tx)
+		}
+	}
+	result.Overrides = nil
+	for _, ov := range base.Overrides {
+		if !overrides[ov.Target] {
+			result.Overrides = append(result.Overrides, ov)
+		}
+	}
+	if len(r.Variables) > 0 {
+		result.Variables = mergeVariables(base.Variables, nil)
+		result.providers = mergeProviders(base.providers, nil)
+		result.varDefs = mergeVarDefs(base.varDefs, nil)
+		for _, name := range r.Variables {
+			delete(result.Variables, name)
+			delete(result.providers, name)
+			delete(result.varDefs, name)
+		}
+	}
+	return &result
+}
+
+// union combines two removals, keeping the origin of the later one.
+func (r Removal) union(later Removal) Removal {
+	if r.empty() {
+		return later
+	}
+	if later.empty() {
+		return r
+	}
+	return Removal{
+		Sources:   append(append([]string(nil), r.Sources...), later.Sources...),
+		Targets:   append(append([]string(nil), r.Targets...), later.Targets...),
+		Overrides: append(append([]string(nil), r.Overrides...), later.Overrides...),
+		Variables: append(append([]string(nil), r.Variables...), later.Variables...),
+		Origin:    later.Origin,
+	}
+}
+
+// checkEnforced reports the ways overlay would replace, remove, or override
+// entries that base enforces.
+func checkEnforced(base, overlay *Config) []FieldError {
+	var errs []FieldError
+	removed := overlay.Remove
+	sources := toSet(removed.Sources)
+	targets := toSet(removed.Targets)
+	overrides := toSet(removed.Overrides)
+
+	replaced := make(map[string]Origin, len(overlay.Sources))
+	for _, s := range overlay.Sources {
+		replaced[s.Name] = s.Origin
+	}
+	for _, s := range base.Sources {
+		if !s.Enforced {
+			continue
+		}
+		if at, ok := replaced[s.Name]; ok {
+			errs = append(errs, fieldError(Origin{}, "", "source '%s' is enforced by %s and cannot be replaced by %s", s.Name, where(s.Origin), where(at)))
+		}
+		if sources[s.Name] {
+			errs = append(errs, fieldError(Origin{}, "", "source '%s' is enforced by %s and cannot be removed by %s", s.Name, where(s.Origin), where(removed.Origin)))
+		}
+	}
+
+	for _, t := range base.Targets {
+		if !t.Enforced {
+			continue
+		}
+		switch {
+		case targets[t.selector()]:
+			errs = append(errs, fieldError(Origin{}, "", "target '%s' is enforced by %s and cannot be removed by %s", t.selector(), where(t.Origin), where(removed.Origin)))
+		case sources[t.Source]:
+			errs = append(errs, fieldError(Origin{}, "", "target '%s' is enforced by %s and cannot be removed with source '%s' by %s", t.selector(), where(t.Origin), t.Source, where(removed.Origin)))
+		}
+	}
+
+	for _, ov := range base.Overrides {
+		if !ov.Enforced {
+			continue
+		}
+		if overrides[ov.Target] {
+			errs = append(errs, fieldError(Origin{}, "", "override for '%s' is enforced by %s and cannot be removed by %s", ov.Target, where(ov.Origin), where(removed.Origin)))
+		}
+		for _, o := range overlay.Overrides {
+			if o.Target == ov.Target && o.Strategy == "replace" {
+				errs = append(errs, fieldError(Origin{}, "", "override for '%s' is enforced by %s and cannot be replaced by %s", ov.Target, where(ov.Origin), where(o.Origin)))
+			}
+		}
+	}
+
+	// A transform rewrites every file of its source, so one added by a
+	// higher layer could empty an enforced source or target as surely as
+	// removing it.
+	for _, tx := range overlay.Transforms {
+		for _, s := range base.Sources {
+			if s.Enforced && s.Name == tx.Source {
+				errs = append(errs, fieldError(Origin{}, "", "source '%s' is enforced by %s and cannot be transformed by %s", s.Name, where(s.Origin), where(tx.Origin)))
+			}
+		}
+		for _, t := range base.Targets {
+			if t.Enforced && t.Source == tx.Source {
+				errs = append(errs, fieldError(Origin{}, "", "target '%s' is enforced by %s and its source '%s' cannot be transformed by %s", t.selector(), where(t.Origin), t.Source, where(tx.Origin)))
+			}
+		}
+	}
+
+	errs = append(errs, checkSigningKeys(base, overlay)...)
+
+	removedVars := toSet(removed.Variables)
+	for _, name := range sortedKeys(base.varDefs) {
+		def := base.varDefs[name]
+		if !def.Enforced {
+			continue
+		}
+		if at, ok := overlay.varDefs[name]; ok {
+			errs = append(errs, fieldError(Origin{}, "variables."+name, "variable '%s' is enforced by %s and cannot be overridden by %s", name, where(def.Origin), where(at.Origin)))
+		}
+		if removedVars[name] {
+			errs = append(errs, fieldError(Origin{}, "variables."+name, "variable '%s' is enforced by %s and cannot be removed by %s", name, where(def.Origin), where(removed.Origin)))
+		}
+	}
+	return errs
+}
+
+// CheckOverride reports an error if ov would replace a file written by the
+// enforced target t and comes from a higher config layer than t. Which
+// files a target writes is only known once its source is fetched, so sync
+// checks this rather than the config loader. Appending and prepending keep
+// the enforced content and are allowed.
+func CheckOverride(t Target, ov Override) error {
+	if !t.Enforced || ov.Strategy != "replace" || layerRank[ov.Origin.Layer] <= layerRank[t.Origin.Layer] {
+		return nil
+	}
+	return fmt.Errorf("target '%s' is enforced by %s and its file '%s' cannot be replaced by %s", t.selector(), where(t.Origin), ov.Target, where(ov.Origin))
+}
+
+// layerRank orders config layers by precedence. Configs not loaded
+// hierarchically have no layer and rank lowest.
+var layerRank = map[ConfigLevel]int{LevelSystem: 1, LevelUser: 2, LevelProject: 3}
+
+// checkSigningKeys keeps a higher config layer from changing whose
+// lockfile signatures a lower layer trusts: it may not replace a lower
+// layer's allowed key, nor add keys once a lower layer requires signatures.
+// Otherwise anyone able to edit the project config could trust their own
+// key and re-sign a tampered lockfile. Fragments merged by include belong
+// to one layer and are not checked.
+func checkSigningKeys(base, overlay *Config) []FieldError {
+	var errs []FieldError
+	lower := make(map[string]AllowedKey, len(base.Signing.AllowedKeys))
+	for _, k := range base.Signing.AllowedKeys {
+		lower[k.Name] = k
+	}
+	required := base.position("signing.require")
+	for _, k := range overlay.Signing.AllowedKeys {
+		if k.Origin.Layer == "" {
+			continue
+		}
+		if b, ok := lower[k.Name]; ok && b.Origin.Layer != "" && b.Origin.Layer != k.Origin.Layer {
+			errs = append(errs, fieldError(Origin{}, "", "allowed key '%s' is defined by %s and cannot be replaced by %s", k.Name, where(b.Origin), where(k.Origin)))
+			continue
+		}
+		if base.Signing.Require && required.Layer != "" && required.Layer != k.Origin.Layer {
+			errs = append(errs, fieldError(Origin{}, "", "allowed key '%s' cannot be added by %s: signatures are required by %s, so trusted keys must be listed there or in a lower layer", k.Name, where(k.Origin), where(required)))
+		}
+	}
+	return errs
+}
+
+// checkNotEnforced rejects enforced entries in a project config, where
+// there is no higher layer to enforce them against.
+func checkNotEnforced(cfg *Config) []FieldError {
+	const msg = "%s: 'enforced' is only allowed in system and user config"
+	var errs []FieldError
+	for i, s := range cfg.Sources {
+		if s.Enforced {
+			errs = append(errs, fieldError(s.Origin, fmt.Sprintf("sources[%d]", i), msg, fmt.Sprintf("source '%s'", s.Name)))
+		}
+	}
+	for i, t := range cfg.Targets {
+		if t.Enforced {
+			errs = append(errs, fieldError(t.Origin, fmt.Sprintf("targets[%d]", i), msg, fmt.Sprintf("target '%s'", t.selector())))
+		}
+	}
+	for i, ov := range cfg.Overrides {
+		if ov.Enforced {
+			errs = append(errs, fieldError(ov.Origin, fmt.Sprintf("overrides[%d]", i), msg, fmt.Sprintf("override for '%s'", ov.Target)))
+		}
+	}
+	for _, name := range sortedKeys(cfg.varDefs) {
+		if def := cfg.varDefs[name]; def.Enforced {
+			errs = append(errs, fieldError(def.Origin, "variables."+name, msg, fmt.Sprintf("variable '%s'", name)))
+		}
+	}
+	return errs
+}
+
+// validateEnforcedProfiles checks that no profile disables an enforced
+// source or target or overrides an enforced variable.
+func validateEnforcedProfiles(cfg *Config) []FieldError {
+	var errs []FieldError
+	for _, name := range cfg.ProfileNames() {
+		p := cfg.Profiles[name]
+		at, path, prefix := cfg.position("profiles."+name), "profiles."+name, fmt.Sprintf("profile '%s'", name)
+		disabled := toSet(p.Sources.Disable)
+		for _, s := range cfg.Sources {
+			if s.Enforced && disabled[s.Name] {
+				errs = append(errs, fieldError(at, path, "%s: source '%s' is enforced by %s and cannot be disabled", prefix, s.Name, where(s.Origin)))
+			}
+		}
+		disabled = toSet(p.Targets.Disable)
+		for _, t := range cfg.Targets {
+			if t.Enforced && disabled[t.selector()] {
+				errs = append(errs, fieldError(at, path, "%s: target '%s' is enforced by %s and cannot be disabled", prefix, t.selector(), where(t.Origin)))
+			}
+		}
+		for _, v := range sortedKeys(p.Variables) {
+			if def, ok := cfg.varDefs[v]; ok && def.Enforced {
+				errs = append(errs, fieldError(at, path, "%s: variable '%s' is enforced by %s and cannot be overridden", prefix, v, where(def.Origin)))
+			}
+		}
+	}
+	return errs
+}
+
+// where describes an origin in an error message.
+func where(o Origin) string {
+	if o.File == "" {
+		return "another config layer"
+	}
+	return o.describe()
+}
+
+func toSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,358 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const enforcedSystem = `version: 1
+variables:
+  registry: {value: https://registry.example.com, enforced: true}
+  team: platform
+sources:
+  - name: security
+    type: local
+    path: ./security/
+    enforced: true
+  - name: style
+    type: local
+    path: ./style/
+targets:
+  - name: security-docs
+    source: security
+    destination: docs/security/
+    enforced: true
+  - source: style
+    destination: docs/style/
+overrides:
+  - target: security.md
+    strategy: append
+    file: local/security.md
+    enforced: true
+`
+
+func loadLayers(t *testing.T, dir, project string) (*HierarchicalResult, error) {
+	t.Helper()
+	writeFiles(t, dir, map[string]string{
+		"system.yaml":     enforcedSystem,
+		"user.yaml":       "version: 1\n",
+		"agent-sync.yaml": project,
+	})
+	return LoadHierarchical(HierarchicalOptions{
+		ProjectPath:      filepath.Join(dir, "agent-sync.yaml"),
+		SystemConfigPath: filepath.Join(dir, "system.yaml"),
+		UserConfigPath:   filepath.Join(dir, "user.yaml"),
+	})
+}
+
+func TestEnforcedEntriesAreKept(t *testing.T) {
+	result, err := loadLayers(t, t.TempDir(), `version: 1
+sources:
+  - name: rules
+    type: local
+    path: ./rules/
+targets:
+  - source: rules
+    destination: out/
+overrides:
+  - target: security.md
+    strategy: append
+    file: local/more.md
+`)
+	if err != nil {
+		t.Fatalf("LoadHierarchical: %v", err)
+	}
+	cfg := result.Config
+	if len(cfg.Sources) != 3 || len(cfg.Overrides) != 2 {
+		t.Errorf("got %d sources and %d overrides, want 3 and 2", len(cfg.Sources), len(cfg.Overrides))
+	}
+	if cfg.Variables["registry"] != "https://registry.example.com" {
+		t.Errorf("registry = %q", cfg.Variables["registry"])
+	}
+}
+
+func TestEnforcedViolations(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		want    string
+	}{
+		{
+			name: "replace source",
+			project: `version: 1
+sources:
+  - name: security
+    type: local
+    path: ./mine/
+`,
+			want: "source 'security' is enforced by %s:6:5 (system config) and cannot be replaced by %s:3:5 (project config)",
+		},
+		{
+			name: "remove source",
+			project: `version: 1
+remove:
+  sources: [security]
+`,
+			want: "source 'security' is enforced by %s:6:5 (system config) and cannot be removed by %s:3:3 (project config)",
+		},
+		{
+			name: "remove enforced target",
+			project: `version: 1
+remove:
+  targets: [security-docs]
+`,
+			want: "target 'security-docs' is enforced by %s:14:5 (system config) and cannot be removed by %s:3:3 (project config)",
+		},
+		{
+			name: "remove source of enforced target",
+			project: `version: 1
+remove:
+  sources: [security]
+  targets: [style]
+`,
+			want: "target 'security-docs' is enforced by %s:14:5 (system config) and cannot be removed with source 'security' by %s:3:3 (project config)",
+		},
+		{
+			name: "replace override",
+			project: `version: 1
+overrides:
+  - target: security.md
+    strategy: replace
+    file: local/mine.md
+`,
+			want: "override for 'security.md' is enforced by %s:21:5 (system config) and cannot be replaced by %s:3:5 (project config)",
+		},
+		{
+			name: "transform enforced source",
+			project: `version: 1
+transforms:
+  - source: security
+    type: custom
+    command: "true"
+`,
+			want: "source 'security' is enforced by %s:6:5 (system config) and cannot be transformed by %s:3:5 (project config)",
+		},
+		{
+			name: "transform source of enforced target",
+			project: `version: 1
+transforms:
+  - source: security
+    type: template
+`,
+			want: "target 'security-docs' is enforced by %s:14:5 (system config) and its source 'security' cannot be transformed by %s:3:5 (project config)",
+		},
+		{
+			name: "override variable",
+			project: `version: 1
+variables:
+  registry: https://evil.example.com
+`,
+			want: "variable 'registry' is enforced by %s:3:3 (system config) and cannot be overridden by %s:3:3 (project config)",
+		},
+		{
+			name: "remove variable",
+			project: `version: 1
+remove:
+  variables: [registry]
+`,
+			want: "variable 'registry' is enforced by %s:3:3 (system config) and cannot be removed by %s:3:3 (project config)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			_, err := loadLayers(t, dir, tt.project)
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a validation error", err)
+			}
+			want := fmt.Sprintf(tt.want, filepath.Join(dir, "system.yaml"), filepath.Join(dir, "agent-sync.yaml"))
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q\nwant it to contain %q", err, want)
+			}
+		})
+	}
+}
+
+func TestRemoveDropsInheritedEntries(t *testing.T) {
+	result, err := loadLayers(t, t.TempDir(), `version: 1
+remove:
+  sources: [style, not-defined-here]
+  variables: [team]
+sources:
+  - name: rules
+    type: local
+    path: ./rules/
+`)
+	if err != nil {
+		t.Fatalf("LoadHierarchical: %v", err)
+	}
+	cfg := result.Config
+	for _, s := range cfg.Sources {
+		if s.Name == "style" {
+			t.Error("source 'style' was not removed")
+		}
+	}
+	for _, tgt := range cfg.Targets {
+		if tgt.Source == "style" {
+			t.Error("target of removed source 'style' was kept")
+		}
+	}
+	if _, ok := cfg.Variables["team"]; ok {
+		t.Error("variable 'team' was not removed")
+	}
+}
+
+func TestEnforcedOnlyBelowProject(t *testing.T) {
+	_, err := loadLayers(t, t.TempDir(), `version: 1
+sources:
+  - name: rules
+    type: local
+    path: ./rules/
+    enforced: true
+`)
+	if err == nil || !strings.Contains(err.Error(), "'enforced' is only allowed in system and user config") {
+		t.Fatalf("got %v, want enforced rejected in project config", err)
+	}
+}
+
+func TestProfilesCannotDisableEnforced(t *testing.T) {
+	_, err := loadLayers(t, t.TempDir(), `version: 1
+profiles:
+  ci:
+    sources:
+      disable: [security]
+    variables:
+      registry: other
+`)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{
+		"profile 'ci': source 'security' is enforced by",
+		"profile 'ci': variable 'registry' is enforced by",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestValueRequiresNoProvider(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"agent-sync.yaml": `version: 1
+variables:
+  x: {value: a, env: HOME}
+sources:
+  - name: rules
+    type: local
+    path: ./rules/
+`})
+	_, err := Load(filepath.Join(dir, "agent-sync.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "'value' cannot be combined") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestSigningKeysStayInTrustedLayers(t *testing.T) {
+	const system = `version: 1
+signing:
+  require: true
+  allowed_keys:
+    - name: release
+      public_key: ssh-ed25519 AAAAsystem
+`
+	tests := []struct {
+		name string
+		user string
+		proj string
+		want string
+	}{
+		{
+			name: "project adds key",
+			proj: `version: 1
+signing:
+  allowed_keys:
+    - name: mine
+      public_key: ssh-ed25519 AAAAmine
+`,
+			want: "allowed key 'mine' cannot be added by %[2]s:4:7 (project config): signatures are required by %[1]s:3:3 (system config)",
+		},
+		{
+			name: "project replaces key",
+			proj: `version: 1
+signing:
+  allowed_keys:
+    - name: release
+      public_key: ssh-ed25519 AAAAmine
+`,
+			want: "allowed key 'release' is defined by %[1]s:5:7 (system config) and cannot be replaced by %[2]s:4:7 (project config)",
+		},
+		{
+			name: "user adds key",
+			user: `version: 1
+signing:
+  allowed_keys:
+    - name: laptop
+      public_key: ssh-ed25519 AAAAlaptop
+`,
+			want: "(user config): signatures are required by %[1]s:3:3 (system config)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			user := tt.user
+			if user == "" {
+				user = "version: 1\n"
+			}
+			proj := tt.proj
+			if proj == "" {
+				proj = "version: 1\nsources: []\ntargets: []\n"
+			}
+			writeFiles(t, dir, map[string]string{"system.yaml": system, "user.yaml": user, "agent-sync.yaml": proj})
+			_, err := LoadHierarchical(HierarchicalOptions{
+				ProjectPath:      filepath.Join(dir, "agent-sync.yaml"),
+				SystemConfigPath: filepath.Join(dir, "system.yaml"),
+				UserConfigPath:   filepath.Join(dir, "user.yaml"),
+			})
+			want := fmt.Sprintf(tt.want, filepath.Join(dir, "system.yaml"), filepath.Join(dir, "agent-sync.yaml"))
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("got %v\nwant error containing %q", err, want)
+			}
+		})
+	}
+}
+
+func TestProjectSigningPolicyWithoutLowerRequire(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"system.yaml": "version: 1\n",
+		"user.yaml":   "version: 1\n",
+		"agent-sync.yaml": `version: 1
+sources:
+  - name: rules
+    type: local
+    path: ./rules/
+targets:
+  - source: rules
+    tools: [cursor]
+signing:
+  require: true
+  allowed_keys:
+    - name: team
+      public_key: ssh-ed25519 AAAAteam
+`,
+	})
+	_, err := LoadHierarchical(HierarchicalOptions{
+		ProjectPath:      filepath.Join(dir, "agent-sync.yaml"),
+		SystemConfigPath: filepath.Join(dir, "system.yaml"),
+		UserConfigPath:   filepath.Join(dir, "user.yaml"),
+	})
+	if err != nil {
+		t.Fatalf("LoadHierarchical: %v", err)
+	}
+}
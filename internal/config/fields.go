@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// variableSpec is the long form of a variable: a literal written with
+// 'value', or a provider. Either can be enforced.
+type variableSpec struct {
+	Value            *string `yaml:"value,omitempty"`
+	VariableProvider `yaml:",inline"`
+	Enforced         bool `yaml:"enforced,omitempty"`
+}
+
+// yamlField is a struct field as it is written in a config file.
+type yamlField struct {
+	Type     reflect.Type
+	Owner    reflect.Type // the struct declaring the field, for inline fields
+	Name     string
+	Required bool // the yaml tag has no omitempty
+}
+
+// yamlFields returns the fields of struct type t by yaml key, in declaration
+// order, with inline structs flattened. Unexported fields and fields tagged
+// "-" are left out.
+func yamlFields(t reflect.Type) []yamlField {
+	var fields []yamlField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		switch {
+		case opts == "inline":
+			fields = append(fields, yamlFields(f.Type)...)
+			continue
+		case !f.IsExported() || name == "-":
+			continue
+		case name == "":
+			name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, yamlField{
+			Type:     f.Type,
+			Owner:    t,
+			Name:     name,
+			Required: !strings.Contains(opts, "omitempty"),
+		})
+	}
+	return fields
+}
+
+// checkFields reports the mapping keys in n that type t does not define,
+// suggesting the closest known key. path names n in messages, such as
+// "sources[0]"; it is empty for the document root. Values of the wrong
+// kind are left for decoding to report.
+func checkFields(file string, n *yaml.Node, t reflect.Type, path string) []FieldError {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var errs []FieldError
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			f, ok := findField(fields, key.Value)
+			if !ok {
+				errs = append(errs, FieldError{Origin: nodeOrigin(file, key), Path: joinPath(path, key.Value), Message: unknownField(key.Value, path, fields)})
+				continue
+			}
+			errs = append(errs, checkFields(file, value, f.Type, joinPath(path, key.Value))...)
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range n.Content {
+			errs = append(errs, checkFields(file, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return nil
+		}
+		elem := t.Elem()
+		if path == "variables" {
+			// Config variables may be written in long form.
+			elem = reflect.TypeOf(variableSpec{})
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			errs = append(errs, checkFields(file, n.Content[i+1], elem, joinPath(path, n.Content[i].Value))...)
+		}
+	}
+	return errs
+}
+
+func findField(fields []yamlField, name string) (yamlField, bool) {
+	for _, f := range fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return yamlField{}, false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// unknownField describes an unknown key, suggesting the known field it is
+// closest to if it looks like a typo.
+func unknownField(key, path string, fields []yamlField) string {
+	msg := fmt.Sprintf("unknown field '%s'", key)
+	if path != "" {
+		msg += " in " + path
+	}
+	best, bestDist := "", 0
+	for _, f := range fields {
+		d := editDistance(strings.ToLower(key), f.Name)
+		if best == "" || d < bestDist {
+			best, bestDist = f.Name, d
+		}
+	}
+	if best != "" && bestDist <= 2 && bestDist < len(key) {
+		msg += fmt.Sprintf(" — did you mean '%s'?", best)
+	}
+	return msg
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
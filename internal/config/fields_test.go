@@ -0,0 +1,107 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"agent-sync.yaml": `version: 1
+variables:
+  token: {env: TOKEN, defualt: none}
+sources:
+  - name: rules
+    type: local
+    paht: ./rules/
+targets:
+  - source: rules
+    destination: out/
+sycn:
+  atomic: true
+profiles:
+  ci:
+    sources:
+      enabel: [rules]
+frobnicate: true
+`})
+	path := filepath.Join(dir, "agent-sync.yaml")
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{
+		path + ":3:23: unknown field 'defualt' in variables.token — did you mean 'default'?",
+		path + ":7:5: unknown field 'paht' in sources[0] — did you mean 'path'?",
+		path + ":11:1: unknown field 'sycn' — did you mean 'sync'?",
+		path + ":16:7: unknown field 'enabel' in profiles.ci.sources — did you mean 'enable'?",
+		path + ":17:1: unknown field 'frobnicate'\n",
+	} {
+		if !strings.Contains(err.Error()+"\n", want) {
+			t.Errorf("error = %v\nwant it to contain %q", err, want)
+		}
+	}
+}
+
+func TestLoadIgnoresFieldsOfNewerVersions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"agent-sync.yaml": "version: 99\nfuture: true\n"})
+	_, err := Load(filepath.Join(dir, "agent-sync.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "unsupported version 99") || strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("got %v, want only the version reported", err)
+	}
+}
+
+func TestValidationErrorsNameLayer(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"user.yaml": `version: 1
+sources:
+  - name: notes
+    type: git
+    repo: https://example.com/notes.git
+update:
+  min_age: soon
+`,
+		"agent-sync.yaml": `version: 1
+sources:
+  - name: rules
+    type: local
+    path: ./rules/
+`,
+	})
+	_, err := LoadHierarchical(HierarchicalOptions{
+		ProjectPath:      filepath.Join(dir, "agent-sync.yaml"),
+		SystemConfigPath: filepath.Join(dir, "none.yaml"),
+		UserConfigPath:   filepath.Join(dir, "user.yaml"),
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	user := filepath.Join(dir, "user.yaml")
+	for _, want := range []string{
+		user + ":3:5 (user config): source 'notes': type 'git' requires 'ref'",
+		user + ":7:3 (user config): update: invalid min_age",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v\nwant it to contain %q", err, want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "abc", 3},
+		{"source", "source", 0},
+		{"sorce", "source", 1},
+		{"enabel", "enable", 2},
+	} {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
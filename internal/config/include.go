@@ -0,0 +1,262 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bianoble/agent-sync/internal/sandbox"
+	"gopkg.in/yaml.v3"
+)
+
+// IncludedFiles returns the fragment files merged into the config through
+// include, in load order.
+func (c *Config) IncludedFiles() []string {
+	return c.includes
+}
+
+// parseFile reads the config file at path and merges in the fragments it
+// includes, recursively. Include paths and globs are relative to the
+// including file and must resolve within the directory of the top-level
+// file. Fragments merge with the same rules as Merge, in listed order and
+// below the file that includes them.
+func parseFile(path string) (*Config, error) {
+	return parseData(path, nil)
+}
+
+// parseData is parseFile with data in place of the content of path, if it
+// is not nil. Includes are still read from disk.
+func parseData(path string, data []byte) (*Config, error) {
+	l := &includeLoader{root: filepath.Dir(path), seen: make(map[string]string), data: data}
+	cfg, err := l.load(path, "")
+	if err != nil {
+		return nil, err
+	}
+	cfg.includes = l.files
+	return cfg, nil
+}
+
+// includeLoader tracks the files loaded while expanding includes.
+type includeLoader struct {
+	seen  map[string]string // resolved path -> file that included it
+	root  string
+	stack []string // resolved paths of the files being loaded, outermost first
+	names []string // display paths matching stack
+	files []string // display paths of the fragments loaded
+	data  []byte   // content of the top-level file, if not read from disk
+}
+
+func (l *includeLoader) load(path, real string) (*Config, error) {
+	data := l.data
+	l.data = nil
+	var err error
+	if data == nil {
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("reading config %s: %w", path, err)
+		}
+	}
+	cfg, err := decode(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Include) == 0 {
+		return cfg, nil
+	}
+
+	if real == "" {
+		if real, err = filepath.Abs(path); err != nil {
+			return nil, fmt.Errorf("resolving config %s: %w", path, err)
+		}
+		if resolved, err := filepath.EvalSymlinks(real); err == nil {
+			real = resolved
+		}
+	}
+	l.stack = append(l.stack, real)
+	l.names = append(l.names, path)
+	defer func() {
+		l.stack = l.stack[:len(l.stack)-1]
+		l.names = l.names[:len(l.names)-1]
+	}()
+
+	var merged *Config
+	notes := cfg.migrations
+	for i, pattern := range cfg.Include {
+		at := Origin{File: path}
+		if i < len(cfg.includeAt) {
+			at = cfg.includeAt[i]
+		}
+		matches, err := l.expand(path, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: include '%s': %w", at, pattern, err)
+		}
+		for _, m := range matches {
+			if err := l.enter(m.real, m.path, path, at); err != nil {
+				return nil, err
+			}
+			fragment, err := l.load(m.path, m.real)
+			if err != nil {
+				return nil, fmt.Errorf("%s: include '%s': %w", at, pattern, err)
+			}
+			notes = append(notes, fragment.migrations...)
+			if merged, err = Merge(merged, fragment); err != nil {
+				return nil, fmt.Errorf("%s: including %s: %w", at, m.path, err)
+			}
+		}
+	}
+
+	result, err := Merge(merged, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	result.migrations = notes
+	return result, nil
+}
+
+// includeMatch is a file selected by an include entry.
+type includeMatch struct {
+	path string // relative to the working directory, as the user sees it
+	real string // resolved absolute path
+}
+
+// expand resolves an include pattern relative to the including file and
+// checks that every match stays within the top-level config directory.
+// A plain path must exist; a glob may match nothing.
+func (l *includeLoader) expand(from, pattern string) ([]includeMatch, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	if filepath.IsAbs(pattern) {
+		return nil, fmt.Errorf("must be a relative path")
+	}
+
+	joined := filepath.Join(filepath.Dir(from), pattern)
+	paths := []string{joined}
+	if strings.ContainsAny(pattern, "*?[") {
+		var err error
+		if paths, err = filepath.Glob(joined); err != nil {
+			return nil, fmt.Errorf("invalid glob: %w", err)
+		}
+	}
+
+	absRoot, err := filepath.Abs(l.root)
+	if err != nil {
+		return nil, err
+	}
+	var matches []includeMatch
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(absRoot, abs)
+		if err != nil {
+			return nil, err
+		}
+		real, err := sandbox.ValidatePath(absRoot, rel)
+		if err != nil {
+			return nil, fmt.Errorf("%s is outside the config directory %s", p, l.root)
+		}
+		matches = append(matches, includeMatch{path: p, real: real})
+	}
+	return matches, nil
+}
+
+// enter records that from includes the file at real, rejecting cycles and
+// files included more than once.
+func (l *includeLoader) enter(real, path, from string, at Origin) error {
+	for i, r := range l.stack {
+		if r == real {
+			chain := append(append([]string{}, l.names[i:]...), path)
+			return fmt.Errorf("%s: include cycle: %s", at, strings.Join(chain, " -> "))
+		}
+	}
+	if prev, ok := l.seen[real]; ok {
+		return fmt.Errorf("%s: %s is already included by %s", at, path, prev)
+	}
+	l.seen[real] = from
+	l.files = append(l.files, path)
+	return nil
+}
+
+// setOrigins records the position of each list entry, setting, and profile
+// in cfg, read from the document root it was decoded from.
+func setOrigins(cfg *Config, path string, root *yaml.Node) {
+	at := func(keys ...string) []Origin {
+		n := root
+		for _, k := range keys {
+			if n = mappingValue(n, k); n == nil {
+				return nil
+			}
+		}
+		if n.Kind != yaml.SequenceNode {
+			return nil
+		}
+		origins := make([]Origin, len(n.Content))
+		for i, item := range n.Content {
+			origins[i] = nodeOrigin(path, item)
+		}
+		return origins
+	}
+
+	cfg.includeAt = at("include")
+	for i, o := range at("sources") {
+		if i < len(cfg.Sources) {
+			cfg.Sources[i].Origin = o
+		}
+	}
+	for i, o := range at("targets") {
+		if i < len(cfg.Targets) {
+			cfg.Targets[i].Origin = o
+		}
+	}
+	for i, o := range at("overrides") {
+		if i < len(cfg.Overrides) {
+			cfg.Overrides[i].Origin = o
+		}
+	}
+	for i, o := range at("transforms") {
+		if i < len(cfg.Transforms) {
+			cfg.Transforms[i].Origin = o
+		}
+	}
+	for i, o := range at("tool_definitions") {
+		if i < len(cfg.ToolDefinitions) {
+			cfg.ToolDefinitions[i].Origin = o
+		}
+	}
+	for i, o := range at("signing", "allowed_keys") {
+		if i < len(cfg.Signing.AllowedKeys) {
+			cfg.Signing.AllowedKeys[i].Origin = o
+		}
+	}
+	if n := mappingValue(root, "remove"); n != nil {
+		cfg.Remove.Origin = nodeOrigin(path, n)
+	}
+
+	// Settings and profiles are mappings rather than list entries; record
+	// where their keys were written, e.g. "update.min_age".
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		cfg.setPosition(key.Value, nodeOrigin(path, key))
+		if value.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			cfg.setPosition(key.Value+"."+value.Content[j].Value, nodeOrigin(path, value.Content[j]))
+		}
+	}
+}
+
+// mappingValue returns the value for key in a mapping node, or nil.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
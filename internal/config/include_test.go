@@ -0,0 +1,222 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes each name -> content pair under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadIncludesFragments(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"agent-sync.yaml": `version: 1
+include:
+  - sources/*.yaml
+sources:
+  - name: shared
+    type: local
+    path: ./main/
+targets:
+  - source: shared
+    destination: ./out/
+`,
+		"sources/a.yaml": `sources:
+  - name: a
+    type: local
+    path: ./a/
+  - name: shared
+    type: local
+    path: ./ignored/
+targets:
+  - source: a
+    destination: ./a-out/
+`,
+		"sources/b.yaml": `include:
+  - ../extra/c.yaml
+sources:
+  - name: b
+    type: local
+    path: ./b/
+`,
+		"extra/c.yaml": `variables:
+  team: c
+`,
+	})
+
+	cfg, err := Load(filepath.Join(dir, "agent-sync.yaml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var names []string
+	for _, s := range cfg.Sources {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "a,b,shared" {
+		t.Errorf("sources = %s, want a,b,shared", got)
+	}
+	shared := cfg.Sources[2]
+	if shared.Path != "./main/" {
+		t.Errorf("shared path = %s, the including file should win", shared.Path)
+	}
+	if shared.Origin.Line != 5 || filepath.Base(shared.Origin.File) != "agent-sync.yaml" {
+		t.Errorf("shared origin = %s", shared.Origin)
+	}
+	if a := cfg.Sources[0].Origin; a.Line != 2 || !strings.HasSuffix(a.File, filepath.Join("sources", "a.yaml")) {
+		t.Errorf("a origin = %s", a)
+	}
+	if len(cfg.Targets) != 2 || cfg.Targets[0].Source != "a" {
+		t.Errorf("targets = %+v", cfg.Targets)
+	}
+	if cfg.Variables["team"] != "c" {
+		t.Errorf("variables = %v, want nested fragment merged", cfg.Variables)
+	}
+
+	var files []string
+	for _, f := range cfg.IncludedFiles() {
+		rel, _ := filepath.Rel(dir, f)
+		files = append(files, filepath.ToSlash(rel))
+	}
+	if got := strings.Join(files, ","); got != "sources/a.yaml,sources/b.yaml,extra/c.yaml" {
+		t.Errorf("included files = %s", got)
+	}
+}
+
+func TestLoadIncludeValidationErrorNamesFragment(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"agent-sync.yaml": "version: 1\ninclude: [team.yaml]\n",
+		"team.yaml": `sources:
+  - name: ok
+    type: local
+    path: ./ok/
+  - name: broken
+    type: git
+    repo: https://example.com/r.git
+`,
+	})
+
+	_, err := Load(filepath.Join(dir, "agent-sync.yaml"))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	want := filepath.Join(dir, "team.yaml") + ":5:5: source 'broken': type 'git' requires 'ref'"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want it to contain %q", err, want)
+	}
+}
+
+func TestLoadIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"agent-sync.yaml": "version: 1\ninclude: [a.yaml]\n",
+				"a.yaml":          "include: [b.yaml]\n",
+				"b.yaml":          "include: [a.yaml]\n",
+			},
+			want: "include cycle: ",
+		},
+		{
+			name: "self",
+			files: map[string]string{
+				"agent-sync.yaml": "version: 1\ninclude: ['*.yaml']\n",
+			},
+			want: "include cycle",
+		},
+		{
+			name: "included twice",
+			files: map[string]string{
+				"agent-sync.yaml": "version: 1\ninclude: [a.yaml, b.yaml]\n",
+				"a.yaml":          "include: [c.yaml]\n",
+				"b.yaml":          "include: [c.yaml]\n",
+				"c.yaml":          "variables: {x: y}\n",
+			},
+			want: "is already included by",
+		},
+		{
+			name: "outside directory",
+			files: map[string]string{
+				"project/agent-sync.yaml": "version: 1\ninclude: [../secret.yaml]\n",
+				"secret.yaml":             "variables: {x: y}\n",
+			},
+			want: "outside the config directory",
+		},
+		{
+			name: "absolute",
+			files: map[string]string{
+				"agent-sync.yaml": "version: 1\ninclude: [/etc/agent-sync.yaml]\n",
+			},
+			want: "must be a relative path",
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"agent-sync.yaml": "version: 1\ninclude: [missing.yaml]\n",
+			},
+			want: "agent-sync.yaml:2:11: include 'missing.yaml': reading config",
+		},
+		{
+			name: "version mismatch",
+			files: map[string]string{
+				"agent-sync.yaml": "version: 1\ninclude: [a.yaml]\n",
+				"a.yaml":          "version: 2\n",
+			},
+			want: "version mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			path := filepath.Join(dir, "agent-sync.yaml")
+			if _, ok := tt.files["project/agent-sync.yaml"]; ok {
+				path = filepath.Join(dir, "project", "agent-sync.yaml")
+			}
+			_, err := Parse(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadIncludeGlobWithoutMatches(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"agent-sync.yaml": `version: 1
+include: [fragments/*.yaml]
+sources:
+  - name: s
+    type: local
+    path: ./s/
+`,
+	})
+	cfg, err := Load(filepath.Join(dir, "agent-sync.yaml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.IncludedFiles()) != 0 {
+		t.Errorf("included files = %v", cfg.IncludedFiles())
+	}
+}
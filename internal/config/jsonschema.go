@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+//go:generate go run ../../cmd/agent-sync config schema -o ../../docs/reference/agent-sync.schema.json
+
+// JSONSchemaURL is where the JSON Schema for agent-sync.yaml is published.
+const JSONSchemaURL = "https://bianoble.github.io/agent-sync/reference/agent-sync.schema.json"
+
+// schemaEnums lists the allowed values of string fields, by type and field.
+var schemaEnums = map[string][]string{
+	"Source.type":       {"git", "url", "local"},
+	"Override.strategy": {"append", "prepend", "replace"},
+	"Transform.type":    {"template", "custom"},
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) for agent-sync.yaml,
+// generated from the config types, for editor completion and validation.
+// No top-level field is required, since included fragments and system and
+// user layers hold partial configs; loading the config checks the rest.
+func JSONSchema() ([]byte, error) {
+	g := &schemaGenerator{defs: make(map[string]any)}
+	root := g.object(reflect.TypeOf(Config{}), false)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = JSONSchemaURL
+	root["title"] = "agent-sync.yaml"
+	root["$defs"] = g.defs
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+// object describes a struct type as a closed JSON object.
+func (g *schemaGenerator) object(t reflect.Type, required bool) map[string]any {
+	props := make(map[string]any)
+	var names []string
+	for _, f := range yamlFields(t) {
+		if f.Owner == reflect.TypeOf(Config{}) && f.Name == "variables" {
+			props[f.Name] = map[string]any{
+				"type": "object",
+				"additionalProperties": map[string]any{
+					"oneOf": []any{map[string]any{"type": "string"}, g.ref(reflect.TypeOf(variableSpec{}))},
+				},
+			}
+			continue
+		}
+		s := g.schema(f.Type)
+		if enum, ok := schemaEnums[f.Owner.Name()+"."+f.Name]; ok {
+			s = map[string]any{"type": "string", "enum": enum}
+		}
+		props[f.Name] = s
+		if required && f.Required {
+			names = append(names, f.Name)
+		}
+	}
+	obj := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(names) > 0 {
+		obj["required"] = names
+	}
+	return obj
+}
+
+// ref returns a reference to the definition of struct type t, adding it
+// on first use.
+func (g *schemaGenerator) ref(t reflect.Type) map[string]any {
+	name := t.Name()
+	if t == reflect.TypeOf(variableSpec{}) {
+		name = "Variable"
+	}
+	if _, ok := g.defs[name]; !ok {
+		g.defs[name] = nil // reserve the name for recursive types
+		g.defs[name] = g.object(t, true)
+	}
+	return map[string]any{"$ref": "#/$defs/" + name}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.ref(t)
+	}
+	return map[string]any{}
+}
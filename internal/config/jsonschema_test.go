@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestJSONSchemaIsPublished(t *testing.T) {
+	data, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema: %v", err)
+	}
+	published, err := os.ReadFile("../../docs/reference/agent-sync.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, published) {
+		t.Error("docs/reference/agent-sync.schema.json is out of date — run 'go generate ./internal/config'")
+	}
+}
+
+func TestJSONSchemaDescribesConfig(t *testing.T) {
+	data, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema: %v", err)
+	}
+	var schema struct {
+		Properties map[string]any `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]map[string]any `json:"properties"`
+			Required   []string                  `json:"required"`
+		} `json:"$defs"`
+		AdditionalProperties bool `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	if schema.AdditionalProperties {
+		t.Error("top level allows unknown fields")
+	}
+	for _, key := range []string{"version", "sources", "targets", "variables", "profiles", "remove", "include"} {
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("missing top-level property %q", key)
+		}
+	}
+	source := schema.Defs["Source"]
+	if enum := source.Properties["type"]["enum"]; enum == nil {
+		t.Error("Source.type has no enum")
+	}
+	if got := source.Required; len(got) != 2 || got[0] != "name" || got[1] != "type" {
+		t.Errorf("Source required = %v, want [name type]", got)
+	}
+	if _, ok := schema.Defs["Variable"].Properties["enforced"]; !ok {
+		t.Error("Variable has no 'enforced' property")
+	}
+}
@@ -3,47 +3,43 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
-
-	"gopkg.in/yaml.v3"
 )
 
 // Load reads and validates an agent-sync.yaml configuration file.
 // This loads a single file with full validation — use LoadHierarchical
-// for system/user/project merging.
+// for system/user/project merging. Older schema versions are upgraded in
+// memory; see Config.Migrations. Included fragments are merged in; see
+// Config.IncludedFiles.
+//
+// Variable providers and ${...} references are resolved relative to the
+// directory of path, without running command providers.
 func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading config %s: %w", path, err)
-	}
+	return load(path, interpolateOptions{root: filepath.Dir(path)})
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+func load(path string, opts interpolateOptions) (*Config, error) {
+	cfg, err := parseFile(path)
+	if err != nil {
+		return nil, err
 	}
 
-	if errs := Validate(&cfg); len(errs) > 0 {
+	errs := cfg.interpolate(opts)
+	if errs = append(errs, Validate(cfg)...); len(errs) > 0 {
 		return nil, &ValidationError{Errors: errs}
 	}
 
-	return &cfg, nil
+	return cfg, nil
 }
 
 // Parse reads a config file without validation.
 // Used for loading system/user layers that may be incomplete on their own.
+// Older schema versions are upgraded and includes merged, as in Load.
 func Parse(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading config %s: %w", path, err)
-	}
-
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parsing config %s: %w", path, err)
-	}
-
-	return &cfg, nil
+	return parseFile(path)
 }
 
 // HierarchicalOptions configures hierarchical config loading.
@@ -61,6 +57,20 @@ type HierarchicalOptions struct {
 
 	// NoInherit disables hierarchy; loads only ProjectPath.
 	NoInherit bool
+
+	// AllowCommands lets command variable providers run. They are off by
+	// default because loading a config should not execute anything. It also
+	// lets file providers in the project config read outside the project.
+	AllowCommands bool
+
+	// Written returns the merged config as written: ${...} references and
+	// variable providers are left unresolved, and the result is not
+	// validated. Used to show the config rather than to act on it.
+	Written bool
+
+	// ProjectData, if not nil, is used as the content of ProjectPath, so
+	// that an edit can be validated before it is written.
+	ProjectData []byte
 }
 
 // HierarchicalResult holds the merged config and metadata about which layers were loaded.
@@ -69,22 +79,32 @@ type HierarchicalResult struct {
 	Layers []ConfigLayerInfo
 }
 
-// LoadHierarchical discovers, loads, merges, and validates configs
+// LoadHierarchical discovers, loads, merges, interpolates, and validates configs
 // from system, user, and project levels.
 //
 // Missing system/user configs are silently skipped. A missing project
 // config is a fatal error. Existing files with parse errors are fatal.
 // Version mismatches across layers are fatal.
 func LoadHierarchical(opts HierarchicalOptions) (*HierarchicalResult, error) {
+	interp := interpolateOptions{root: filepath.Dir(opts.ProjectPath), allowCommands: opts.AllowCommands}
 	if opts.NoInherit {
-		cfg, err := Load(opts.ProjectPath)
+		cfg, err := parseData(opts.ProjectPath, opts.ProjectData)
 		if err != nil {
 			return nil, err
 		}
+		if !opts.Written {
+			errs := cfg.interpolate(interp)
+			if errs = append(errs, Validate(cfg)...); len(errs) > 0 {
+				return nil, &ValidationError{Errors: errs}
+			}
+		}
+		if errs := checkNotEnforced(cfg); len(errs) > 0 {
+			return nil, &ValidationError{Errors: errs}
+		}
 		return &HierarchicalResult{
 			Config: cfg,
 			Layers: []ConfigLayerInfo{
-				{Path: opts.ProjectPath, Level: LevelProject, Loaded: true},
+				{Path: opts.ProjectPath, Level: LevelProject, Loaded: true, Includes: cfg.IncludedFiles()},
 			},
 		}, nil
 	}
@@ -99,7 +119,11 @@ func LoadHierarchical(opts HierarchicalOptions) (*HierarchicalResult, error) {
 	for i := range layers {
 		layer := &layers[i]
 
-		cfg, err := Parse(layer.Path)
+		var data []byte
+		if layer.Level == LevelProject {
+			data = opts.ProjectData
+		}
+		cfg, err := parseData(layer.Path, data)
 		if err != nil {
 			if errors.Is(err, os.ErrNotExist) && layer.Level != LevelProject {
 				// Missing system/user config is fine; skip silently.
@@ -117,7 +141,15 @@ func LoadHierarchical(opts HierarchicalOptions) (*HierarchicalResult, error) {
 			return nil, layer.Err
 		}
 
+		if layer.Level == LevelProject {
+			if errs := checkNotEnforced(cfg); len(errs) > 0 {
+				return nil, &ValidationError{Errors: errs}
+			}
+		}
+
+		cfg.setLayer(layer.Level)
 		layer.Loaded = true
+		layer.Includes = cfg.IncludedFiles()
 		configs = append(configs, cfg)
 	}
 
@@ -131,8 +163,18 @@ func LoadHierarchical(opts HierarchicalOptions) (*HierarchicalResult, error) {
 		return nil, err
 	}
 
-	// Validate the merged result.
-	if errs := Validate(merged); len(errs) > 0 {
+	var notes []string
+	for _, cfg := range configs {
+		notes = append(notes, cfg.migrations...)
+	}
+	merged.migrations = notes
+	if opts.Written {
+		return &HierarchicalResult{Config: merged, Layers: layers}, nil
+	}
+
+	// Resolve variables, then validate the merged result.
+	errs := merged.interpolate(interp)
+	if errs = append(errs, Validate(merged)...); len(errs) > 0 {
 		return nil, &ValidationError{Errors: errs}
 	}
 
@@ -144,26 +186,61 @@ func LoadHierarchical(opts HierarchicalOptions) (*HierarchicalResult, error) {
 
 // ValidationError holds multiple validation failures.
 type ValidationError struct {
-	Errors []string
+	Errors []FieldError
 }
 
 func (e *ValidationError) Error() string {
-	return fmt.Sprintf("config validation failed:\n  - %s", strings.Join(e.Errors, "\n  - "))
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("config validation failed:\n  - %s", strings.Join(msgs, "\n  - "))
+}
+
+// FieldError is one validation failure.
+type FieldError struct {
+	// Path is the field path of the entry or setting at fault, as in
+	// 'config show --origin' output: "sources[0]", "cache.max_size",
+	// "variables.token". It is empty when the failure is not about one
+	// entry or the entry's position in the merged config is not known.
+	Path string
+
+	// Message describes the failure. It names the entry in words, such as
+	// "source 'rules': ...", but not where it was written.
+	Message string
+
+	// Origin is where the entry or setting was written, or the zero Origin
+	// if that is not known.
+	Origin Origin
+}
+
+// Error returns the message prefixed with the origin, if known.
+func (e FieldError) Error() string {
+	return e.Origin.at(e.Message)
+}
+
+// fieldError returns a FieldError for the entry or setting at path,
+// written at o.
+func fieldError(o Origin, path, format string, args ...any) FieldError {
+	return FieldError{Origin: o, Path: path, Message: fmt.Sprintf(format, args...)}
 }
 
 // Validate checks a Config for semantic correctness.
-// Returns a list of validation error messages (empty if valid).
-func Validate(cfg *Config) []string {
-	var errs []string
+// Returns a list of validation failures (empty if valid).
+func Validate(cfg *Config) []FieldError {
+	var errs []FieldError
 
 	// Version (Section 14).
-	if cfg.Version != 1 {
-		errs = append(errs, fmt.Sprintf("unsupported version %d — only version 1 is supported", cfg.Version))
+	at := cfg.position("version")
+	if cfg.Version > CurrentVersion {
+		errs = append(errs, fieldError(at, "version", "unsupported version %d — this agent-sync supports up to version %d; upgrade agent-sync to use this config", cfg.Version, CurrentVersion))
+	} else if cfg.Version != CurrentVersion {
+		errs = append(errs, fieldError(at, "version", "unsupported version %d — only version %d is supported", cfg.Version, CurrentVersion))
 	}
 
 	// Sources.
 	if len(cfg.Sources) == 0 {
-		errs = append(errs, "at least one source is required")
+		errs = append(errs, fieldError(Origin{}, "sources", "at least one source is required"))
 	}
 
 	sourceNames := make(map[string]bool)
@@ -172,16 +249,17 @@ func Validate(cfg *Config) []string {
 		if src.Name != "" {
 			prefix = fmt.Sprintf("source '%s'", src.Name)
 		}
+		at, path := src.Origin, fmt.Sprintf("sources[%d]", i)
 
 		if src.Name == "" {
-			errs = append(errs, fmt.Sprintf("%s: 'name' is required", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'name' is required", prefix))
 		} else if sourceNames[src.Name] {
-			errs = append(errs, fmt.Sprintf("%s: duplicate source name '%s'", prefix, src.Name))
+			errs = append(errs, fieldError(at, path, "%s: duplicate source name '%s'", prefix, src.Name))
 		} else {
 			sourceNames[src.Name] = true
 		}
 
-		errs = append(errs, validateSource(src, prefix)...)
+		errs = append(errs, validateSource(src, at, path, prefix)...)
 	}
 
 	// Targets (Section 7.3).
@@ -190,18 +268,19 @@ func Validate(cfg *Config) []string {
 		if tgt.Source != "" {
 			prefix = fmt.Sprintf("target for source '%s'", tgt.Source)
 		}
+		at, path := tgt.Origin, fmt.Sprintf("targets[%d]", i)
 
 		if tgt.Source == "" {
-			errs = append(errs, fmt.Sprintf("%s: 'source' is required", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'source' is required", prefix))
 		} else if !sourceNames[tgt.Source] {
-			errs = append(errs, fmt.Sprintf("%s: references undefined source '%s'", prefix, tgt.Source))
+			errs = append(errs, fieldError(at, path, "%s: references undefined source '%s'", prefix, tgt.Source))
 		}
 
 		if len(tgt.Tools) > 0 && tgt.Destination != "" {
-			errs = append(errs, fmt.Sprintf("%s: 'tools' and 'destination' are mutually exclusive — use one or the other", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'tools' and 'destination' are mutually exclusive — use one or the other", prefix))
 		}
 		if len(tgt.Tools) == 0 && tgt.Destination == "" {
-			errs = append(errs, fmt.Sprintf("%s: one of 'tools' or 'destination' is required", prefix))
+			errs = append(errs, fieldError(at, path, "%s: one of 'tools' or 'destination' is required", prefix))
 		}
 	}
 
@@ -211,21 +290,22 @@ func Validate(cfg *Config) []string {
 		if ov.Target != "" {
 			prefix = fmt.Sprintf("override for '%s'", ov.Target)
 		}
+		at, path := ov.Origin, fmt.Sprintf("overrides[%d]", i)
 
 		if ov.Target == "" {
-			errs = append(errs, fmt.Sprintf("%s: 'target' is required", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'target' is required", prefix))
 		}
 		if ov.File == "" {
-			errs = append(errs, fmt.Sprintf("%s: 'file' is required", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'file' is required", prefix))
 		}
 
 		switch ov.Strategy {
 		case "append", "prepend", "replace":
 			// valid
 		case "":
-			errs = append(errs, fmt.Sprintf("%s: 'strategy' is required — must be one of: append, prepend, replace", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'strategy' is required — must be one of: append, prepend, replace", prefix))
 		default:
-			errs = append(errs, fmt.Sprintf("%s: invalid strategy '%s' — must be one of: append, prepend, replace", prefix, ov.Strategy))
+			errs = append(errs, fieldError(at, path, "%s: invalid strategy '%s' — must be one of: append, prepend, replace", prefix, ov.Strategy))
 		}
 	}
 
@@ -235,11 +315,12 @@ func Validate(cfg *Config) []string {
 		if tx.Source != "" {
 			prefix = fmt.Sprintf("transform for source '%s'", tx.Source)
 		}
+		at, path := tx.Origin, fmt.Sprintf("transforms[%d]", i)
 
 		if tx.Source == "" {
-			errs = append(errs, fmt.Sprintf("%s: 'source' is required", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'source' is required", prefix))
 		} else if !sourceNames[tx.Source] {
-			errs = append(errs, fmt.Sprintf("%s: references undefined source '%s'", prefix, tx.Source))
+			errs = append(errs, fieldError(at, path, "%s: references undefined source '%s'", prefix, tx.Source))
 		}
 
 		switch tx.Type {
@@ -247,55 +328,110 @@ func Validate(cfg *Config) []string {
 			// vars is optional
 		case "custom":
 			if tx.Command == "" {
-				errs = append(errs, fmt.Sprintf("%s: custom transform requires 'command'", prefix))
+				errs = append(errs, fieldError(at, path, "%s: custom transform requires 'command'", prefix))
 			}
 		case "":
-			errs = append(errs, fmt.Sprintf("%s: 'type' is required — must be one of: template, custom", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'type' is required — must be one of: template, custom", prefix))
 		default:
-			errs = append(errs, fmt.Sprintf("%s: invalid type '%s' — must be one of: template, custom", prefix, tx.Type))
+			errs = append(errs, fieldError(at, path, "%s: invalid type '%s' — must be one of: template, custom", prefix, tx.Type))
 		}
 	}
 
+	// Update settings.
+	if cfg.Update.MinAge != "" {
+		if _, err := ParseAge(cfg.Update.MinAge); err != nil {
+			errs = append(errs, fieldError(cfg.position("update.min_age"), "update.min_age", "update: invalid min_age: %s", err))
+		}
+	}
+
+	// Cache settings.
+	if cfg.Cache.MaxSize != "" {
+		if _, err := ParseSize(cfg.Cache.MaxSize); err != nil {
+			errs = append(errs, fieldError(cfg.position("cache.max_size"), "cache.max_size", "cache: invalid max_size: %s", err))
+		}
+	}
+	// Signing policy.
+	keyNames := make(map[string]bool)
+	for i, k := range cfg.Signing.AllowedKeys {
+		prefix := fmt.Sprintf("signing: allowed_keys[%d]", i)
+		if k.Name != "" {
+			prefix = fmt.Sprintf("signing: allowed key '%s'", k.Name)
+		}
+		at, path := k.Origin, fmt.Sprintf("signing.allowed_keys[%d]", i)
+		if k.Name == "" {
+			errs = append(errs, fieldError(at, path, "%s: 'name' is required", prefix))
+		} else if keyNames[k.Name] {
+			errs = append(errs, fieldError(at, path, "%s: duplicate key name '%s'", prefix, k.Name))
+		} else {
+			keyNames[k.Name] = true
+		}
+		if !strings.HasPrefix(k.PublicKey, "ssh-ed25519 ") {
+			errs = append(errs, fieldError(at, path, "%s: 'public_key' must be an ed25519 key in 'ssh-ed25519 AAAA...' form", prefix))
+		}
+	}
+	if cfg.Signing.Require && len(cfg.Signing.AllowedKeys) == 0 {
+		errs = append(errs, fieldError(cfg.position("signing.require"), "signing.require", "signing: 'require' is set but no allowed_keys are listed"))
+	}
+
+	if cfg.Cache.RemoteCache != "" {
+		if u, err := url.Parse(cfg.Cache.RemoteCache); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fieldError(cfg.position("cache.remote_cache"), "cache.remote_cache", "cache: remote_cache must be an http:// or https:// URL, got %q", cfg.Cache.RemoteCache))
+		}
+	}
+
+	// Profiles.
+	errs = append(errs, validateProfiles(cfg, sourceNames)...)
+	errs = append(errs, validateEnforcedProfiles(cfg)...)
+
 	// Tool definitions.
 	for i, td := range cfg.ToolDefinitions {
 		prefix := fmt.Sprintf("tool_definition[%d]", i)
+		at, path := td.Origin, fmt.Sprintf("tool_definitions[%d]", i)
 		if td.Name == "" {
-			errs = append(errs, fmt.Sprintf("%s: 'name' is required", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'name' is required", prefix))
 		}
 		if td.Destination == "" {
-			errs = append(errs, fmt.Sprintf("%s: 'destination' is required", prefix))
+			errs = append(errs, fieldError(at, path, "%s: 'destination' is required", prefix))
 		}
 	}
 
 	return errs
 }
 
-func validateSource(src Source, prefix string) []string {
-	var errs []string
+func validateSource(src Source, at Origin, path, prefix string) []FieldError {
+	var errs []FieldError
 
 	switch src.Type {
 	case "git":
 		if src.Repo == "" {
-			errs = append(errs, fmt.Sprintf("%s: type 'git' requires 'repo' — add 'repo: https://...' to the source definition", prefix))
+			errs = append(errs, fieldError(at, path, "%s: type 'git' requires 'repo' — add 'repo: https://...' to the source definition", prefix))
 		}
 		if src.Ref == "" {
-			errs = append(errs, fmt.Sprintf("%s: type 'git' requires 'ref' — add 'ref: <tag-or-branch>' to the source definition", prefix))
+			errs = append(errs, fieldError(at, path, "%s: type 'git' requires 'ref' — add 'ref: <tag-or-branch>' to the source definition", prefix))
 		}
 	case "url":
 		if src.URL == "" {
-			errs = append(errs, fmt.Sprintf("%s: type 'url' requires 'url' — add 'url: https://...' to the source definition", prefix))
+			errs = append(errs, fieldError(at, path, "%s: type 'url' requires 'url' — add 'url: https://...' to the source definition", prefix))
 		}
 		if src.Checksum == "" {
-			errs = append(errs, fmt.Sprintf("%s: type 'url' requires 'checksum' — add 'checksum: sha256:<hex>' to the source definition", prefix))
+			errs = append(errs, fieldError(at, path, "%s: type 'url' requires 'checksum' — add 'checksum: sha256:<hex>' to the source definition", prefix))
 		}
 	case "local":
 		if src.Path == "" {
-			errs = append(errs, fmt.Sprintf("%s: type 'local' requires 'path' — add 'path: ./relative/path/' to the source definition", prefix))
+			errs = append(errs, fieldError(at, path, "%s: type 'local' requires 'path' — add 'path: ./relative/path/' to the source definition", prefix))
 		}
 	case "":
-		errs = append(errs, fmt.Sprintf("%s: 'type' is required — must be one of: git, url, local", prefix))
+		errs = append(errs, fieldError(at, path, "%s: 'type' is required — must be one of: git, url, local", prefix))
 	default:
-		errs = append(errs, fmt.Sprintf("%s: unknown source type '%s' — must be one of: git, url, local", prefix, src.Type))
+		errs = append(errs, fieldError(at, path, "%s: unknown source type '%s' — must be one of: git, url, local", prefix, src.Type))
+	}
+
+	if src.MinAge != "" {
+		if src.Type != "git" {
+			errs = append(errs, fieldError(at, path, "%s: 'min_age' is only supported for git sources", prefix))
+		} else if _, err := ParseAge(src.MinAge); err != nil {
+			errs = append(errs, fieldError(at, path, "%s: invalid min_age: %s", prefix, err))
+		}
 	}
 
 	return errs
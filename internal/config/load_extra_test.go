@@ -5,6 +5,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/bianoble/agent-sync/internal/schema"
+	"gopkg.in/yaml.v3"
 )
 
 func TestEnvNoInherit(t *testing.T) {
@@ -34,6 +37,17 @@ func TestEnvNoInherit(t *testing.T) {
 	}
 }
 
+func TestEnvOffline(t *testing.T) {
+	t.Setenv("AGENT_SYNC_OFFLINE", "1")
+	if !EnvOffline() {
+		t.Error("EnvOffline() = false with AGENT_SYNC_OFFLINE=1")
+	}
+	t.Setenv("AGENT_SYNC_OFFLINE", "")
+	if EnvOffline() {
+		t.Error("EnvOffline() = true with AGENT_SYNC_OFFLINE unset")
+	}
+}
+
 func TestParseValidYAML(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "config.yaml")
@@ -316,3 +330,73 @@ func TestDefaultUserConfigPathContainsConfigDir(t *testing.T) {
 		t.Errorf("path should contain %q: %s", configDirName, path)
 	}
 }
+
+func TestLoadMigratesOlderVersion(t *testing.T) {
+	old := migrations
+	migrations = []schema.Migration{{
+		From:    0,
+		Summary: "renamed 'repos' to 'sources'",
+		Apply: func(root *yaml.Node) error {
+			for i := 0; i < len(root.Content); i += 2 {
+				if root.Content[i].Value == "repos" {
+					root.Content[i].Value = "sources"
+				}
+			}
+			return nil
+		},
+	}}
+	defer func() { migrations = old }()
+
+	path := filepath.Join(t.TempDir(), "agent-sync.yaml")
+	data := `# team config
+version: 0
+repos:
+  - name: s
+    type: local
+    path: ./a/ # shared rules
+targets:
+  - source: s
+    destination: ./out/
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Version != CurrentVersion || len(cfg.Sources) != 1 {
+		t.Errorf("expected migrated config, got version %d with %d source(s)", cfg.Version, len(cfg.Sources))
+	}
+	if notes := cfg.Migrations(); len(notes) != 1 || !strings.Contains(notes[0], "renamed 'repos' to 'sources'") {
+		t.Errorf("migrations = %q", notes)
+	}
+
+	out, notes, err := Migrate(path)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(notes) != 1 {
+		t.Errorf("notes = %q", notes)
+	}
+	for _, want := range []string{"# team config", "version: 1", "sources:", "# shared rules"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("migrated config missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent-sync.yaml")
+	if err := os.WriteFile(path, []byte("version: 2\nsources: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "upgrade agent-sync") {
+		t.Errorf("expected newer-version error, got %v", err)
+	}
+	if data, _, err := Migrate(path); err != nil || data != nil {
+		t.Errorf("Migrate should leave a newer file alone, got data=%q err=%v", data, err)
+	}
+}
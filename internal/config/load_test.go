@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -299,16 +300,43 @@ func TestValidateValidConfig(t *testing.T) {
 }
 
 func TestValidationErrorFormat(t *testing.T) {
-	verr := &ValidationError{Errors: []string{"error one", "error two"}}
-	msg := verr.Error()
-	if !strings.Contains(msg, "error one") || !strings.Contains(msg, "error two") {
-		t.Errorf("error message missing details: %s", msg)
+	verr := &ValidationError{Errors: []FieldError{
+		{Message: "error one"},
+		{Path: "sources[0]", Message: "source 's': error two", Origin: Origin{File: "a.yaml", Line: 3, Column: 5, Layer: LevelUser}},
+	}}
+	want := "config validation failed:\n  - error one\n  - a.yaml:3:5 (user config): source 's': error two"
+	if msg := verr.Error(); msg != want {
+		t.Errorf("Error() = %q, want %q", msg, want)
 	}
 }
 
-func containsSubstring(errs []string, substr string) bool {
+func TestValidationErrorPositions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"agent-sync.yaml": `version: 1
+sources:
+  - name: s
+    type: local
+    path: ./a/
+targets:
+  - source: s
+    destination: out/
+cache:
+  max_size: huge
+`})
+	_, err := Load(filepath.Join(dir, "agent-sync.yaml"))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 {
+		t.Fatalf("err = %v, want one validation error", err)
+	}
+	fe := verr.Errors[0]
+	if fe.Path != "cache.max_size" || fe.Origin.Line != 10 || fe.Origin.Column != 3 || !strings.HasPrefix(fe.Message, "cache: invalid max_size") {
+		t.Errorf("error = %+v", fe)
+	}
+}
+
+func containsSubstring(errs []FieldError, substr string) bool {
 	for _, e := range errs {
-		if strings.Contains(e, substr) {
+		if strings.Contains(e.Error(), substr) {
 			return true
 		}
 	}
@@ -5,10 +5,19 @@ import "fmt"
 // Merge combines two configs where overlay takes precedence over base.
 // This implements the hierarchical merge semantics:
 //   - version: must agree if both declare it (non-zero); fatal error on mismatch
-//   - variables: deep merge, overlay keys win
+//   - variables: deep merge, overlay keys win (literals and providers alike)
 //   - sources: merge by name — same name in overlay replaces base entry entirely
 //   - tool_definitions: merge by name — same name in overlay replaces base entry
+//   - profiles: merge by name — same name in overlay replaces base profile
+//   - cache, sync, update: field by field, overlay wins when set
+//   - signing: require if any layer requires; allowed_keys merge by name,
+//     but overlay may not replace base's keys, nor add keys if base requires
+//     signatures (see checkSigningKeys)
 //   - targets, overrides, transforms: concatenate (base first, then overlay)
+//
+// Before merging, entries listed in overlay's remove are dropped from base.
+// Overlay may not replace, remove, or override entries base marks enforced;
+// that is a *ValidationError naming both files.
 func Merge(base, overlay *Config) (*Config, error) {
 	if base == nil {
 		return overlay, nil
@@ -24,15 +33,59 @@ func Merge(base, overlay *Config) (*Config, error) {
 		return nil, err
 	}
 
-	// Variables: deep merge with overlay winning.
+	if errs := checkEnforced(base, overlay); len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	result.Remove = base.Remove.union(overlay.Remove)
+	base = overlay.Remove.apply(base)
+
+	// Variables: deep merge with overlay winning, whether a variable is a
+	// literal or a provider.
 	result.Variables = mergeVariables(base.Variables, overlay.Variables)
+	result.providers = mergeProviders(base.providers, overlay.providers)
+	for name := range overlay.providers {
+		delete(result.Variables, name)
+	}
+	for name := range overlay.Variables {
+		delete(result.providers, name)
+	}
+	result.varDefs = mergeVarDefs(base.varDefs, overlay.varDefs)
+	result.positions = mergePositions(base.positions, overlay.positions)
 
 	// Sources: merge by name.
 	result.Sources = mergeNamedSources(base.Sources, overlay.Sources)
 
+	// Profiles: merge by name.
+	result.Profiles = mergeProfiles(base.Profiles, overlay.Profiles)
+
 	// ToolDefinitions: merge by name.
 	result.ToolDefinitions = mergeNamedToolDefs(base.ToolDefinitions, overlay.ToolDefinitions)
 
+	// Cache settings: overlay wins when set.
+	result.Cache = base.Cache
+	if overlay.Cache.MaxSize != "" {
+		result.Cache.MaxSize = overlay.Cache.MaxSize
+	}
+	if overlay.Cache.RemoteCache != "" {
+		result.Cache.RemoteCache = overlay.Cache.RemoteCache
+	}
+
+	// Sync settings: overlay wins when set.
+	result.Sync = base.Sync
+	if overlay.Sync.Atomic != nil {
+		result.Sync.Atomic = overlay.Sync.Atomic
+	}
+
+	// Update settings: overlay wins when set.
+	result.Update = base.Update
+	if overlay.Update.MinAge != "" {
+		result.Update.MinAge = overlay.Update.MinAge
+	}
+
+	// Signing: any layer can require signatures; allowed keys merge by name.
+	result.Signing.Require = base.Signing.Require || overlay.Signing.Require
+	result.Signing.AllowedKeys = mergeNamedAllowedKeys(base.Signing.AllowedKeys, overlay.Signing.AllowedKeys)
+
 	// Targets: concatenate.
 	result.Targets = append(result.Targets, base.Targets...)
 	result.Targets = append(result.Targets, overlay.Targets...)
@@ -97,6 +150,51 @@ func mergeVariables(base, overlay map[string]string) map[string]string {
 	return result
 }
 
+func mergeProviders(base, overlay map[string]VariableProvider) map[string]VariableProvider {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+
+	result := make(map[string]VariableProvider, len(base)+len(overlay))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range overlay {
+		result[k] = v
+	}
+	return result
+}
+
+func mergeVarDefs(base, overlay map[string]variableDef) map[string]variableDef {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+
+	result := make(map[string]variableDef, len(base)+len(overlay))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range overlay {
+		result[k] = v
+	}
+	return result
+}
+
+func mergeProfiles(base, overlay map[string]Profile) map[string]Profile {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+
+	result := make(map[string]Profile, len(base)+len(overlay))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range overlay {
+		result[k] = v // a profile in overlay replaces the base profile entirely
+	}
+	return result
+}
+
 func mergeNamedSources(base, overlay []Source) []Source {
 	if len(base) == 0 {
 		return overlay
@@ -149,3 +247,26 @@ func mergeNamedToolDefs(base, overlay []ToolDefinition) []ToolDefinition {
 
 	return result
 }
+
+func mergeNamedAllowedKeys(base, overlay []AllowedKey) []AllowedKey {
+	if len(base) == 0 {
+		return overlay
+	}
+	if len(overlay) == 0 {
+		return base
+	}
+
+	overlayNames := make(map[string]bool, len(overlay))
+	for _, k := range overlay {
+		overlayNames[k.Name] = true
+	}
+
+	var result []AllowedKey
+	for _, k := range base {
+		if !overlayNames[k.Name] {
+			result = append(result, k)
+		}
+	}
+
+	return append(result, overlay...)
+}
@@ -148,3 +148,54 @@ func TestMergeAllSingle(t *testing.T) {
 		t.Errorf("variables[k] = %q, want v", merged.Variables["k"])
 	}
 }
+
+func TestMergeSyncAtomic(t *testing.T) {
+	on, off := true, false
+	system := &Config{Sync: SyncSettings{Atomic: &on}}
+
+	merged, err := Merge(system, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !merged.Sync.IsAtomic() {
+		t.Error("atomic set in a lower layer should be inherited")
+	}
+
+	merged, err = Merge(system, &Config{Sync: SyncSettings{Atomic: &off}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Sync.IsAtomic() {
+		t.Error("an explicit atomic: false in a higher layer should win")
+	}
+
+	if (SyncSettings{}).IsAtomic() {
+		t.Error("atomic should default to off")
+	}
+}
+
+func TestMergeSigning(t *testing.T) {
+	system := &Config{Signing: SigningSettings{
+		Require:     true,
+		AllowedKeys: []AllowedKey{{Name: "release", PublicKey: "ssh-ed25519 AAAA1"}, {Name: "ci", PublicKey: "ssh-ed25519 AAAA2"}},
+	}}
+	project := &Config{Signing: SigningSettings{
+		AllowedKeys: []AllowedKey{{Name: "ci", PublicKey: "ssh-ed25519 AAAA3"}},
+	}}
+
+	merged, err := Merge(system, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !merged.Signing.Require {
+		t.Error("a lower layer's require must not be dropped by a higher layer")
+	}
+	if len(merged.Signing.AllowedKeys) != 2 {
+		t.Fatalf("allowed keys = %v, want release and overridden ci", merged.Signing.AllowedKeys)
+	}
+	for _, k := range merged.Signing.AllowedKeys {
+		if k.Name == "ci" && k.PublicKey != "ssh-ed25519 AAAA3" {
+			t.Errorf("ci key = %q, want project override", k.PublicKey)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/bianoble/agent-sync/internal/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config schema version this release reads natively.
+const CurrentVersion = 1
+
+// migrations upgrade older config versions to CurrentVersion, one version
+// per step. Every breaking schema change adds an entry (spec Section 14.3).
+var migrations []schema.Migration
+
+// Migrations returns a note for each schema migration applied in memory
+// while loading the config.
+func (c *Config) Migrations() []string {
+	return c.migrations
+}
+
+// decode parses a config document, upgrading older schema versions in memory.
+func decode(path string, data []byte) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+
+	var cfg Config
+	if doc.Kind == 0 {
+		return &cfg, nil
+	}
+	result, err := schema.Upgrade(&doc, migrations)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	if len(doc.Content) > 0 {
+		// A newer version may add fields; validation reports the version.
+		if result.To <= CurrentVersion {
+			if errs := checkFields(path, doc.Content[0], reflect.TypeOf(cfg), ""); len(errs) > 0 {
+				return nil, &ValidationError{Errors: errs}
+			}
+		}
+		if err := extractVariables(&cfg, path, doc.Content[0]); err != nil {
+			return nil, err
+		}
+	}
+	if err := doc.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	cfg.migrations = result.Notes(path)
+	if len(doc.Content) > 0 {
+		setOrigins(&cfg, path, doc.Content[0])
+	}
+	return &cfg, nil
+}
+
+// Migrate upgrades the config file at path to CurrentVersion and returns
+// the rewritten document, preserving comments. If the file needs no
+// migration, data is nil.
+func Migrate(path string) (data []byte, notes []string, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		return nil, nil, nil
+	}
+
+	result, err := schema.Upgrade(&doc, migrations)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	if !result.Migrated() {
+		return nil, nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("encoding config %s: %w", path, err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("encoding config %s: %w", path, err)
+	}
+
+	// Make sure the result is a valid config before handing it back.
+	if _, err := decode(path, buf.Bytes()); err != nil {
+		return nil, nil, fmt.Errorf("migrated config %s does not parse: %w", path, err)
+	}
+	return buf.Bytes(), result.Notes(path), nil
+}
@@ -0,0 +1,71 @@
+package config
+
+// setPosition records where the setting or profile at key was written.
+// Keys are top-level field names, such as "version", or a top-level name
+// and a key within it, such as "cache.max_size" or "profiles.<name>".
+func (c *Config) setPosition(key string, o Origin) {
+	if c.positions == nil {
+		c.positions = make(map[string]Origin)
+	}
+	c.positions[key] = o
+}
+
+// position returns where the setting or profile at key was written, or the
+// zero Origin if it was not read from a file.
+func (c *Config) position(key string) Origin {
+	return c.positions[key]
+}
+
+// setLayer marks every position in c as read from a config layer.
+func (c *Config) setLayer(level ConfigLevel) {
+	set := func(o *Origin) {
+		if o.File != "" {
+			o.Layer = level
+		}
+	}
+	for i := range c.Sources {
+		set(&c.Sources[i].Origin)
+	}
+	for i := range c.Targets {
+		set(&c.Targets[i].Origin)
+	}
+	for i := range c.Overrides {
+		set(&c.Overrides[i].Origin)
+	}
+	for i := range c.Transforms {
+		set(&c.Transforms[i].Origin)
+	}
+	for i := range c.ToolDefinitions {
+		set(&c.ToolDefinitions[i].Origin)
+	}
+	for i := range c.Signing.AllowedKeys {
+		set(&c.Signing.AllowedKeys[i].Origin)
+	}
+	for i := range c.includeAt {
+		set(&c.includeAt[i])
+	}
+	set(&c.Remove.Origin)
+	for name, def := range c.varDefs {
+		set(&def.Origin)
+		c.varDefs[name] = def
+	}
+	for key, o := range c.positions {
+		set(&o)
+		c.positions[key] = o
+	}
+}
+
+func mergePositions(base, overlay map[string]Origin) map[string]Origin {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+
+	result := make(map[string]Origin, len(base)+len(overlay))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range overlay {
+		result[k] = v
+	}
+	return result
+}
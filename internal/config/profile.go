@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile adjusts the config for one environment, such as a laptop, CI, or
+// a release build. Profiles never change how sources resolve, so the
+// lockfile covers every profile and switching profiles needs no update.
+type Profile struct {
+	// Variables override global variables for template transforms. They
+	// cannot change ${...} references, which resolve before profiles apply.
+	Variables map[string]string `yaml:"variables,omitempty"`
+
+	// Sources and Targets turn entries on or off by name. A target is
+	// selected by its name or, if it has none, by its source.
+	Sources Toggle `yaml:"sources,omitempty"`
+	Targets Toggle `yaml:"targets,omitempty"`
+
+	// Tools are added to every tool-based target.
+	Tools []string `yaml:"tools,omitempty"`
+}
+
+// Toggle lists config entries to turn on or off.
+type Toggle struct {
+	Enable  []string `yaml:"enable,omitempty"`
+	Disable []string `yaml:"disable,omitempty"`
+}
+
+// ProfileNames returns the names of the defined profiles, sorted.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithProfile returns the config as seen by the named profile: only enabled
+// sources and targets, the profile's tools added, and its variables
+// applied. Disabling a source also drops its targets and transforms. An
+// empty name applies no profile, leaving out only entries marked
+// 'enabled: false'. c is not modified.
+func (c *Config) WithProfile(name string) (*Config, error) {
+	var p Profile
+	if name != "" {
+		var ok bool
+		if p, ok = c.Profiles[name]; !ok {
+			if len(c.Profiles) == 0 {
+				return nil, fmt.Errorf("unknown profile '%s' — the config defines no profiles", name)
+			}
+			return nil, fmt.Errorf("unknown profile '%s' — must be one of: %s", name, strings.Join(c.ProfileNames(), ", "))
+		}
+	}
+
+	view := *c
+	enabled := c.enabledSources(p)
+	view.Sources = nil
+	for _, s := range c.Sources {
+		if enabled[s.Name] {
+			view.Sources = append(view.Sources, s)
+		}
+	}
+
+	view.Targets = nil
+	for _, t := range c.Targets {
+		if !enabled[t.Source] || !toggled(t.Enabled, p.Targets, t.selector()) {
+			continue
+		}
+		if len(t.Tools) > 0 && len(p.Tools) > 0 {
+			t.Tools = appendMissing(append([]string(nil), t.Tools...), p.Tools)
+		}
+		view.Targets = append(view.Targets, t)
+	}
+
+	view.Transforms = nil
+	for _, tx := range c.Transforms {
+		if enabled[tx.Source] {
+			view.Transforms = append(view.Transforms, tx)
+		}
+	}
+
+	if len(p.Variables) > 0 {
+		view.Variables = mergeVariables(c.Variables, p.Variables)
+	}
+	return &view, nil
+}
+
+// Union returns the config with every source that is enabled without a
+// profile or in at least one profile. Commands that write the lockfile use
+// it, so that the lockfile serves every profile. c is not modified.
+func (c *Config) Union() *Config {
+	enabled := c.enabledSources(Profile{})
+	for _, p := range c.Profiles {
+		for name := range c.enabledSources(p) {
+			enabled[name] = true
+		}
+	}
+
+	union := *c
+	union.Sources = nil
+	for _, s := range c.Sources {
+		if enabled[s.Name] {
+			union.Sources = append(union.Sources, s)
+		}
+	}
+	return &union
+}
+
+// enabledSources returns the names of the sources enabled under p.
+func (c *Config) enabledSources(p Profile) map[string]bool {
+	enabled := make(map[string]bool, len(c.Sources))
+	for _, s := range c.Sources {
+		if toggled(s.Enabled, p.Sources, s.Name) {
+			enabled[s.Name] = true
+		}
+	}
+	return enabled
+}
+
+// toggled reports whether an entry is on: its own setting, defaulting to on,
+// unless the profile lists it.
+func toggled(enabled *bool, t Toggle, name string) bool {
+	for _, n := range t.Disable {
+		if n == name {
+			return false
+		}
+	}
+	for _, n := range t.Enable {
+		if n == name {
+			return true
+		}
+	}
+	return enabled == nil || *enabled
+}
+
+// selector is the name profiles use for the target.
+func (t Target) selector() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Source
+}
+
+func appendMissing(list, add []string) []string {
+	for _, a := range add {
+		found := false
+		for _, l := range list {
+			if l == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+// validateProfiles checks that every profile refers to existing sources and
+// targets and does not both enable and disable an entry.
+func validateProfiles(cfg *Config, sourceNames map[string]bool) []FieldError {
+	var errs []FieldError
+
+	targetNames := make(map[string]bool)
+	for _, t := range cfg.Targets {
+		targetNames[t.selector()] = true
+	}
+
+	for _, name := range cfg.ProfileNames() {
+		p := cfg.Profiles[name]
+		if name == "" {
+			errs = append(errs, fieldError(cfg.position("profiles."), "profiles.", "profiles: profile names must not be empty"))
+			continue
+		}
+		at, path, prefix := cfg.position("profiles."+name), "profiles."+name, fmt.Sprintf("profile '%s'", name)
+		for _, check := range []struct {
+			toggle Toggle
+			kind   string
+			known  map[string]bool
+		}{
+			{p.Sources, "source", sourceNames},
+			{p.Targets, "target", targetNames},
+		} {
+			disabled := make(map[string]bool)
+			for _, n := range check.toggle.Disable {
+				disabled[n] = true
+				if !check.known[n] {
+					errs = append(errs, fieldError(at, path, "%s: disables undefined %s '%s'", prefix, check.kind, n))
+				}
+			}
+			for _, n := range check.toggle.Enable {
+				if !check.known[n] {
+					errs = append(errs, fieldError(at, path, "%s: enables undefined %s '%s'", prefix, check.kind, n))
+				}
+				if disabled[n] {
+					errs = append(errs, fieldError(at, path, "%s: %s '%s' is both enabled and disabled", prefix, check.kind, n))
+				}
+			}
+		}
+		for i, tool := range p.Tools {
+			if tool == "" {
+				errs = append(errs, fieldError(at, path, "%s: tools[%d] is empty", prefix, i))
+			}
+		}
+	}
+	return errs
+}
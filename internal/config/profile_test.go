@@ -0,0 +1,196 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const profileConfig = `version: 1
+variables:
+  env: dev
+sources:
+  - name: rules
+    type: local
+    path: ./rules/
+  - name: drafts
+    type: local
+    path: ./drafts/
+    enabled: false
+  - name: release-notes
+    type: local
+    path: ./notes/
+targets:
+  - source: rules
+    tools: [cursor]
+  - name: rules-docs
+    source: rules
+    destination: docs/rules/
+    enabled: false
+  - source: drafts
+    destination: drafts/
+  - source: release-notes
+    destination: notes/
+transforms:
+  - source: release-notes
+    type: template
+profiles:
+  laptop:
+    sources:
+      enable: [drafts]
+    tools: [claude-code, cursor]
+  ci:
+    sources:
+      disable: [release-notes]
+    targets:
+      enable: [rules-docs]
+    variables:
+      env: ci
+`
+
+func loadProfileConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"agent-sync.yaml": profileConfig})
+	cfg, err := Load(filepath.Join(dir, "agent-sync.yaml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func sourceNamesOf(cfg *Config) string {
+	var names []string
+	for _, s := range cfg.Sources {
+		names = append(names, s.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestWithProfile(t *testing.T) {
+	cfg := loadProfileConfig(t)
+
+	def, err := cfg.WithProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sourceNamesOf(def); got != "rules,release-notes" {
+		t.Errorf("default sources = %s", got)
+	}
+	if len(def.Targets) != 2 || len(def.Transforms) != 1 {
+		t.Errorf("default targets = %+v, transforms = %+v", def.Targets, def.Transforms)
+	}
+
+	laptop, err := cfg.WithProfile("laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sourceNamesOf(laptop); got != "rules,drafts,release-notes" {
+		t.Errorf("laptop sources = %s", got)
+	}
+	if got := strings.Join(laptop.Targets[0].Tools, ","); got != "cursor,claude-code" {
+		t.Errorf("laptop tools = %s", got)
+	}
+	if len(laptop.Targets) != 3 || laptop.Targets[1].Source != "drafts" {
+		t.Errorf("laptop targets = %+v", laptop.Targets)
+	}
+
+	ci, err := cfg.WithProfile("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sourceNamesOf(ci); got != "rules" {
+		t.Errorf("ci sources = %s", got)
+	}
+	if len(ci.Targets) != 2 || ci.Targets[1].Name != "rules-docs" || len(ci.Transforms) != 0 {
+		t.Errorf("ci targets = %+v, transforms = %+v", ci.Targets, ci.Transforms)
+	}
+	if ci.Variables["env"] != "ci" || cfg.Variables["env"] != "dev" {
+		t.Errorf("ci variables = %v, base variables = %v", ci.Variables, cfg.Variables)
+	}
+
+	// Applying a profile leaves the config itself alone.
+	if sourceNamesOf(cfg) != "rules,drafts,release-notes" || len(cfg.Targets[0].Tools) != 1 {
+		t.Error("WithProfile modified the config")
+	}
+
+	if _, err := cfg.WithProfile("release"); err == nil || !strings.Contains(err.Error(), "must be one of: ci, laptop") {
+		t.Errorf("unknown profile err = %v", err)
+	}
+}
+
+func TestUnionCoversEveryProfile(t *testing.T) {
+	cfg := loadProfileConfig(t)
+	if got := sourceNamesOf(cfg.Union()); got != "rules,drafts,release-notes" {
+		t.Errorf("union sources = %s", got)
+	}
+
+	cfg.Profiles = nil
+	if got := sourceNamesOf(cfg.Union()); got != "rules,release-notes" {
+		t.Errorf("union without profiles = %s, want disabled sources left out", got)
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	cfg := &Config{
+		Version: 1,
+		Sources: []Source{{Name: "s", Type: "local", Path: "./s/"}},
+		Targets: []Target{{Source: "s", Destination: "out/"}},
+		Profiles: map[string]Profile{
+			"ci": {
+				Sources: Toggle{Enable: []string{"s", "missing"}, Disable: []string{"s"}},
+				Targets: Toggle{Disable: []string{"nope"}},
+				Tools:   []string{""},
+			},
+		},
+	}
+	errs := Validate(cfg)
+	for _, want := range []string{
+		"profile 'ci': enables undefined source 'missing'",
+		"profile 'ci': source 's' is both enabled and disabled",
+		"profile 'ci': disables undefined target 'nope'",
+		"profile 'ci': tools[0] is empty",
+	} {
+		if !containsSubstring(errs, want) {
+			t.Errorf("expected %q, got: %v", want, errs)
+		}
+	}
+}
+
+func TestProfileVariablesCannotChangeReferences(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent-sync.yaml")
+	writeFiles(t, filepath.Dir(path), map[string]string{"agent-sync.yaml": `version: 1
+variables:
+  env: dev
+  branch: main
+sources:
+  - name: rules
+    type: git
+    repo: https://example.com/rules-${env}.git
+    ref: ${branch}
+targets:
+  - source: rules
+    destination: out/
+profiles:
+  ci:
+    variables:
+      env: ci
+`})
+	_, err := Load(path)
+	want := "profile 'ci': variable 'env' is used in a ${...} reference, which profiles cannot change"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Load error = %v, want %q", err, want)
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	base := &Config{Profiles: map[string]Profile{"ci": {Tools: []string{"a"}}, "laptop": {Tools: []string{"b"}}}}
+	overlay := &Config{Profiles: map[string]Profile{"ci": {Tools: []string{"c"}}}}
+	merged, err := Merge(base, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Profiles["ci"].Tools[0] != "c" || merged.Profiles["laptop"].Tools[0] != "b" {
+		t.Errorf("profiles = %+v", merged.Profiles)
+	}
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces values read by variable providers or from the
+// environment when a resolved config is shown.
+const Redacted = "<redacted>"
+
+// RenderOptions controls Config.Render.
+type RenderOptions struct {
+	// Layers are the config layers the config was loaded from. Origins in
+	// files they loaded are labeled with their level.
+	Layers []ConfigLayerInfo
+
+	// Format is "yaml" (the default) or "json".
+	Format string
+
+	// Origins annotates every entry, variable, setting, and profile with
+	// the file that supplied it: a comment in YAML, and an "origins" object
+	// keyed by field path in JSON.
+	Origins bool
+}
+
+// Render formats the config for display. A config loaded with
+// HierarchicalOptions.Written shows ${...} references and variable
+// providers as written. A loaded config shows resolved values, with the
+// values of variable providers and of environment variables that ${...}
+// references read redacted wherever they appear, since they often hold
+// credentials.
+func (c *Config) Render(opts RenderOptions) ([]byte, error) {
+	view := *c
+	view.Include = nil
+	view.Remove = Removal{} // already applied
+	var root yaml.Node
+	if err := root.Encode(&view); err != nil {
+		return nil, err
+	}
+	resolved := c.scope != nil
+
+	vars, err := c.variablesNode(resolved)
+	if err != nil {
+		return nil, err
+	}
+	setMappingValue(&root, "variables", vars)
+	moveFirst(&root, "version")
+	if resolved {
+		redact(&root, c.secrets())
+	}
+
+	notes := c.annotations(&root, opts.Layers)
+	switch opts.Format {
+	case "", "yaml":
+		if opts.Origins {
+			for _, n := range notes {
+				if n.head {
+					n.node.HeadComment = n.label
+				} else {
+					n.node.LineComment = n.label
+				}
+			}
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&root); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "json":
+		var data any
+		if err := root.Decode(&data); err != nil {
+			return nil, err
+		}
+		if opts.Origins {
+			origins := make(map[string]string, len(notes))
+			for _, n := range notes {
+				origins[n.path] = n.label
+			}
+			data = map[string]any{"config": data, "origins": origins}
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown format '%s' — must be one of: yaml, json", opts.Format)
+	}
+}
+
+// variablesNode renders literals and providers together, in long form
+// where they are enforced.
+func (c *Config) variablesNode(resolved bool) (*yaml.Node, error) {
+	names := make(map[string]bool, len(c.Variables)+len(c.providers))
+	for name := range c.Variables {
+		names[name] = true
+	}
+	for name := range c.providers {
+		names[name] = true
+	}
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range sortedKeys(names) {
+		var spec variableSpec
+		p, isProvider := c.providers[name]
+		switch {
+		case resolved && isProvider:
+			v := Redacted
+			spec.Value = &v
+		case isProvider:
+			spec.VariableProvider = p
+		default:
+			v := c.Variables[name]
+			spec.Value = &v
+		}
+		spec.Enforced = c.varDefs[name].Enforced
+
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: ""}
+		if spec.Value != nil && !spec.Enforced {
+			value.Value = *spec.Value
+		} else {
+			if err := value.Encode(spec); err != nil {
+				return nil, err
+			}
+			value.Style = yaml.FlowStyle
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, value)
+	}
+	return node, nil
+}
+
+// secrets returns the resolved values of the variable providers and the
+// environment variables that references read.
+func (c *Config) secrets() []string {
+	var values []string
+	for name := range c.providers {
+		if v := c.Variables[name]; v != "" {
+			values = append(values, v)
+		}
+	}
+	for _, v := range c.scope.env {
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	// Longest first, so that a secret containing another is replaced whole.
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	return values
+}
+
+// redact replaces secrets in the scalar values under n. Secrets shorter
+// than four characters are only replaced when they are a whole value, so
+// that they do not mangle unrelated text.
+func redact(n *yaml.Node, secrets []string) {
+	if len(secrets) == 0 {
+		return
+	}
+	switch n.Kind {
+	case yaml.ScalarNode:
+		for _, s := range secrets {
+			if n.Value == s || len(s) >= 4 && strings.Contains(n.Value, s) {
+				n.Value = strings.ReplaceAll(n.Value, s, Redacted)
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			redact(n.Content[i], secrets)
+		}
+	default:
+		for _, child := range n.Content {
+			redact(child, secrets)
+		}
+	}
+}
+
+// annotation is the origin of one rendered entry.
+type annotation struct {
+	node  *yaml.Node // where a YAML comment goes
+	path  string     // field path, as in validation errors
+	label string
+	head  bool // a head comment rather than a line comment
+}
+
+// annotations finds the origin of every entry, variable, setting, and
+// profile in the rendered root mapping.
+func (c *Config) annotations(root *yaml.Node, layers []ConfigLayerInfo) []annotation {
+	var notes []annotation
+	add := func(node *yaml.Node, path string, o Origin, head bool) {
+		if o.File == "" {
+			return
+		}
+		if o.Layer == "" {
+			o.Layer = layerOf(layers, o.File)
+		}
+		notes = append(notes, annotation{node: node, path: path, label: o.describe(), head: head})
+	}
+	entries := func(seq *yaml.Node, path string, origin func(i int) Origin) {
+		if seq == nil {
+			return
+		}
+		for i, item := range seq.Content {
+			add(item, fmt.Sprintf("%s[%d]", path, i), origin(i), true)
+		}
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "sources":
+			entries(value, key.Value, func(i int) Origin { return c.Sources[i].Origin })
+		case "targets":
+			entries(value, key.Value, func(i int) Origin { return c.Targets[i].Origin })
+		case "overrides":
+			entries(value, key.Value, func(i int) Origin { return c.Overrides[i].Origin })
+		case "transforms":
+			entries(value, key.Value, func(i int) Origin { return c.Transforms[i].Origin })
+		case "tool_definitions":
+			entries(value, key.Value, func(i int) Origin { return c.ToolDefinitions[i].Origin })
+		case "variables":
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				name := value.Content[j].Value
+				add(value.Content[j+1], "variables."+name, c.varDefs[name].Origin, false)
+			}
+		case "profiles":
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				name := value.Content[j].Value
+				add(value.Content[j], "profiles."+name, c.position("profiles."+name), false)
+			}
+		default:
+			if value.Kind != yaml.MappingNode {
+				add(value, key.Value, c.position(key.Value), false)
+				continue
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				field, v := value.Content[j].Value, value.Content[j+1]
+				path := key.Value + "." + field
+				if path == "signing.allowed_keys" {
+					entries(v, path, func(i int) Origin { return c.Signing.AllowedKeys[i].Origin })
+					continue
+				}
+				add(v, path, c.position(path), false)
+			}
+		}
+	}
+	return notes
+}
+
+// layerOf returns the level of the layer that loaded file, directly or
+// through an include.
+func layerOf(layers []ConfigLayerInfo, file string) ConfigLevel {
+	for _, l := range layers {
+		if l.Path == file {
+			return l.Level
+		}
+		for _, inc := range l.Includes {
+			if inc == file {
+				return l.Level
+			}
+		}
+	}
+	return ""
+}
+
+// setMappingValue replaces the value of key in mapping n, adding the key if
+// it is missing and dropping it if value is empty.
+func setMappingValue(n *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			if len(value.Content) == 0 {
+				n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			} else {
+				n.Content[i+1] = value
+			}
+			return
+		}
+	}
+	if len(value.Content) > 0 {
+		n.Content = append([]*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value}, n.Content...)
+	}
+}
+
+// moveFirst moves key to the start of mapping n, where readers look for it.
+func moveFirst(n *yaml.Node, key string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			pair := []*yaml.Node{n.Content[i], n.Content[i+1]}
+			rest := append(n.Content[:i:i], n.Content[i+2:]...)
+			n.Content = append(pair, rest...)
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps size suffixes to byte multipliers (binary, matching how
+// agent-sync reports sizes).
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a human-readable byte size such as "512", "100KB",
+// "1.5GB". Units are case-insensitive and binary (1KB = 1024 bytes).
+func ParseSize(s string) (int64, error) {
+	trimmed := strings.ToUpper(strings.TrimSpace(s))
+	if trimmed == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	factor := int64(1)
+	number := trimmed
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(trimmed, u.suffix) {
+			factor = u.factor
+			number = strings.TrimSpace(strings.TrimSuffix(trimmed, u.suffix))
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || !(n >= 0) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid size '%s' — expected a number with an optional unit (B, KB, MB, GB, TB)", s)
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, the first size out of range.
+	size := n * float64(factor)
+	if size >= float64(math.MaxInt64) {
+		return 0, fmt.Errorf("size '%s' is too large", s)
+	}
+	return int64(size), nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"100KB", 100 << 10, false},
+		{"500mb", 500 << 20, false},
+		{"1.5GB", 3 << 29, false},
+		{" 2 TB ", 2 << 40, false},
+		{"", 0, true},
+		{"lots", 0, true},
+		{"-1MB", 0, true},
+		{"NaN", 0, true},
+		{"inf", 0, true},
+		{"99999999999G", 0, true},
+		{"99999999999GB", 0, true},
+		{"8388608TB", 0, true},
+		{"8388607TB", 8388607 << 40, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCacheMaxSize(t *testing.T) {
+	cfg := &Config{
+		Version: 1,
+		Sources: []Source{{Name: "s", Type: "local", Path: "./a/"}},
+		Targets: []Target{{Source: "s", Destination: "./out/"}},
+		Cache:   CacheSettings{MaxSize: "huge"},
+	}
+	errs := Validate(cfg)
+	if len(errs) != 1 || !containsSubstring(errs, "max_size") {
+		t.Fatalf("errors = %v, want one max_size error", errs)
+	}
+
+	cfg.Cache = CacheSettings{RemoteCache: "cache.internal:8080"}
+	errs = Validate(cfg)
+	if len(errs) != 1 || !containsSubstring(errs, "remote_cache") {
+		t.Fatalf("errors = %v, want one remote_cache error", errs)
+	}
+
+	cfg.Cache = CacheSettings{RemoteCache: "https://cache.internal/agent-sync"}
+	if errs := Validate(cfg); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestMergeCacheSettings(t *testing.T) {
+	base := &Config{Cache: CacheSettings{MaxSize: "1GB"}}
+	merged, err := Merge(base, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Cache.MaxSize != "1GB" {
+		t.Errorf("max_size = %q, want inherited 1GB", merged.Cache.MaxSize)
+	}
+
+	merged, err = Merge(base, &Config{Cache: CacheSettings{MaxSize: "200MB", RemoteCache: "http://ci-cache:8080"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Cache.MaxSize != "200MB" {
+		t.Errorf("max_size = %q, want overlay 200MB", merged.Cache.MaxSize)
+	}
+	if merged.Cache.RemoteCache != "http://ci-cache:8080" {
+		t.Errorf("remote_cache = %q, want overlay value", merged.Cache.RemoteCache)
+	}
+}
+
+func TestValidateSigning(t *testing.T) {
+	cfg := &Config{
+		Version: 1,
+		Sources: []Source{{Name: "s", Type: "local", Path: "./a/"}},
+		Targets: []Target{{Source: "s", Destination: "./out/"}},
+		Signing: SigningSettings{Require: true},
+	}
+	if errs := Validate(cfg); len(errs) != 1 || !containsSubstring(errs, "no allowed_keys") {
+		t.Fatalf("errors = %v, want missing allowed_keys", errs)
+	}
+
+	cfg.Signing.AllowedKeys = []AllowedKey{
+		{Name: "a", PublicKey: "ssh-ed25519 AAAA"},
+		{Name: "a", PublicKey: "ssh-rsa AAAA"},
+		{PublicKey: "ssh-ed25519 AAAA"},
+	}
+	errs := Validate(cfg)
+	for _, want := range []string{"duplicate key name 'a'", "must be an ed25519 key", "allowed_keys[2]: 'name' is required"} {
+		if !containsSubstring(errs, want) {
+			t.Errorf("errors = %v, want %q", errs, want)
+		}
+	}
+}
@@ -1,15 +1,36 @@
 package config
 
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 // Config represents the agent-sync.yaml configuration file.
 // See spec Section 3.
 type Config struct {
-	Variables       map[string]string `yaml:"variables,omitempty"`
-	Sources         []Source          `yaml:"sources"`
-	Targets         []Target          `yaml:"targets"`
-	Overrides       []Override        `yaml:"overrides,omitempty"`
-	Transforms      []Transform       `yaml:"transforms,omitempty"`
-	ToolDefinitions []ToolDefinition  `yaml:"tool_definitions,omitempty"`
-	Version         int               `yaml:"version"`
+	Variables       map[string]string  `yaml:"variables,omitempty"`
+	Include         []string           `yaml:"include,omitempty"`
+	Profiles        map[string]Profile `yaml:"profiles,omitempty"`
+	Sources         []Source           `yaml:"sources"`
+	Targets         []Target           `yaml:"targets"`
+	Overrides       []Override         `yaml:"overrides,omitempty"`
+	Transforms      []Transform        `yaml:"transforms,omitempty"`
+	ToolDefinitions []ToolDefinition   `yaml:"tool_definitions,omitempty"`
+	migrations      []string           // schema migrations applied while loading
+	includes        []string           // fragment files merged in while loading
+	includeAt       []Origin           // where each Include entry was written
+	providers       map[string]VariableProvider
+	varDefs         map[string]variableDef // where variables were defined
+	positions       map[string]Origin      // where settings and profiles were defined; see position
+	scope           *scope                 // resolves ${...} references; set while loading
+	Cache           CacheSettings          `yaml:"cache,omitempty"`
+	Sync            SyncSettings           `yaml:"sync,omitempty"`
+	Update          UpdateSettings         `yaml:"update,omitempty"`
+	Signing         SigningSettings        `yaml:"signing,omitempty"`
+	Remove          Removal                `yaml:"remove,omitempty"`
+	Version         int                    `yaml:"version"`
 }
 
 // Source defines an external source of agent files.
@@ -29,14 +50,79 @@ type Source struct {
 
 	// Git source fields (Section 5.1).
 	Paths []string `yaml:"paths,omitempty"`
+
+	// MinAge overrides update.min_age for this git source ("0" disables).
+	MinAge string `yaml:"min_age,omitempty"`
+
+	// Enabled set to false leaves the source out unless a profile enables
+	// it. Nil means enabled.
+	Enabled *bool `yaml:"enabled,omitempty"`
+
+	// Enforced, in a system or user layer, keeps higher layers from
+	// replacing, removing, or disabling the source.
+	Enforced bool `yaml:"enforced,omitempty"`
+
+	// written holds repo, url, and path before interpolation, if it
+	// changed them; see Recorded.
+	written *sourceLocation
+
+	// Origin is where the entry was defined.
+	Origin Origin `yaml:"-"`
+}
+
+// Origin records the position a config entry was read from, so that
+// errors can point at it when the config is split across included files
+// and hierarchy layers. The zero value means the entry was not read from a
+// file.
+type Origin struct {
+	File   string
+	Layer  ConfigLevel // set when the config was loaded hierarchically
+	Line   int
+	Column int
+}
+
+// String formats the origin as "file:line:column", or "" if it is unknown.
+func (o Origin) String() string {
+	if o.File == "" {
+		return ""
+	}
+	if o.Column == 0 {
+		return fmt.Sprintf("%s:%d", o.File, o.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", o.File, o.Line, o.Column)
+}
+
+// describe formats the origin with its layer, if known.
+func (o Origin) describe() string {
+	if o.Layer == "" {
+		return o.String()
+	}
+	return fmt.Sprintf("%s (%s config)", o, o.Layer)
+}
+
+// at prefixes what with the origin, if known.
+func (o Origin) at(what string) string {
+	if o.File == "" {
+		return what
+	}
+	return o.describe() + ": " + what
+}
+
+// nodeOrigin returns the position of n in the file at path.
+func nodeOrigin(path string, n *yaml.Node) Origin {
+	return Origin{File: path, Line: n.Line, Column: n.Column}
 }
 
 // Target defines where source files are written.
 // See spec Section 7.
 type Target struct {
+	Enabled     *bool    `yaml:"enabled,omitempty"` // false: only when a profile enables it
+	Name        string   `yaml:"name,omitempty"`    // selects the target in profiles
 	Source      string   `yaml:"source"`
 	Destination string   `yaml:"destination,omitempty"`
 	Tools       []string `yaml:"tools,omitempty"`
+	Origin      Origin   `yaml:"-"`
+	Enforced    bool     `yaml:"enforced,omitempty"` // cannot be removed or disabled by higher layers
 }
 
 // Override defines a post-sync modification to a target file.
@@ -45,6 +131,8 @@ type Override struct {
 	Target   string `yaml:"target"`
 	Strategy string `yaml:"strategy"` // "append", "prepend", "replace"
 	File     string `yaml:"file"`
+	Origin   Origin `yaml:"-"`
+	Enforced bool   `yaml:"enforced,omitempty"` // cannot be removed or replaced by higher layers
 }
 
 // Transform defines a transformation applied to source files.
@@ -55,6 +143,7 @@ type Transform struct {
 	Vars       map[string]string `yaml:"vars,omitempty"`
 	Command    string            `yaml:"command,omitempty"`
 	OutputHash string            `yaml:"output_hash,omitempty"`
+	Origin     Origin            `yaml:"-"`
 }
 
 // ToolDefinition defines a custom tool path mapping or overrides a built-in.
@@ -62,4 +151,76 @@ type Transform struct {
 type ToolDefinition struct {
 	Name        string `yaml:"name"`
 	Destination string `yaml:"destination"`
+	Origin      Origin `yaml:"-"`
+}
+
+// SyncSettings configures default sync behavior. CLI flags override it.
+type SyncSettings struct {
+	// Atomic makes sync all-or-nothing: nothing is written unless every
+	// source fetches and renders successfully. A pointer so that a higher
+	// layer can explicitly turn off a default set by a lower one.
+	Atomic *bool `yaml:"atomic,omitempty"`
+}
+
+// IsAtomic reports whether atomic sync is enabled.
+func (s SyncSettings) IsAtomic() bool {
+	return s.Atomic != nil && *s.Atomic
+}
+
+// UpdateSettings configures default update behavior.
+type UpdateSettings struct {
+	// MinAge is the cooldown applied to git sources: update does not lock
+	// a commit younger than this (by committer date), falling back to an
+	// older release tag instead. Accepts Go durations plus d and w units,
+	// e.g. "72h" or "3d". Typically set in the system layer.
+	MinAge string `yaml:"min_age,omitempty"`
+}
+
+// MinAge returns the cooldown in effect for src: its own min_age if set,
+// otherwise update.min_age. Non-git sources have no cooldown. The config
+// is assumed valid.
+func (c *Config) MinAge(src Source) time.Duration {
+	if src.Type != "git" {
+		return 0
+	}
+	value := c.Update.MinAge
+	if src.MinAge != "" {
+		value = src.MinAge
+	}
+	if value == "" {
+		return 0
+	}
+	d, _ := ParseAge(value)
+	return d
+}
+
+// SigningSettings is the lockfile signing policy.
+type SigningSettings struct {
+	// AllowedKeys lists the keys whose lockfile signatures are trusted.
+	AllowedKeys []AllowedKey `yaml:"allowed_keys,omitempty"`
+
+	// Require makes sync and check refuse to run unless the lockfile
+	// carries a valid signature from an allowed key.
+	Require bool `yaml:"require,omitempty"`
+}
+
+// AllowedKey is a trusted lockfile signing key.
+type AllowedKey struct {
+	Name      string `yaml:"name"`
+	PublicKey string `yaml:"public_key"` // "ssh-ed25519 AAAA..."
+	Origin    Origin `yaml:"-"`
+}
+
+// CacheSettings configures the local content-addressed cache.
+// Typically set in the user or system config layer.
+type CacheSettings struct {
+	// MaxSize caps the cache size (e.g. "500MB", "2GB"). When exceeded,
+	// objects not referenced by any registered lockfile are evicted,
+	// least recently used first. Empty means no cap.
+	MaxSize string `yaml:"max_size,omitempty"`
+
+	// RemoteCache is the base URL of a shared HTTP cache (see 'agent-sync
+	// cache serve'). Objects missing locally are read through from it, and
+	// newly cached objects are written back.
+	RemoteCache string `yaml:"remote_cache,omitempty"`
 }
@@ -0,0 +1,525 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/sandbox"
+	"gopkg.in/yaml.v3"
+)
+
+// VariableProvider is a variable whose value is read when the config is
+// loaded instead of being written in it:
+//
+//	variables:
+//	  token: {env: GITHUB_TOKEN}
+//	  owners: {file: .github/OWNERS}
+//	  build: {command: "git describe --tags", default: dev}
+//
+// Exactly one of Env, File, and Command is set. Commands only run when
+// allowed (see HierarchicalOptions.AllowCommands).
+type VariableProvider struct {
+	// Default is used when the environment variable is unset, the file is
+	// missing, or the command fails. Without it those are errors.
+	Default *string `yaml:"default,omitempty"`
+
+	Env string `yaml:"env,omitempty"`
+
+	// File is read relative to the project root. A project config may only
+	// read files inside it; see providerFile.
+	File    string `yaml:"file,omitempty"`
+	Command string `yaml:"command,omitempty"`
+}
+
+// commandTimeout bounds a command variable provider.
+const commandTimeout = 30 * time.Second
+
+// Built-in variables describing the project's git checkout. They are unset
+// outside a git repository or when the fact is unavailable (e.g. no remote).
+const (
+	FactGitRemote = "git.remote" // URL of the origin remote
+	FactGitBranch = "git.branch" // current branch
+	FactGitRepo   = "git.repo"   // repository name, from the remote or the checkout directory
+)
+
+// interpolateOptions controls how ${...} references are resolved.
+type interpolateOptions struct {
+	root          string // project root: file providers and git facts
+	allowCommands bool
+}
+
+// scope resolves ${...} references: config variables first, then built-in
+// facts, then the process environment.
+type scope struct {
+	literals  map[string]string
+	providers map[string]VariableProvider
+	defs      map[string]variableDef
+	values    map[string]string // resolved variables
+	errs      map[string]error  // variables that failed to resolve
+	resolving map[string]bool   // variables being resolved, for cycle detection
+	facts     map[string]string // nil until first needed
+	env       map[string]string // environment variables referenced, redacted when shown
+	refs      map[string]bool   // names referenced with ${...}
+	opts      interpolateOptions
+}
+
+func newScope(c *Config, opts interpolateOptions) *scope {
+	return &scope{
+		literals:  c.Variables,
+		providers: c.providers,
+		defs:      c.varDefs,
+		values:    make(map[string]string),
+		errs:      make(map[string]error),
+		resolving: make(map[string]bool),
+		env:       make(map[string]string),
+		refs:      make(map[string]bool),
+		opts:      opts,
+	}
+}
+
+// lookup returns the value of name and whether it is set.
+func (s *scope) lookup(name string) (string, bool, error) {
+	if _, ok := s.literals[name]; ok {
+		return s.variable(name)
+	}
+	if _, ok := s.providers[name]; ok {
+		return s.variable(name)
+	}
+	if strings.HasPrefix(name, "git.") {
+		if s.facts == nil {
+			s.facts = gitFacts(s.opts.root)
+		}
+		v, ok := s.facts[name]
+		return v, ok, nil
+	}
+	v, ok := os.LookupEnv(name)
+	if ok {
+		s.env[name] = v
+	}
+	return v, ok, nil
+}
+
+// variable resolves a config variable once, following references between
+// variables.
+func (s *scope) variable(name string) (string, bool, error) {
+	if v, ok := s.values[name]; ok {
+		return v, true, nil
+	}
+	if err, ok := s.errs[name]; ok {
+		return "", false, err
+	}
+	if s.resolving[name] {
+		return "", false, fmt.Errorf("variable '%s' refers to itself", name)
+	}
+	s.resolving[name] = true
+	defer delete(s.resolving, name)
+
+	var v string
+	var err error
+	if p, ok := s.providers[name]; ok {
+		v, err = p.value(s.opts, s.defs[name].Origin)
+	} else {
+		v, err = s.expand(s.literals[name])
+	}
+	if err != nil {
+		s.errs[name] = err
+		return "", false, err
+	}
+	s.values[name] = v
+	return v, true, nil
+}
+
+// value reads a provider defined at from.
+func (p VariableProvider) value(opts interpolateOptions, from Origin) (string, error) {
+	var v string
+	var err error
+	switch {
+	case p.Env != "":
+		var ok bool
+		if v, ok = os.LookupEnv(p.Env); !ok {
+			err = fmt.Errorf("environment variable %s is not set", p.Env)
+		}
+	case p.File != "":
+		file, ferr := providerFile(p.File, opts, from)
+		if ferr != nil {
+			return "", ferr
+		}
+		var data []byte
+		if data, err = os.ReadFile(file); err == nil {
+			v = strings.TrimRight(string(data), "\r\n")
+		}
+	case p.Command != "":
+		if !opts.allowCommands {
+			return "", fmt.Errorf("command providers are disabled — pass --allow-commands or set AGENT_SYNC_ALLOW_COMMANDS=1 to run %q", p.Command)
+		}
+		v, err = runCommand(opts.root, p.Command)
+	}
+	if err != nil {
+		if p.Default != nil {
+			return *p.Default, nil
+		}
+		return "", err
+	}
+	return v, nil
+}
+
+// providerFile returns the file a file provider reads. Like includes, a
+// provider in the project config may only read files inside the project
+// root: it would otherwise let a checked-out repository copy any file the
+// user can read into its targets. System and user configs are trusted to
+// read any file, as is every layer when command providers are allowed.
+func providerFile(name string, opts interpolateOptions, from Origin) (string, error) {
+	file := name
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(opts.root, file)
+	}
+	if opts.allowCommands || from.Layer == LevelSystem || from.Layer == LevelUser {
+		return file, nil
+	}
+	if !filepath.IsAbs(name) {
+		if _, err := sandbox.ValidatePath(opts.root, name); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("file %s is outside the project root — only the system and user configs may read it, or pass --allow-commands or set AGENT_SYNC_ALLOW_COMMANDS=1", name)
+}
+
+func runCommand(dir, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("command %q failed: %w", command, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// gitFacts reads the built-in git variables for the checkout at root.
+func gitFacts(root string) map[string]string {
+	facts := make(map[string]string)
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", root}, args...)...).Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	top := git("rev-parse", "--show-toplevel")
+	if top == "" {
+		return facts
+	}
+	facts[FactGitRepo] = filepath.Base(top)
+	if remote := git("remote", "get-url", "origin"); remote != "" {
+		facts[FactGitRemote] = remote
+		// Both "https://host/org/name.git" and "git@host:org/name.git".
+		name := path.Base(strings.ReplaceAll(remote, ":", "/"))
+		if name = strings.TrimSuffix(name, ".git"); name != "" && name != "." && name != "/" {
+			facts[FactGitRepo] = name
+		}
+	}
+	if branch := git("symbolic-ref", "--short", "-q", "HEAD"); branch != "" {
+		facts[FactGitBranch] = branch
+	}
+	return facts
+}
+
+// expand replaces ${NAME} and ${NAME:-default} references in s. "$${"
+// escapes a literal "${". A default applies when NAME is unset or empty.
+func (s *scope) expand(in string) (string, error) {
+	if !strings.Contains(in, "${") {
+		return in, nil
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(in, "${")
+		if i < 0 {
+			b.WriteString(in)
+			return b.String(), nil
+		}
+		if i > 0 && in[i-1] == '$' {
+			b.WriteString(in[:i-1])
+			b.WriteString("${")
+			in = in[i+2:]
+			continue
+		}
+		b.WriteString(in[:i])
+		end := strings.IndexByte(in[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q", in[i:])
+		}
+		ref := in[i+2 : i+end]
+		in = in[i+end+1:]
+
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		if !validVarName(name) {
+			return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+		}
+		s.refs[name] = true
+		v, ok, err := s.lookup(name)
+		switch {
+		case err != nil && !hasDefault:
+			return "", err
+		case (!ok || v == "") && hasDefault:
+			v = def
+		case !ok:
+			return "", fmt.Errorf("variable '%s' is not set", name)
+		}
+		b.WriteString(v)
+	}
+}
+
+func validVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r == '.' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// interpolate resolves variable providers and ${...} references throughout
+// the config, returning one error per field that cannot be resolved.
+// Source repo, url, and path remember their written form; see
+// Source.Recorded.
+func (c *Config) interpolate(opts interpolateOptions) []FieldError {
+	s := newScope(c, opts)
+	c.scope = s
+
+	var errs []FieldError
+	names := make([]string, 0, len(c.Variables)+len(c.providers))
+	for name := range c.Variables {
+		names = append(names, name)
+	}
+	for name, p := range c.providers {
+		if n := p.kinds(); n != 1 {
+			errs = append(errs, fieldError(c.varDefs[name].Origin, "variables."+name, "variables.%s: exactly one of 'value', 'env', 'file', or 'command' is required", name))
+			delete(c.providers, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolved := make(map[string]string, len(names))
+	for _, name := range names {
+		v, _, err := s.variable(name)
+		if err != nil {
+			errs = append(errs, fieldError(c.varDefs[name].Origin, "variables."+name, "variables.%s: %s", name, err))
+			continue
+		}
+		resolved[name] = v
+	}
+	if len(resolved) > 0 {
+		c.Variables = resolved
+	}
+
+	for i := range c.Sources {
+		src := &c.Sources[i]
+		written := *src
+		errs = append(errs, s.fields(reflect.ValueOf(src).Elem(), fmt.Sprintf("sources[%d]", i), src.Origin)...)
+		if written.Repo != src.Repo || written.URL != src.URL || written.Path != src.Path {
+			src.written = &sourceLocation{Repo: written.Repo, URL: written.URL, Path: written.Path}
+		}
+	}
+	for i := range c.Targets {
+		errs = append(errs, s.fields(reflect.ValueOf(&c.Targets[i]).Elem(), fmt.Sprintf("targets[%d]", i), c.Targets[i].Origin)...)
+	}
+	for i := range c.Overrides {
+		errs = append(errs, s.fields(reflect.ValueOf(&c.Overrides[i]).Elem(), fmt.Sprintf("overrides[%d]", i), c.Overrides[i].Origin)...)
+	}
+	for i := range c.Transforms {
+		errs = append(errs, s.fields(reflect.ValueOf(&c.Transforms[i]).Elem(), fmt.Sprintf("transforms[%d]", i), c.Transforms[i].Origin)...)
+	}
+	for i := range c.ToolDefinitions {
+		errs = append(errs, s.fields(reflect.ValueOf(&c.ToolDefinitions[i]).Elem(), fmt.Sprintf("tool_definitions[%d]", i), c.ToolDefinitions[i].Origin)...)
+	}
+	// Profiles apply to the interpolated config, too late to change a
+	// reference. A profile variable that one names would silently not
+	// apply there, so it is rejected.
+	for _, name := range c.ProfileNames() {
+		for _, v := range sortedKeys(c.Profiles[name].Variables) {
+			if s.refs[v] {
+				errs = append(errs, fieldError(c.position("profiles."+name), "profiles."+name+".variables."+v, "profile '%s': variable '%s' is used in a ${...} reference, which profiles cannot change — profile variables only reach template transforms", name, v))
+			}
+		}
+	}
+	for _, name := range c.ProfileNames() {
+		p := c.Profiles[name]
+		errs = append(errs, s.fields(reflect.ValueOf(&p).Elem(), "profiles."+name, c.position("profiles."+name))...)
+		c.Profiles[name] = p
+	}
+	for _, settings := range []struct {
+		v    any
+		name string
+	}{{&c.Cache, "cache"}, {&c.Update, "update"}} {
+		errs = append(errs, s.fields(reflect.ValueOf(settings.v).Elem(), settings.name, c.position(settings.name))...)
+	}
+	return errs
+}
+
+// fields interpolates the string fields of a struct in place, including
+// string slices and map values, naming each by its yaml key.
+func (s *scope) fields(v reflect.Value, prefix string, at Origin) []FieldError {
+	var errs []FieldError
+	fail := func(field string, err error) {
+		path := prefix + "." + field
+		errs = append(errs, fieldError(at, path, "%s: %s", path, err))
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" || !f.CanSet() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.String:
+			out, err := s.expand(f.String())
+			if err != nil {
+				fail(key, err)
+				continue
+			}
+			f.SetString(out)
+		case reflect.Slice:
+			if f.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			for j := 0; j < f.Len(); j++ {
+				out, err := s.expand(f.Index(j).String())
+				if err != nil {
+					fail(fmt.Sprintf("%s[%d]", key, j), err)
+					continue
+				}
+				f.Index(j).SetString(out)
+			}
+		case reflect.Map:
+			if f.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			for _, k := range f.MapKeys() {
+				out, err := s.expand(f.MapIndex(k).String())
+				if err != nil {
+					fail(fmt.Sprintf("%s.%s", key, k.String()), err)
+					continue
+				}
+				f.SetMapIndex(k, reflect.ValueOf(out))
+			}
+		}
+	}
+	return errs
+}
+
+func (p VariableProvider) kinds() int {
+	n := 0
+	for _, set := range []bool{p.Env != "", p.File != "", p.Command != ""} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// Expand resolves ${...} references in s against the config's variables,
+// the built-in facts, and the environment, as at load time. It turns a
+// value recorded in the lockfile back into the one to use.
+func (c *Config) Expand(s string) (string, error) {
+	if c.scope == nil {
+		c.scope = newScope(c, interpolateOptions{})
+	}
+	return c.scope.expand(s)
+}
+
+// sourceLocation holds the fields of a source that are recorded in the
+// lockfile, as written in the config.
+type sourceLocation struct {
+	Repo, URL, Path string
+}
+
+// Recorded returns the source as it is recorded in the lockfile: repo, url,
+// and path keep their ${...} references rather than the values they
+// resolved to, so that secrets are never written out. Sync resolves them
+// again only when the lockfile still matches this form.
+func (s Source) Recorded() Source {
+	if s.written != nil {
+		s.Repo, s.URL, s.Path = s.written.Repo, s.written.URL, s.written.Path
+	}
+	return s
+}
+
+// variableDef records where a variable was defined and whether it is
+// enforced.
+type variableDef struct {
+	Origin   Origin
+	Enforced bool
+}
+
+// extractVariables moves the provider entries out of the variables mapping
+// in a config document, which otherwise holds only strings, and records
+// where each variable was defined. A mapping with 'value' is a literal
+// written in long form so that it can be enforced:
+//
+//	variables:
+//	  registry: {value: https://registry.example.com, enforced: true}
+func extractVariables(cfg *Config, path string, root *yaml.Node) error {
+	vars := mappingValue(root, "variables")
+	if vars == nil || vars.Kind != yaml.MappingNode {
+		return nil
+	}
+	var kept []*yaml.Node
+	for i := 0; i+1 < len(vars.Content); i += 2 {
+		key, value := vars.Content[i], vars.Content[i+1]
+		def := variableDef{Origin: nodeOrigin(path, key)}
+		if value.Kind != yaml.MappingNode {
+			kept = append(kept, key, value)
+			cfg.setVarDef(key.Value, def)
+			continue
+		}
+		var v variableSpec
+		if err := value.Decode(&v); err != nil {
+			return fmt.Errorf("%s:%d: variables.%s: %w", path, value.Line, key.Value, err)
+		}
+		def.Enforced = v.Enforced
+		cfg.setVarDef(key.Value, def)
+		if v.Value != nil {
+			if v.kinds() > 0 || v.Default != nil {
+				return fmt.Errorf("%s:%d: variables.%s: 'value' cannot be combined with 'env', 'file', 'command', or 'default'", path, value.Line, key.Value)
+			}
+			kept = append(kept, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: *v.Value, Line: value.Line, Column: value.Column})
+			continue
+		}
+		if cfg.providers == nil {
+			cfg.providers = make(map[string]VariableProvider)
+		}
+		cfg.providers[key.Value] = v.VariableProvider
+	}
+	vars.Content = kept
+	return nil
+}
+
+func (c *Config) setVarDef(name string, def variableDef) {
+	if c.varDefs == nil {
+		c.varDefs = make(map[string]variableDef)
+	}
+	c.varDefs[name] = def
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("AS_TEST_SET", "value")
+	t.Setenv("AS_TEST_EMPTY", "")
+	cfg := &Config{Variables: map[string]string{"team": "platform", "greeting": "hi ${team}"}}
+
+	tests := []struct {
+		in, want string
+		wantErr  string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${AS_TEST_SET}", want: "value"},
+		{in: "a-${team}-b", want: "a-platform-b"},
+		{in: "${greeting}!", want: "hi platform!"},
+		{in: "${AS_TEST_UNSET:-fallback}", want: "fallback"},
+		{in: "${AS_TEST_EMPTY:-fallback}", want: "fallback"},
+		{in: "${AS_TEST_SET:-fallback}", want: "value"},
+		{in: "$${AS_TEST_SET}", want: "${AS_TEST_SET}"},
+		{in: "${AS_TEST_UNSET}", wantErr: "variable 'AS_TEST_UNSET' is not set"},
+		{in: "${AS_TEST_SET", wantErr: "unterminated"},
+		{in: "${bad name}", wantErr: "invalid variable reference"},
+	}
+	for _, tt := range tests {
+		got, err := cfg.Expand(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expand(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Expand(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpandSelfReference(t *testing.T) {
+	cfg := &Config{Variables: map[string]string{"a": "${b}", "b": "${a}"}}
+	if _, err := cfg.Expand("${a}"); err == nil || !strings.Contains(err.Error(), "refers to itself") {
+		t.Errorf("err = %v, want a cycle error", err)
+	}
+}
+
+func TestLoadInterpolatesVariablesAndProviders(t *testing.T) {
+	t.Setenv("AS_TEST_TOKEN", "s3cret")
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"owner.txt": "org\n",
+		"agent-sync.yaml": `version: 1
+variables:
+  token: {env: AS_TEST_TOKEN}
+  owner: {file: owner.txt}
+  missing: {env: AS_TEST_UNSET, default: none}
+  repo_base: https://${token}@example.com/${owner}
+sources:
+  - name: rules
+    type: git
+    repo: ${repo_base}/rules.git
+    ref: ${AS_TEST_REF:-main}
+targets:
+  - source: rules
+    destination: ./${missing}/
+`,
+	})
+
+	cfg, err := Load(filepath.Join(dir, "agent-sync.yaml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	src := cfg.Sources[0]
+	if src.Repo != "https://s3cret@example.com/org/rules.git" || src.Ref != "main" {
+		t.Errorf("source = %+v", src)
+	}
+	if cfg.Targets[0].Destination != "./none/" {
+		t.Errorf("destination = %s", cfg.Targets[0].Destination)
+	}
+	if cfg.Variables["token"] != "s3cret" || cfg.Variables["owner"] != "org" {
+		t.Errorf("variables = %v", cfg.Variables)
+	}
+
+	recorded := src.Recorded()
+	if recorded.Repo != "${repo_base}/rules.git" {
+		t.Errorf("recorded repo = %s, want the written form", recorded.Repo)
+	}
+	if got, err := cfg.Expand(recorded.Repo); err != nil || got != src.Repo {
+		t.Errorf("Expand(recorded) = %s, %v", got, err)
+	}
+	if untouched := (Source{Repo: "https://example.com/r.git"}).Recorded(); untouched.Repo != "https://example.com/r.git" {
+		t.Errorf("recorded = %+v", untouched)
+	}
+}
+
+func TestLoadInterpolationErrorsNameTheField(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"agent-sync.yaml": `version: 1
+variables:
+  token: {env: AS_TEST_UNSET}
+  both: {env: HOME, file: x}
+  build: {command: "echo 1"}
+sources:
+  - name: rules
+    type: git
+    repo: https://example.com/${AS_TEST_UNSET}.git
+    ref: main
+`,
+	})
+
+	_, err := Load(filepath.Join(dir, "agent-sync.yaml"))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{
+		"agent-sync.yaml:3:3: variables.token: environment variable AS_TEST_UNSET is not set",
+		"variables.both: exactly one of 'value', 'env', 'file', or 'command' is required",
+		"variables.build: command providers are disabled",
+		"agent-sync.yaml:7:5: sources[0].repo: variable 'AS_TEST_UNSET' is not set",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v\nwant it to contain %q", err, want)
+		}
+	}
+}
+
+func TestLoadHierarchicalCommandProvider(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"agent-sync.yaml": `version: 1
+variables:
+  build: {command: "echo from-command"}
+  failing: {command: "exit 3", default: fallback}
+sources:
+  - name: s
+    type: local
+    path: ./${build}/${failing}/
+`,
+	})
+
+	result, err := LoadHierarchical(HierarchicalOptions{
+		ProjectPath:   filepath.Join(dir, "agent-sync.yaml"),
+		NoInherit:     true,
+		AllowCommands: true,
+	})
+	if err != nil {
+		t.Fatalf("LoadHierarchical: %v", err)
+	}
+	if got := result.Config.Sources[0].Path; got != "./from-command/fallback/" {
+		t.Errorf("path = %s", got)
+	}
+}
+
+func TestFileProviderStaysInProjectRoot(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	writeFiles(t, dir, map[string]string{
+		"secret.txt":         "outside",
+		"project/inside.txt": "inside",
+		"project/link.txt":   "",
+	})
+	if err := os.Remove(filepath.Join(project, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(project, "link.txt")); err != nil {
+		t.Skip("symlinks not supported")
+	}
+	outside := filepath.Join(dir, "secret.txt")
+
+	load := func(file, user string, allowCommands bool) (*HierarchicalResult, error) {
+		t.Helper()
+		writeFiles(t, dir, map[string]string{
+			"user.yaml": user,
+			"project/agent-sync.yaml": `version: 1
+variables:
+  v: {file: "` + filepath.ToSlash(file) + `"}
+sources:
+  - name: s
+    type: local
+    path: ./${v}/
+targets:
+  - source: s
+    destination: ./out/
+`,
+		})
+		return LoadHierarchical(HierarchicalOptions{
+			ProjectPath:      filepath.Join(project, "agent-sync.yaml"),
+			UserConfigPath:   filepath.Join(dir, "user.yaml"),
+			SystemConfigPath: filepath.Join(dir, "missing.yaml"),
+			AllowCommands:    allowCommands,
+		})
+	}
+
+	if result, err := load("inside.txt", "version: 1\n", false); err != nil {
+		t.Fatalf("file inside the project: %v", err)
+	} else if got := result.Config.Sources[0].Path; got != "./inside/" {
+		t.Errorf("path = %s", got)
+	}
+	for _, file := range []string{"../secret.txt", outside, "link.txt"} {
+		_, err := load(file, "version: 1\n", false)
+		if err == nil || !strings.Contains(err.Error(), "outside the project root") {
+			t.Errorf("file %s: err = %v, want outside the project root", file, err)
+		}
+	}
+	if result, err := load(outside, "version: 1\n", true); err != nil {
+		t.Fatalf("file outside the project with commands allowed: %v", err)
+	} else if got := result.Config.Sources[0].Path; got != "./outside/" {
+		t.Errorf("path = %s", got)
+	}
+
+	// A provider defined in the user config may read any file.
+	if result, err := load("inside.txt", "version: 1\nvariables:\n  home: {file: \""+filepath.ToSlash(outside)+"\"}\n", false); err != nil {
+		t.Fatalf("user config provider: %v", err)
+	} else if got := result.Config.Variables["home"]; got != "outside" {
+		t.Errorf("home = %q", got)
+	}
+}
+
+func TestGitFacts(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := filepath.Join(t.TempDir(), "checkout")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if facts := gitFacts(dir); len(facts) != 0 {
+		t.Errorf("facts outside a repository = %v", facts)
+	}
+
+	for _, args := range [][]string{
+		{"init", "-b", "feature"},
+		{"remote", "add", "origin", "git@github.com:org/agent-rules.git"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %v", args, out, err)
+		}
+	}
+
+	facts := gitFacts(dir)
+	want := map[string]string{
+		FactGitRemote: "git@github.com:org/agent-rules.git",
+		FactGitBranch: "feature",
+		FactGitRepo:   "agent-rules",
+	}
+	for k, v := range want {
+		if facts[k] != v {
+			t.Errorf("%s = %q, want %q", k, facts[k], v)
+		}
+	}
+}
+
+func TestMergeVariableProviders(t *testing.T) {
+	base := &Config{
+		Variables: map[string]string{"a": "base", "b": "base"},
+		providers: map[string]VariableProvider{"c": {Env: "C"}},
+	}
+	overlay := &Config{
+		Variables: map[string]string{"c": "overlay"},
+		providers: map[string]VariableProvider{"a": {Env: "A"}},
+	}
+	merged, err := Merge(base, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := merged.Variables["a"]; ok || merged.providers["a"].Env != "A" {
+		t.Errorf("overlay provider should replace base literal: %v %v", merged.Variables, merged.providers)
+	}
+	if _, ok := merged.providers["c"]; ok || merged.Variables["c"] != "overlay" {
+		t.Errorf("overlay literal should replace base provider: %v %v", merged.Variables, merged.providers)
+	}
+	if merged.Variables["b"] != "base" {
+		t.Errorf("b = %q", merged.Variables["b"])
+	}
+}
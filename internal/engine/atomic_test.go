@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+)
+
+// atomicTestSync sets up two sources: "good" is fully cached, "bad" is a
+// git source whose fetch fails.
+func atomicTestSync(t *testing.T, projectRoot string) (*SyncEngine, config.Config, lock.Lockfile) {
+	t.Helper()
+	eng, cfg, lf := cachedTestSync(t, projectRoot, "good", ".out/good/", map[string]string{"a.md": "good content"})
+	eng.Registry = newTestRegistry(map[string]*mockResolver{"git": {err: fmt.Errorf("clone failed")}})
+	cfg.Sources = append(cfg.Sources, config.Source{Name: "bad", Type: "git", Repo: "https://example.com/r.git", Ref: "main"})
+	cfg.Targets = append(cfg.Targets, config.Target{Source: "bad", Destination: ".out/bad/"})
+	lf.Sources = append(lf.Sources, lock.LockedSource{Name: "bad", Type: "git", Repo: "https://example.com/r.git", Status: "ok", Resolved: lock.ResolvedState{
+		Commit: "abc123", Files: map[string]lock.FileHash{"b.md": {SHA256: "bbbb"}},
+	}})
+	return eng, cfg, lf
+}
+
+func TestSyncAtomicWritesNothingOnSourceFailure(t *testing.T) {
+	projectRoot := t.TempDir()
+	eng, cfg, lf := atomicTestSync(t, projectRoot)
+
+	for _, dryRun := range []bool{true, false} {
+		result, err := eng.Sync(context.Background(), lf, cfg, SyncOptions{Atomic: true, DryRun: dryRun})
+		if !errors.Is(err, ErrSyncAborted) {
+			t.Fatalf("dryRun=%v: error = %v, want ErrSyncAborted", dryRun, err)
+		}
+		if len(result.Errors) != 1 || result.Errors[0].Source != "bad" {
+			t.Errorf("dryRun=%v: errors = %v, want the bad source", dryRun, result.Errors)
+		}
+		if len(result.Written) != 0 {
+			t.Errorf("dryRun=%v: written = %v, want none", dryRun, result.Written)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(projectRoot, ".out", "good", "a.md")); !os.IsNotExist(err) {
+		t.Error("atomic sync must not write the healthy source when another fails")
+	}
+}
+
+func TestSyncNonAtomicWritesHealthySources(t *testing.T) {
+	projectRoot := t.TempDir()
+	eng, cfg, lf := atomicTestSync(t, projectRoot)
+
+	result, err := eng.Sync(context.Background(), lf, cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(result.Errors) != 1 || len(result.Written) != 1 {
+		t.Errorf("result = %+v, want one error and one written file", result)
+	}
+}
+
+func TestSyncWriteFailureRollsBackEveryFile(t *testing.T) {
+	projectRoot := t.TempDir()
+	eng, cfg, lf := cachedTestSync(t, projectRoot, "src", ".out/", map[string]string{
+		"a.md": "content of a.md", "b.md": "content of b.md", "c.md": "content of c.md",
+	})
+
+	outDir := filepath.Join(projectRoot, ".out")
+	if err := os.MkdirAll(filepath.Join(outDir, "c.md"), 0755); err != nil {
+		t.Fatal(err) // a directory where c.md must go makes the last write fail
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "a.md"), []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := eng.Sync(context.Background(), lf, cfg, SyncOptions{Atomic: true}); err == nil {
+		t.Fatal("expected write failure")
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "a.md"))
+	if err != nil || string(data) != "previous" {
+		t.Errorf("a.md = %q, %v; want restored previous content", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "b.md")); !os.IsNotExist(err) {
+		t.Error("b.md should be removed by rollback")
+	}
+}
@@ -42,6 +42,41 @@ func newTestRegistry(resolvers map[string]*mockResolver) *source.Registry {
 	return reg
 }
 
+// cachedTestSync returns a sync engine, config, and lockfile for one local
+// source whose files are all in the engine's cache, synced to destination.
+func cachedTestSync(t *testing.T, projectRoot, name, destination string, files map[string]string) (*SyncEngine, config.Config, lock.Lockfile) {
+	t.Helper()
+	c, _ := cache.New(t.TempDir())
+	locked := make(map[string]lock.FileHash, len(files))
+	for path, content := range files {
+		hash := cache.ComputeHash([]byte(content))
+		if err := c.Put(hash, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		locked[path] = lock.FileHash{SHA256: hash}
+	}
+
+	eng := &SyncEngine{
+		Registry:    newTestRegistry(nil),
+		Cache:       c,
+		ToolMap:     target.NewToolMap(nil),
+		ProjectRoot: projectRoot,
+	}
+	cfg := config.Config{
+		Version: 1,
+		Sources: []config.Source{{Name: name, Type: "local", Path: "./" + name + "/"}},
+		Targets: []config.Target{{Source: name, Destination: destination}},
+	}
+	lf := lock.Lockfile{
+		Version: 1,
+		Sources: []lock.LockedSource{{
+			Name: name, Type: "local", Status: "ok",
+			Resolved: lock.ResolvedState{Path: "./" + name + "/", Files: locked},
+		}},
+	}
+	return eng, cfg, lf
+}
+
 func TestSyncEngineBasic(t *testing.T) {
 	projectRoot := t.TempDir()
 	cacheDir := t.TempDir()
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/flock"
+)
+
+// OpLockPath is the advisory lock file taken by mutating operations,
+// relative to the project root.
+const OpLockPath = ".agent-sync/lock"
+
+// stateIgnore lists the entries under .agent-sync/ that hold machine-local
+// state and must not be committed.
+var stateIgnore = []string{"lock", "journal/"}
+
+// ProjectLockedError reports that another operation holds the project lock.
+type ProjectLockedError struct {
+	Path   string
+	Holder string // e.g. "update (pid 4242)"; empty if unknown
+}
+
+func (e *ProjectLockedError) Error() string {
+	if e.Holder == "" {
+		return fmt.Sprintf("another agent-sync operation holds %s", e.Path)
+	}
+	return fmt.Sprintf("another agent-sync operation (%s) holds %s", e.Holder, e.Path)
+}
+
+func (e *ProjectLockedError) Unwrap() error {
+	return flock.ErrLocked
+}
+
+// LockProject takes the project's operation lock so that concurrent
+// mutating operations (sync, update, prune, vendor) cannot interleave their
+// writes. It waits up to wait for a running operation to finish; with
+// wait <= 0 it fails immediately. The caller must Unlock the returned lock.
+func LockProject(ctx context.Context, projectRoot, operation string, wait time.Duration) (*flock.Lock, error) {
+	path := filepath.Join(projectRoot, filepath.FromSlash(OpLockPath))
+	holder := fmt.Sprintf("%s (pid %d)", operation, os.Getpid())
+
+	l, err := flock.Acquire(ctx, path, holder, wait)
+	if errors.Is(err, flock.ErrLocked) {
+		return nil, &ProjectLockedError{Path: OpLockPath, Holder: flock.Holder(path)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ensureStateIgnore(filepath.Dir(path))
+	return l, nil
+}
+
+// ensureStateIgnore keeps a .gitignore in the state directory listing the
+// machine-local entries, so they never show up as untracked files. Lines the
+// user added are preserved.
+func ensureStateIgnore(stateDir string) {
+	path := filepath.Join(stateDir, ".gitignore")
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	present := make(map[string]bool)
+	for _, line := range strings.Split(string(existing), "\n") {
+		present[strings.TrimSpace(line)] = true
+	}
+
+	content := string(existing)
+	if content == "" {
+		content = "# Machine-local agent-sync state.\n"
+	} else if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	changed := false
+	for _, entry := range stateIgnore {
+		if !present[entry] {
+			content += entry + "\n"
+			changed = true
+		}
+	}
+	if changed {
+		_ = os.WriteFile(path, []byte(content), 0644)
+	}
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/flock"
+)
+
+func TestLockProjectExcludesConcurrentOperations(t *testing.T) {
+	root := t.TempDir()
+
+	held, err := LockProject(context.Background(), root, "update", 0)
+	if err != nil {
+		t.Fatalf("LockProject: %v", err)
+	}
+
+	_, err = LockProject(context.Background(), root, "sync", 50*time.Millisecond)
+	var locked *ProjectLockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("error = %v, want ProjectLockedError", err)
+	}
+	if !errors.Is(err, flock.ErrLocked) {
+		t.Error("ProjectLockedError should wrap flock.ErrLocked")
+	}
+	if !strings.HasPrefix(locked.Holder, "update (pid ") {
+		t.Errorf("holder = %q, want the update operation", locked.Holder)
+	}
+	if !strings.Contains(err.Error(), OpLockPath) {
+		t.Errorf("error should name %s: %v", OpLockPath, err)
+	}
+
+	if err := held.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := LockProject(context.Background(), root, "sync", 0)
+	if err != nil {
+		t.Fatalf("LockProject after release: %v", err)
+	}
+	_ = again.Unlock()
+}
+
+func TestLockProjectIgnoresLocalState(t *testing.T) {
+	root := t.TempDir()
+	stateDir := filepath.Join(root, ".agent-sync")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ignorePath := filepath.Join(stateDir, ".gitignore")
+	if err := os.WriteFile(ignorePath, []byte("custom"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := LockProject(context.Background(), root, "sync", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Unlock()
+
+	data, err := os.ReadFile(ignorePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if lines[0] != "custom" {
+		t.Errorf("user entries should be preserved, got %q", data)
+	}
+	for _, entry := range stateIgnore {
+		if !strings.Contains(string(data), "\n"+entry+"\n") {
+			t.Errorf(".gitignore missing %q: %q", entry, data)
+		}
+	}
+}
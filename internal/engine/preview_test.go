@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bianoble/agent-sync/internal/cache"
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/source"
+	"github.com/bianoble/agent-sync/internal/target"
+)
+
+func TestPreviewReportsPendingChanges(t *testing.T) {
+	projectRoot := t.TempDir()
+	srcDir := filepath.Join(projectRoot, "rules")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"same.md": "same\n", "changed.md": "new\n", "added.md": "added\n"}
+	locked := make(map[string]lock.FileHash)
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		locked[name] = lock.FileHash{SHA256: cache.ComputeHash([]byte(content))}
+	}
+
+	outDir := filepath.Join(projectRoot, "out")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"same.md": "same\n", "changed.md": "old\n"} {
+		if err := os.WriteFile(filepath.Join(outDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg := source.NewRegistry()
+	reg.Register("local", &source.LocalResolver{})
+	eng := &SyncEngine{Registry: reg, ToolMap: target.NewToolMap(nil), ProjectRoot: projectRoot}
+	cfg := config.Config{
+		Version: 1,
+		Sources: []config.Source{{Name: "rules", Type: "local", Path: "./rules/"}},
+		Targets: []config.Target{{Source: "rules", Destination: "out/"}},
+	}
+	lf := lock.Lockfile{Version: 1, Sources: []lock.LockedSource{{
+		Name: "rules", Type: "local", Status: "ok",
+		Resolved: lock.ResolvedState{Path: "./rules/", Files: locked},
+	}}}
+
+	result, err := eng.Preview(context.Background(), lf, cfg)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Fatalf("errors: %v", result.Errors)
+	}
+	if len(result.Changes) != 2 {
+		t.Fatalf("changes = %+v, want added.md and changed.md", result.Changes)
+	}
+	added, changed := result.Changes[0], result.Changes[1]
+	if added.Path != filepath.Join("out", "added.md") || added.Existed || string(added.After) != "added\n" {
+		t.Errorf("added = %+v", added)
+	}
+	if changed.Path != filepath.Join("out", "changed.md") || !changed.Existed || string(changed.Before) != "old\n" || string(changed.After) != "new\n" {
+		t.Errorf("changed = %+v", changed)
+	}
+
+	// Preview never writes.
+	if data, _ := os.ReadFile(filepath.Join(outDir, "changed.md")); string(data) != "old\n" {
+		t.Error("Preview must not modify target files")
+	}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/journal"
+)
+
+// RecoverEngine finishes or undoes an operation that was interrupted
+// mid-write, using the journal it left behind.
+type RecoverEngine struct {
+	ProjectRoot string
+}
+
+// RecoverOptions configures a recover operation.
+type RecoverOptions struct {
+	// Rollback restores the files as they were before the interrupted
+	// operation. By default the operation is rolled forward (completed).
+	Rollback bool
+	// Discard drops the journal and leaves every file as it is, once the
+	// files that changed since the interruption have been dealt with.
+	Discard bool
+}
+
+// RecoverResult holds the outcome of a recover operation.
+type RecoverResult struct {
+	Started    time.Time
+	Operation  string   // the interrupted operation, e.g. "sync"
+	Applied    []string // paths restored or completed
+	Conflicts  []string // paths modified since the interruption, left untouched
+	Unchanged  int
+	RolledBack bool
+	Discarded  bool
+}
+
+// Recover rolls an interrupted operation forward or back. It returns nil
+// if there is nothing to recover.
+func (e *RecoverEngine) Recover(ctx context.Context, opts RecoverOptions) (*RecoverResult, error) {
+	jnl, err := journal.Open(e.ProjectRoot)
+	if err != nil || jnl == nil {
+		return nil, err
+	}
+
+	if opts.Discard {
+		if err := jnl.Complete(); err != nil {
+			return nil, err
+		}
+		return &RecoverResult{Started: jnl.Started, Operation: jnl.Operation, Discarded: true}, nil
+	}
+
+	var res *journal.Result
+	if opts.Rollback {
+		res, err = jnl.RollBack()
+	} else {
+		res, err = jnl.RollForward()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoverResult{
+		Started:    jnl.Started,
+		Operation:  jnl.Operation,
+		Applied:    res.Applied,
+		Conflicts:  res.Conflicts,
+		Unchanged:  res.Unchanged,
+		RolledBack: opts.Rollback,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bianoble/agent-sync/internal/journal"
+)
+
+func TestSyncRemovesJournalOnSuccess(t *testing.T) {
+	projectRoot := t.TempDir()
+	eng, cfg, lf := cachedTestSync(t, projectRoot, "rules", ".out/", map[string]string{"rules.md": "# Rules\n"})
+
+	if _, err := eng.Sync(context.Background(), lf, cfg, SyncOptions{}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(projectRoot, journal.Dir)); !os.IsNotExist(err) {
+		t.Error("journal should be removed after a successful sync")
+	}
+}
+
+func TestSyncRefusesWithPendingJournal(t *testing.T) {
+	projectRoot := t.TempDir()
+	eng, cfg, lf := cachedTestSync(t, projectRoot, "rules", ".out/", map[string]string{"rules.md": "# Rules\n"})
+
+	if _, err := journal.Begin(projectRoot, "sync", []journal.Change{{Path: "other.md", After: []byte("x")}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Sync(context.Background(), lf, cfg, SyncOptions{}); !errors.Is(err, journal.ErrPending) {
+		t.Fatalf("Sync error = %v, want journal.ErrPending", err)
+	}
+	if _, err := os.Stat(filepath.Join(projectRoot, ".out", "rules.md")); !os.IsNotExist(err) {
+		t.Error("no target may be written while a journal is pending")
+	}
+}
+
+func TestRecoverEngine(t *testing.T) {
+	projectRoot := t.TempDir()
+	eng := &RecoverEngine{ProjectRoot: projectRoot}
+
+	result, err := eng.Recover(context.Background(), RecoverOptions{})
+	if err != nil || result != nil {
+		t.Fatalf("Recover with no journal = %+v, %v; want nil, nil", result, err)
+	}
+
+	changes := []journal.Change{{Path: filepath.Join(".out", "a.md"), After: []byte("new")}}
+	if _, err := journal.Begin(projectRoot, "sync", changes); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = eng.Recover(context.Background(), RecoverOptions{})
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if result.Operation != "sync" || len(result.Applied) != 1 || result.RolledBack {
+		t.Errorf("result = %+v", result)
+	}
+	data, err := os.ReadFile(filepath.Join(projectRoot, ".out", "a.md"))
+	if err != nil || string(data) != "new" {
+		t.Errorf("a.md = %q, %v; want rolled-forward content", data, err)
+	}
+}
+
+func TestRecoverEngineDiscard(t *testing.T) {
+	projectRoot := t.TempDir()
+	eng := &RecoverEngine{ProjectRoot: projectRoot}
+
+	changes := []journal.Change{{Path: filepath.Join(".out", "a.md"), After: []byte("new")}}
+	if _, err := journal.Begin(projectRoot, "sync", changes); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(projectRoot, ".out"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectRoot, ".out", "a.md"), []byte("hand edit"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := eng.Recover(context.Background(), RecoverOptions{})
+	if err != nil || len(result.Conflicts) != 1 {
+		t.Fatalf("Recover = %+v, %v; want one conflict", result, err)
+	}
+	if _, err := os.Stat(filepath.Join(projectRoot, journal.Dir)); err != nil {
+		t.Fatalf("journal should be kept while a file conflicts: %v", err)
+	}
+
+	result, err = eng.Recover(context.Background(), RecoverOptions{Discard: true})
+	if err != nil || !result.Discarded {
+		t.Fatalf("Recover with Discard = %+v, %v", result, err)
+	}
+	if _, err := os.Stat(filepath.Join(projectRoot, journal.Dir)); !os.IsNotExist(err) {
+		t.Error("journal should be removed by Discard")
+	}
+	data, _ := os.ReadFile(filepath.Join(projectRoot, ".out", "a.md"))
+	if string(data) != "hand edit" {
+		t.Errorf("a.md = %q, want it left as it is", data)
+	}
+}
@@ -18,11 +18,13 @@ type StatusEngine struct {
 
 // SourceStatus describes the current state of a source.
 type SourceStatus struct {
-	Name     string
-	Type     string
-	PinnedAt string
-	State    string // "synced", "drifted", "missing", "pending"
-	Targets  []string
+	Name      string
+	Type      string
+	PinnedAt  string
+	State     string // "synced", "drifted", "missing", "pending"
+	PinReason string
+	Targets   []string
+	Pinned    bool // frozen with 'agent-sync pin'
 }
 
 // Status returns the state of all (or named) sources.
@@ -64,6 +66,8 @@ func (e *StatusEngine) Status(ctx context.Context, lf lock.Lockfile, cfg config.
 			s.PinnedAt = "(not locked)"
 		} else {
 			s.PinnedAt = summarizeLocked(ls)
+			s.Pinned = ls.Pinned()
+			s.PinReason = ls.PinReason
 			s.State = computeState(e.ProjectRoot, ls, targets)
 		}
 
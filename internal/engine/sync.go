@@ -1,16 +1,20 @@
 package engine
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/bianoble/agent-sync/internal/cache"
 	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/journal"
 	"github.com/bianoble/agent-sync/internal/lock"
 	"github.com/bianoble/agent-sync/internal/sandbox"
 	"github.com/bianoble/agent-sync/internal/source"
@@ -22,6 +26,7 @@ import (
 type SyncEngine struct {
 	Registry    *source.Registry
 	Cache       *cache.Cache
+	Vendor      *cache.Cache // optional in-repo vendor store, preferred over Cache
 	ToolMap     *target.ToolMap
 	ProjectRoot string
 }
@@ -29,17 +34,167 @@ type SyncEngine struct {
 // SyncOptions configures a sync operation.
 type SyncOptions struct {
 	DryRun bool
+
+	// Atomic writes nothing unless every source fetches and renders
+	// successfully. Failures during the write phase always roll back.
+	Atomic bool
 }
 
+// ErrSyncAborted is returned by an atomic sync that wrote nothing because
+// at least one source failed.
+var ErrSyncAborted = errors.New("atomic sync aborted; no files written")
+
 // Sync synchronizes files to targets using the lockfile as the source of truth.
 // It does NOT modify the lockfile.
 func (e *SyncEngine) Sync(ctx context.Context, lf lock.Lockfile, cfg config.Config, opts SyncOptions) (*SyncResult, error) {
 	result := &SyncResult{}
 
+	ops, sourceErrs, err := e.plan(ctx, lf, cfg)
+	if err != nil {
+		return nil, err
+	}
+	result.Errors = sourceErrs
+
+	if opts.Atomic && len(result.Errors) > 0 {
+		return result, fmt.Errorf("%d source(s) failed: %w", len(result.Errors), ErrSyncAborted)
+	}
+
+	if opts.DryRun {
+		for _, op := range ops {
+			absPath := filepath.Join(e.ProjectRoot, op.destPath)
+			existing, err := os.ReadFile(absPath)
+			if err != nil {
+				result.Written = append(result.Written, FileAction{Path: op.destPath, Action: "new"})
+			} else if hex.EncodeToString(sha256Hash(existing)) != hex.EncodeToString(sha256Hash(op.content)) {
+				result.Written = append(result.Written, FileAction{Path: op.destPath, Action: "modified"})
+			} else {
+				result.Skipped = append(result.Skipped, FileAction{Path: op.destPath, Action: "unchanged"})
+			}
+		}
+		return result, nil
+	}
+
+	// Snapshot existing files for rollback, and plan the writes.
+	var snapshots []snapshot
+	var writtenPaths []string
+	var changes []journal.Change
+	for _, op := range ops {
+		absPath := filepath.Join(e.ProjectRoot, op.destPath)
+		existing, err := os.ReadFile(absPath)
+		if err == nil {
+			snapshots = append(snapshots, snapshot{path: op.destPath, content: existing, existed: true})
+		} else {
+			snapshots = append(snapshots, snapshot{path: op.destPath, existed: false})
+		}
+		if err != nil || hex.EncodeToString(sha256Hash(existing)) != hex.EncodeToString(sha256Hash(op.content)) {
+			changes = append(changes, journal.Change{Path: op.destPath, Before: existing, After: op.content, Existed: err == nil})
+		}
+	}
+
+	// Journal the planned writes so that an interrupted sync can be
+	// recovered on the next run.
+	var jnl *journal.Journal
+	if len(changes) > 0 {
+		jnl, err = journal.Begin(e.ProjectRoot, "sync", changes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Write files.
+	for _, op := range ops {
+		absPath := filepath.Join(e.ProjectRoot, op.destPath)
+		existing, readErr := os.ReadFile(absPath)
+		if readErr == nil && hex.EncodeToString(sha256Hash(existing)) == hex.EncodeToString(sha256Hash(op.content)) {
+			result.Skipped = append(result.Skipped, FileAction{Path: op.destPath, Action: "unchanged"})
+			continue
+		}
+
+		if err := sandbox.SafeWrite(e.ProjectRoot, op.destPath, op.content, 0644); err != nil {
+			// Rollback.
+			rollback(e.ProjectRoot, writtenPaths, snapshots)
+			_ = jnl.Complete()
+			result.Errors = append(result.Errors, SourceError{Source: op.source, Err: fmt.Errorf("writing %s: %w", op.destPath, err)})
+			return result, fmt.Errorf("sync failed, rolled back: %w", err)
+		}
+
+		action := "written"
+		if readErr == nil {
+			action = "modified"
+		}
+		writtenPaths = append(writtenPaths, op.destPath)
+		result.Written = append(result.Written, FileAction{Path: op.destPath, Action: action})
+	}
+
+	if err := jnl.Complete(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// Preview reports what Sync would change on disk, with the current and new
+// content of every affected file, without writing anything.
+func (e *SyncEngine) Preview(ctx context.Context, lf lock.Lockfile, cfg config.Config) (*PreviewResult, error) {
+	ops, sourceErrs, err := e.plan(ctx, lf, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PreviewResult{Errors: sourceErrs}
+	for _, op := range ops {
+		absPath, err := sandbox.ValidatePath(e.ProjectRoot, op.destPath)
+		if err != nil {
+			result.Errors = append(result.Errors, SourceError{Source: op.source, Err: err})
+			continue
+		}
+		existing, readErr := os.ReadFile(absPath)
+		if readErr == nil && bytes.Equal(existing, op.content) {
+			continue
+		}
+		result.Changes = append(result.Changes, TargetChange{
+			Path:    op.destPath,
+			Source:  op.source,
+			Before:  existing,
+			After:   op.content,
+			Existed: readErr == nil,
+		})
+	}
+	return result, nil
+}
+
+// fileOp is one target file that sync would write.
+type fileOp struct {
+	enforced *config.Target // the enforced target that writes the file, if any
+	destPath string         // relative to project root
+	source   string
+	content  []byte
+}
+
+// plan fetches and renders every locked source with targets and returns
+// the target files to write, sorted by path, along with per-source
+// failures. It does not touch the project.
+func (e *SyncEngine) plan(ctx context.Context, lf lock.Lockfile, cfg config.Config) ([]fileOp, []SourceError, error) {
 	// Resolve all targets.
 	targetMap, err := resolveAllTargets(e.ToolMap, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("resolving targets: %w", err)
+		return nil, nil, fmt.Errorf("resolving targets: %w", err)
+	}
+
+	// Find the destinations of enforced targets, whose files overrides from
+	// higher config layers may not replace.
+	enforced := make(map[target.ResolvedTarget]*config.Target)
+	for i, tgt := range cfg.Targets {
+		if !tgt.Enforced {
+			continue
+		}
+		resolved, err := e.ToolMap.ResolveTarget(tgt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resolving targets: %w", err)
+		}
+		for _, rt := range resolved {
+			enforced[rt] = &cfg.Targets[i]
+		}
 	}
 
 	// Build a lookup of locked sources by name.
@@ -54,17 +209,8 @@ func (e *SyncEngine) Sync(ctx context.Context, lf lock.Lockfile, cfg config.Conf
 		transformsBySource[tx.Source] = append(transformsBySource[tx.Source], tx)
 	}
 
-	// Snapshot existing target files for rollback.
-	var snapshots []snapshot
-	var writtenPaths []string
-
-	// Collect all file operations to perform.
-	type fileOp struct {
-		destPath string // relative to project root
-		source   string
-		content  []byte
-	}
 	var ops []fileOp
+	var errs []SourceError
 
 	// Process each locked source.
 	for _, ls := range lf.Sources {
@@ -76,7 +222,7 @@ func (e *SyncEngine) Sync(ctx context.Context, lf lock.Lockfile, cfg config.Conf
 		// Fetch content for this source.
 		files, fetchErr := e.fetchSourceFiles(ctx, ls, cfg)
 		if fetchErr != nil {
-			result.Errors = append(result.Errors, SourceError{Source: ls.Name, Err: fetchErr})
+			errs = append(errs, SourceError{Source: ls.Name, Err: fetchErr})
 			continue
 		}
 
@@ -84,7 +230,7 @@ func (e *SyncEngine) Sync(ctx context.Context, lf lock.Lockfile, cfg config.Conf
 		if transforms, hasTx := transformsBySource[ls.Name]; hasTx {
 			files, fetchErr = applyTransforms(files, transforms, cfg.Variables)
 			if fetchErr != nil {
-				result.Errors = append(result.Errors, SourceError{Source: ls.Name, Err: fetchErr})
+				errs = append(errs, SourceError{Source: ls.Name, Err: fetchErr})
 				continue
 			}
 		}
@@ -93,7 +239,7 @@ func (e *SyncEngine) Sync(ctx context.Context, lf lock.Lockfile, cfg config.Conf
 		for _, tgt := range targets {
 			for relPath, content := range files {
 				destPath := filepath.Join(tgt.Destination, relPath)
-				ops = append(ops, fileOp{destPath: destPath, content: content, source: ls.Name})
+				ops = append(ops, fileOp{destPath: destPath, content: content, source: ls.Name, enforced: enforced[tgt]})
 			}
 		}
 	}
@@ -106,9 +252,19 @@ func (e *SyncEngine) Sync(ctx context.Context, lf lock.Lockfile, cfg config.Conf
 		for _, op := range ops {
 			filesByName[filepath.Base(op.destPath)] = op.content
 		}
+		for _, ov := range cfg.Overrides {
+			for _, op := range ops {
+				if op.enforced == nil || filepath.Base(op.destPath) != ov.Target {
+					continue
+				}
+				if err := config.CheckOverride(*op.enforced, ov); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
 		applied, overrideErr := overrideProc.Apply(filesByName, cfg.Overrides)
 		if overrideErr != nil {
-			return nil, fmt.Errorf("applying overrides: %w", overrideErr)
+			return nil, nil, fmt.Errorf("applying overrides: %w", overrideErr)
 		}
 		// Update ops with overridden content.
 		for i, op := range ops {
@@ -124,70 +280,52 @@ func (e *SyncEngine) Sync(ctx context.Context, lf lock.Lockfile, cfg config.Conf
 		return ops[i].destPath < ops[j].destPath
 	})
 
-	if opts.DryRun {
-		for _, op := range ops {
-			absPath := filepath.Join(e.ProjectRoot, op.destPath)
-			existing, err := os.ReadFile(absPath)
-			if err != nil {
-				result.Written = append(result.Written, FileAction{Path: op.destPath, Action: "new"})
-			} else if hex.EncodeToString(sha256Hash(existing)) != hex.EncodeToString(sha256Hash(op.content)) {
-				result.Written = append(result.Written, FileAction{Path: op.destPath, Action: "modified"})
-			} else {
-				result.Skipped = append(result.Skipped, FileAction{Path: op.destPath, Action: "unchanged"})
-			}
-		}
-		return result, nil
-	}
+	return ops, errs, nil
+}
 
-	// Snapshot existing files for rollback.
-	for _, op := range ops {
-		absPath := filepath.Join(e.ProjectRoot, op.destPath)
-		existing, err := os.ReadFile(absPath)
-		if err == nil {
-			snapshots = append(snapshots, snapshot{path: op.destPath, content: existing, existed: true})
-		} else {
-			snapshots = append(snapshots, snapshot{path: op.destPath, existed: false})
+// expandLocked resolves the ${...} references a lockfile entry's repo, url,
+// and path may hold; see config.Source.Recorded. The lockfile is not
+// trusted to name variables: a value with references is only resolved when
+// it is exactly what the config's source of that name records, and then to
+// the value the config itself resolved. Anything else could read secrets
+// from the environment into a URL of the lockfile's choosing.
+func expandLocked(cfg *config.Config, ls lock.LockedSource) (repo, url, path string, err error) {
+	var src, recorded config.Source
+	for _, s := range cfg.Sources {
+		if s.Name == ls.Name {
+			src, recorded = s, s.Recorded()
+			break
 		}
 	}
-
-	// Write files.
-	for _, op := range ops {
-		absPath := filepath.Join(e.ProjectRoot, op.destPath)
-		existing, readErr := os.ReadFile(absPath)
-		if readErr == nil && hex.EncodeToString(sha256Hash(existing)) == hex.EncodeToString(sha256Hash(op.content)) {
-			result.Skipped = append(result.Skipped, FileAction{Path: op.destPath, Action: "unchanged"})
-			continue
-		}
-
-		if err := sandbox.SafeWrite(e.ProjectRoot, op.destPath, op.content, 0644); err != nil {
-			// Rollback.
-			rollback(e.ProjectRoot, writtenPaths, snapshots)
-			result.Errors = append(result.Errors, SourceError{Source: op.source, Err: fmt.Errorf("writing %s: %w", op.destPath, err)})
-			return result, fmt.Errorf("sync failed, rolled back: %w", err)
+	expand := func(field, locked, written, resolved string) (string, error) {
+		if !strings.Contains(locked, "${") {
+			return locked, nil
 		}
-
-		action := "written"
-		if readErr == nil {
-			action = "modified"
+		if locked != written {
+			return "", fmt.Errorf("%s %q has ${...} references that do not match the config; run 'agent-sync update'", field, locked)
 		}
-		writtenPaths = append(writtenPaths, op.destPath)
-		result.Written = append(result.Written, FileAction{Path: op.destPath, Action: action})
+		return resolved, nil
 	}
-
-	return result, nil
+	if repo, err = expand("repo", ls.Repo, recorded.Repo, src.Repo); err != nil {
+		return "", "", "", err
+	}
+	if url, err = expand("url", ls.Resolved.URL, recorded.URL, src.URL); err != nil {
+		return "", "", "", err
+	}
+	if path, err = expand("path", ls.Resolved.Path, recorded.Path, src.Path); err != nil {
+		return "", "", "", err
+	}
+	return repo, url, path, nil
 }
 
 func (e *SyncEngine) fetchSourceFiles(ctx context.Context, ls lock.LockedSource, cfg config.Config) (map[string][]byte, error) {
 	files := make(map[string][]byte)
 
 	for relPath, fh := range ls.Resolved.Files {
-		// Try cache first.
-		if e.Cache != nil {
-			content, found, err := e.Cache.Get(fh.SHA256)
-			if err == nil && found {
-				files[relPath] = content
-				continue
-			}
+		// Try vendor store and cache first.
+		if content, found := e.lookupLocal(fh.SHA256); found {
+			files[relPath] = content
+			continue
 		}
 
 		// Fetch from source.
@@ -197,21 +335,29 @@ func (e *SyncEngine) fetchSourceFiles(ctx context.Context, ls lock.LockedSource,
 		}
 
 		// Build a ResolvedSource from the lockfile entry.
+		repo, url, path, err := expandLocked(&cfg, ls)
+		if err != nil {
+			return nil, err
+		}
 		resolved := &source.ResolvedSource{
 			Name:   ls.Name,
 			Type:   ls.Type,
 			Commit: ls.Resolved.Commit,
 			Tree:   ls.Resolved.Tree,
-			URL:    ls.Resolved.URL,
-			Repo:   ls.Repo,
-			Path:   ls.Resolved.Path,
+			URL:    url,
+			Repo:   repo,
+			Path:   path,
 			Files:  make(map[string]string),
 		}
 		for fp, hash := range ls.Resolved.Files {
 			resolved.Files[fp] = hash.SHA256
 		}
 
-		fetched, fetchErr := resolver.Fetch(ctx, resolved)
+		fetched, fetchErr := fetchResolved(ctx, resolver, resolved, e.ProjectRoot)
+		if errors.Is(fetchErr, source.ErrOffline) {
+			e.fillFromLocal(ls, files)
+			return nil, missingObjects(ls, files)
+		}
 		if fetchErr != nil {
 			return nil, fetchErr
 		}
@@ -225,19 +371,70 @@ func (e *SyncEngine) fetchSourceFiles(ctx context.Context, ls lock.LockedSource,
 		break // All files fetched in one call
 	}
 
-	// If we didn't get all files from the batch fetch, fill remaining from cache.
+	// If we didn't get all files from the batch fetch, fill remaining from local storage.
+	e.fillFromLocal(ls, files)
+
+	return files, nil
+}
+
+// fetchResolved fetches the files of a resolved source. Local source paths
+// are relative to the project root, so local sources are read through
+// FetchWithRoot.
+func fetchResolved(ctx context.Context, resolver source.Resolver, resolved *source.ResolvedSource, projectRoot string) ([]source.FetchedFile, error) {
+	if local, ok := resolver.(*source.LocalResolver); ok {
+		return local.FetchWithRoot(ctx, resolved, projectRoot)
+	}
+	return resolver.Fetch(ctx, resolved)
+}
+
+// lookupLocal returns verified content for hash from the vendor store or,
+// failing that, the cache.
+func (e *SyncEngine) lookupLocal(hash string) ([]byte, bool) {
+	for _, store := range []*cache.Cache{e.Vendor, e.Cache} {
+		if store == nil {
+			continue
+		}
+		content, found, err := store.Get(hash)
+		if err == nil && found {
+			return content, true
+		}
+	}
+	return nil, false
+}
+
+// fillFromLocal adds any files of ls that are missing from files but present
+// in the vendor store or cache.
+func (e *SyncEngine) fillFromLocal(ls lock.LockedSource, files map[string][]byte) {
 	for relPath, fh := range ls.Resolved.Files {
-		if _, ok := files[relPath]; !ok {
-			if e.Cache != nil {
-				content, found, _ := e.Cache.Get(fh.SHA256)
-				if found {
-					files[relPath] = content
-				}
-			}
+		if _, ok := files[relPath]; ok {
+			continue
+		}
+		if content, found := e.lookupLocal(fh.SHA256); found {
+			files[relPath] = content
 		}
 	}
+}
 
-	return files, nil
+// missingObjects reports every file of ls that is absent from files,
+// in path order.
+func missingObjects(ls lock.LockedSource, files map[string][]byte) *MissingObjectsError {
+	paths := make([]string, 0, len(ls.Resolved.Files))
+	for relPath := range ls.Resolved.Files {
+		if _, ok := files[relPath]; !ok {
+			paths = append(paths, relPath)
+		}
+	}
+	sort.Strings(paths)
+
+	missing := &MissingObjectsError{}
+	for _, p := range paths {
+		missing.Objects = append(missing.Objects, MissingObject{
+			Source: ls.Name,
+			Path:   p,
+			SHA256: ls.Resolved.Files[p].SHA256,
+		})
+	}
+	return missing
 }
 
 func applyTransforms(files map[string][]byte, transforms []config.Transform, globalVars map[string]string) (map[string][]byte, error) {
@@ -2,9 +2,11 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/bianoble/agent-sync/internal/cache"
@@ -250,6 +252,67 @@ func TestSyncEngineWithOverrides(t *testing.T) {
 	}
 }
 
+func TestSyncEngineOverrideCannotReplaceEnforcedTarget(t *testing.T) {
+	projectRoot := t.TempDir()
+	c, _ := cache.New(t.TempDir())
+
+	content := []byte("base content")
+	contentHash := cache.ComputeHash(content)
+	if err := os.WriteFile(filepath.Join(projectRoot, "mine.md"), []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := newTestRegistry(map[string]*mockResolver{
+		"local": {
+			files: []source.FetchedFile{
+				{RelPath: "rules.md", Content: content, SHA256: contentHash},
+			},
+		},
+	})
+	eng := &SyncEngine{
+		Registry:    reg,
+		Cache:       c,
+		ToolMap:     target.NewToolMap(nil),
+		ProjectRoot: projectRoot,
+	}
+
+	system := config.Origin{File: "/etc/agent-sync/config.yaml", Layer: config.LevelSystem, Line: 3, Column: 5}
+	project := config.Origin{File: "agent-sync.yaml", Layer: config.LevelProject, Line: 4, Column: 5}
+	cfg := config.Config{
+		Version: 1,
+		Sources: []config.Source{{Name: "src", Type: "local", Path: "./src/"}},
+		Targets: []config.Target{{Source: "src", Destination: ".out/", Enforced: true, Origin: system}},
+		Overrides: []config.Override{
+			{Target: "rules.md", Strategy: "replace", File: "mine.md", Origin: project},
+		},
+	}
+	lf := lock.Lockfile{
+		Version: 1,
+		Sources: []lock.LockedSource{{
+			Name: "src", Type: "local",
+			Resolved: lock.ResolvedState{
+				Path:  "./src/",
+				Files: map[string]lock.FileHash{"rules.md": {SHA256: contentHash}},
+			},
+			Status: "ok",
+		}},
+	}
+
+	_, err := eng.Sync(context.Background(), lf, cfg, SyncOptions{})
+	want := "target 'src' is enforced by /etc/agent-sync/config.yaml:3:5 (system config) and its file 'rules.md' cannot be replaced by agent-sync.yaml:4:5 (project config)"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("Sync error = %v, want %q", err, want)
+	}
+	if _, statErr := os.Stat(filepath.Join(projectRoot, ".out/rules.md")); !os.IsNotExist(statErr) {
+		t.Error("sync wrote the enforced target despite the error")
+	}
+
+	cfg.Overrides[0].Strategy = "append"
+	if _, err := eng.Sync(context.Background(), lf, cfg, SyncOptions{}); err != nil {
+		t.Errorf("append to enforced target: %v", err)
+	}
+}
+
 func TestSyncEngineFetchError(t *testing.T) {
 	projectRoot := t.TempDir()
 	cacheDir := t.TempDir()
@@ -590,3 +653,69 @@ func TestResolveAllTargetsError(t *testing.T) {
 		t.Error("expected error for unknown tool in target")
 	}
 }
+
+func TestSyncEngineOfflineListsMissingObjects(t *testing.T) {
+	projectRoot := t.TempDir()
+	c, _ := cache.New(t.TempDir())
+
+	cached := []byte("cached")
+	cachedHash := cache.ComputeHash(cached)
+	if err := c.Put(cachedHash, cached); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := source.NewRegistry()
+	reg.Register("git", source.OfflineResolver{})
+
+	eng := &SyncEngine{
+		Registry:    reg,
+		Cache:       c,
+		ToolMap:     target.NewToolMap(nil),
+		ProjectRoot: projectRoot,
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Sources: []config.Source{{Name: "rules", Type: "git", Repo: "https://example.com/r.git", Ref: "main"}},
+		Targets: []config.Target{{Source: "rules", Destination: ".out/"}},
+	}
+	lf := lock.Lockfile{
+		Version: 1,
+		Sources: []lock.LockedSource{{
+			Name: "rules", Type: "git", Repo: "https://example.com/r.git", Status: "ok",
+			Resolved: lock.ResolvedState{
+				Commit: "abc123",
+				Files: map[string]lock.FileHash{
+					"a.md": {SHA256: cachedHash},
+					"b.md": {SHA256: "bbbb"},
+					"c.md": {SHA256: "cccc"},
+				},
+			},
+		}},
+	}
+
+	result, err := eng.Sync(context.Background(), lf, cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Fatalf("errors = %d, want 1", len(result.Errors))
+	}
+
+	var missing *MissingObjectsError
+	if !errors.As(result.Errors[0].Err, &missing) {
+		t.Fatalf("error = %v, want MissingObjectsError", result.Errors[0].Err)
+	}
+	if len(missing.Objects) != 2 {
+		t.Fatalf("missing = %+v, want b.md and c.md", missing.Objects)
+	}
+	msg := missing.Error()
+	for _, want := range []string{"not in cache: rules/b.md sha256:bbbb", "not in cache: rules/c.md sha256:cccc"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+	if !errors.Is(missing, source.ErrOffline) {
+		t.Error("MissingObjectsError should unwrap to source.ErrOffline")
+	}
+}
@@ -1,5 +1,12 @@
 package engine
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bianoble/agent-sync/internal/source"
+)
+
 // FileAction represents an action taken on a single file during sync or prune.
 type FileAction struct {
 	Path   string
@@ -20,6 +27,33 @@ func (e SourceError) Unwrap() error {
 	return e.Err
 }
 
+// MissingObject identifies a locked file whose content is not available
+// without network access.
+type MissingObject struct {
+	Source string
+	Path   string
+	SHA256 string
+}
+
+// MissingObjectsError lists every object that could not be served from
+// local storage while offline.
+type MissingObjectsError struct {
+	Objects []MissingObject
+}
+
+func (e *MissingObjectsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d object(s) not available offline:", len(e.Objects))
+	for _, o := range e.Objects {
+		fmt.Fprintf(&b, "\n  not in cache: %s/%s sha256:%s", o.Source, o.Path, o.SHA256)
+	}
+	return b.String()
+}
+
+func (e *MissingObjectsError) Unwrap() error {
+	return source.ErrOffline
+}
+
 // DriftEntry represents a file that has drifted from the expected state.
 type DriftEntry struct {
 	Path     string
@@ -29,9 +63,10 @@ type DriftEntry struct {
 
 // SourceDelta represents a change detected in an upstream source.
 type SourceDelta struct {
-	Source string
-	Before string
-	After  string
+	Source    string
+	Before    string
+	After     string
+	PinReason string // for a pinned source
 }
 
 // SyncResult holds the outcome of a sync operation.
@@ -41,6 +76,21 @@ type SyncResult struct {
 	Errors  []SourceError
 }
 
+// TargetChange is a target file that sync would create or modify.
+type TargetChange struct {
+	Before  []byte // current content; nil if the file does not exist
+	After   []byte
+	Path    string // relative to the project root
+	Source  string
+	Existed bool
+}
+
+// PreviewResult holds the target changes a sync would make.
+type PreviewResult struct {
+	Changes []TargetChange
+	Errors  []SourceError
+}
+
 // CheckResult holds the outcome of a check operation.
 type CheckResult struct {
 	Drifted []DriftEntry
@@ -54,6 +104,11 @@ type VerifyResult struct {
 	UpToDate []string
 	Changed  []SourceDelta
 	Errors   []SourceError
+
+	// Pinned lists every pinned source, with After empty if its upstream
+	// has not moved on. They are frozen on purpose, so they are neither
+	// counted as changes nor reported up to date.
+	Pinned []SourceDelta
 }
 
 // PruneResult holds the outcome of a prune operation.
@@ -2,7 +2,10 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/bianoble/agent-sync/internal/cache"
 	"github.com/bianoble/agent-sync/internal/config"
@@ -14,6 +17,7 @@ import (
 type UpdateEngine struct {
 	Registry    *source.Registry
 	Cache       *cache.Cache
+	Now         func() time.Time // clock for the cooldown; nil means time.Now
 	ProjectRoot string
 }
 
@@ -22,6 +26,10 @@ type UpdateOptions struct {
 	SourceNames []string // empty = update all
 	DryRun      bool
 	AutoConfirm bool
+
+	// IgnoreCooldown locks the latest commit even when it is younger than
+	// the source's min_age. Each bypass is reported in the result.
+	IgnoreCooldown bool
 }
 
 // SourceUpdate records what changed for a single source.
@@ -33,9 +41,76 @@ type SourceUpdate struct {
 
 // UpdateResult holds the outcome of an update operation.
 type UpdateResult struct {
-	Lockfile *lock.Lockfile // nil if dry-run
-	Updated  []SourceUpdate
-	Failed   []SourceError
+	Lockfile  *lock.Lockfile // nil if dry-run
+	Updated   []SourceUpdate
+	Failed    []SourceError
+	Pinned    []PinnedSource // skipped because they are pinned
+	Cooldowns []CooldownHold // sources whose latest commit was younger than min_age
+}
+
+// CooldownHold records a source whose latest upstream commit was younger
+// than its min_age, and what update did about it.
+type CooldownHold struct {
+	Name     string
+	Commit   string // the commit that was too young
+	Fallback string // older release tag locked instead, if any
+	Age      time.Duration
+	MinAge   time.Duration
+	Kept     bool // the locked state was kept: the newest old-enough tag is not newer
+	Ignored  bool // locked anyway because of IgnoreCooldown
+}
+
+// PinnedSource is a source an update skipped because it is pinned.
+type PinnedSource struct {
+	Name   string
+	Reason string
+}
+
+// Changed reports whether the update changes the locked state of the source.
+func (u SourceUpdate) Changed() bool {
+	return u.Before == nil || !reflect.DeepEqual(*u.Before, *u.After)
+}
+
+// Reject drops the update for the named source: the new lockfile keeps the
+// source's previous state, or leaves it out if it was not locked before.
+func (r *UpdateResult) Reject(name string) {
+	var before *lock.LockedSource
+	found := false
+	for i, u := range r.Updated {
+		if u.Name == name {
+			before, found = u.Before, true
+			r.Updated = append(r.Updated[:i], r.Updated[i+1:]...)
+			break
+		}
+	}
+	if !found || r.Lockfile == nil {
+		return
+	}
+	for i, ls := range r.Lockfile.Sources {
+		if ls.Name != name {
+			continue
+		}
+		if before != nil {
+			r.Lockfile.Sources[i] = *before
+		} else {
+			r.Lockfile.Sources = append(r.Lockfile.Sources[:i], r.Lockfile.Sources[i+1:]...)
+		}
+		return
+	}
+}
+
+// Contents returns the content of every file of a locked source that can be
+// obtained, from the cache or else fetched from the source (which caches
+// it). Files that cannot be obtained, such as those of an earlier state of
+// a local source, are omitted.
+func (e *UpdateEngine) Contents(ctx context.Context, cfg config.Config, ls *lock.LockedSource) map[string][]byte {
+	fetcher := &SyncEngine{Registry: e.Registry, Cache: e.Cache, ProjectRoot: e.ProjectRoot}
+	files, err := fetcher.fetchSourceFiles(ctx, *ls, cfg)
+	if err != nil {
+		files = make(map[string][]byte)
+		fetcher.fillFromLocal(*ls, files)
+	}
+	return files
 }
 
 // Update resolves sources and updates the lockfile.
@@ -75,6 +150,11 @@ func (e *UpdateEngine) Update(ctx context.Context, cfg config.Config, currentLoc
 	// Resolve each source.
 	newByName := make(map[string]lock.LockedSource)
 	for _, src := range sourcesToUpdate {
+		if prev, ok := currentByName[src.Name]; ok && prev.Pinned() && len(opts.SourceNames) == 0 {
+			result.Pinned = append(result.Pinned, PinnedSource{Name: src.Name, Reason: prev.PinReason})
+			continue
+		}
+
 		resolver, err := e.Registry.Get(src.Type)
 		if err != nil {
 			result.Failed = append(result.Failed, SourceError{Source: src.Name, Err: err})
@@ -87,14 +167,36 @@ func (e *UpdateEngine) Update(ctx context.Context, cfg config.Config, currentLoc
 			continue
 		}
 
-		// Convert to lockfile entry.
-		ls := resolvedToLocked(src, resolved)
-
-		// Record update.
 		var before *lock.LockedSource
 		if prev, ok := currentByName[src.Name]; ok {
 			before = &prev
 		}
+
+		// Apply the cooldown policy, which may substitute an older release
+		// or keep the locked state.
+		var cooldown *lock.Cooldown
+		if minAge := cfg.MinAge(src); minAge > 0 {
+			resolved, cooldown, err = e.applyCooldown(ctx, resolver, src, resolved, before, minAge, opts.IgnoreCooldown, result)
+			if err != nil {
+				result.Failed = append(result.Failed, SourceError{Source: src.Name, Err: err})
+				continue
+			}
+			if resolved == nil {
+				result.Updated = append(result.Updated, SourceUpdate{Name: src.Name, Before: before, After: before})
+				newByName[src.Name] = *before
+				continue
+			}
+		}
+
+		// Convert to lockfile entry.
+		ls := resolvedToLocked(src, resolved)
+		ls.Cooldown = cooldown
+
+		// Record update. A pinned source updated by name stays pinned.
+		if before != nil && before.Pinned() {
+			ls.Status = lock.StatusPinned
+			ls.PinReason = before.PinReason
+		}
 		result.Updated = append(result.Updated, SourceUpdate{
 			Name:   src.Name,
 			Before: before,
@@ -105,7 +207,7 @@ func (e *UpdateEngine) Update(ctx context.Context, cfg config.Config, currentLoc
 
 		// Cache fetched content.
 		if e.Cache != nil {
-			fetched, fetchErr := resolver.Fetch(ctx, resolved)
+			fetched, fetchErr := fetchResolved(ctx, resolver, resolved, e.ProjectRoot)
 			if fetchErr == nil {
 				for _, f := range fetched {
 					_ = e.Cache.Put(f.SHA256, f.Content)
@@ -139,18 +241,110 @@ func (e *UpdateEngine) Update(ctx context.Context, cfg config.Config, currentLoc
 	return result, nil
 }
 
+// applyCooldown checks a resolved git source against its min_age. It
+// returns the state to lock and its cooldown record: resolved itself if its
+// commit is old enough (or IgnoreCooldown is set), else the newest release
+// tag that is old enough. It returns a nil state when that tag is not newer
+// than prev, meaning prev should be kept, and an error when there is no
+// old-enough tag at all.
+func (e *UpdateEngine) applyCooldown(ctx context.Context, resolver source.Resolver, src config.Source, resolved *source.ResolvedSource, prev *lock.LockedSource, minAge time.Duration, ignore bool, result *UpdateResult) (*source.ResolvedSource, *lock.Cooldown, error) {
+	now := time.Now()
+	if e.Now != nil {
+		now = e.Now()
+	}
+	record := func(r *source.ResolvedSource) *lock.Cooldown {
+		return &lock.Cooldown{
+			MinAge:      config.FormatAge(minAge),
+			CommittedAt: r.CommittedAt.UTC().Format(time.RFC3339),
+			Age:         config.FormatAge(now.Sub(r.CommittedAt)),
+		}
+	}
+
+	// Nothing new upstream: keep the record made when it was locked.
+	if prev != nil && prev.Resolved.Commit == resolved.Commit && prev.Cooldown != nil {
+		return resolved, prev.Cooldown, nil
+	}
+
+	if resolved.CommittedAt.IsZero() {
+		return nil, nil, fmt.Errorf("cannot check min_age: commit date of %s is unknown", resolved.Commit)
+	}
+	age := now.Sub(resolved.CommittedAt)
+	if age >= minAge || (prev != nil && prev.Resolved.Commit == resolved.Commit) {
+		return resolved, record(resolved), nil
+	}
+
+	hold := CooldownHold{Name: src.Name, Commit: resolved.Commit, Age: age, MinAge: minAge}
+	if ignore {
+		hold.Ignored = true
+		result.Cooldowns = append(result.Cooldowns, hold)
+		rec := record(resolved)
+		rec.Ignored = true
+		return resolved, rec, nil
+	}
+
+	tooYoung := fmt.Errorf("latest commit %s is %s old, younger than min_age %s", shortCommit(resolved.Commit), config.FormatAge(age), config.FormatAge(minAge))
+	rr, ok := resolver.(source.ReleaseResolver)
+	if !ok {
+		return nil, nil, tooYoung
+	}
+	tag, older, err := rr.ResolveRelease(ctx, src, e.ProjectRoot, now.Add(-minAge))
+	if errors.Is(err, source.ErrNoRelease) {
+		return nil, nil, fmt.Errorf("%w, and no older release tag is available — retry later or use --ignore-cooldown", tooYoung)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Never move backwards from what is locked. Without a recorded commit
+	// date for the locked state, it cannot be compared, so keep it.
+	if prev != nil && prev.Resolved.Commit != older.Commit {
+		var prevTime time.Time
+		if prev.Cooldown != nil {
+			prevTime, _ = time.Parse(time.RFC3339, prev.Cooldown.CommittedAt)
+		}
+		if prevTime.IsZero() || !older.CommittedAt.After(prevTime) {
+			hold.Kept = true
+			result.Cooldowns = append(result.Cooldowns, hold)
+			return nil, nil, nil
+		}
+	}
+
+	hold.Fallback = tag
+	result.Cooldowns = append(result.Cooldowns, hold)
+	rec := record(older)
+	rec.Tag = tag
+	return older, rec, nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
+
+// resolvedToLocked builds the lockfile entry for a resolved source. Repo,
+// url, and path are recorded as written in the config, before
+// interpolation, so that resolved secrets never reach the lockfile.
 func resolvedToLocked(src config.Source, resolved *source.ResolvedSource) lock.LockedSource {
+	recorded := src.Recorded()
 	ls := lock.LockedSource{
 		Name:   src.Name,
 		Type:   src.Type,
-		Repo:   src.Repo,
-		Status: "ok",
+		Repo:   recorded.Repo,
+		Status: lock.StatusOK,
 	}
 
 	ls.Resolved.Commit = resolved.Commit
 	ls.Resolved.Tree = resolved.Tree
 	ls.Resolved.URL = resolved.URL
+	if resolved.URL == src.URL {
+		ls.Resolved.URL = recorded.URL
+	}
 	ls.Resolved.Path = resolved.Path
+	if resolved.Path == src.Path {
+		ls.Resolved.Path = recorded.Path
+	}
 	ls.Resolved.SHA256 = resolvedSHA256(resolved)
 
 	if len(resolved.Files) > 0 {
@@ -3,7 +3,11 @@ package engine
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bianoble/agent-sync/internal/cache"
 	"github.com/bianoble/agent-sync/internal/config"
@@ -223,3 +227,212 @@ func TestResolvedSHA256Empty(t *testing.T) {
 		t.Errorf("resolvedSHA256 with no files = %q, want empty", got)
 	}
 }
+
+func TestUpdateResultReject(t *testing.T) {
+	oldA := lock.LockedSource{Name: "a", Type: "git", Resolved: lock.ResolvedState{Commit: "111"}}
+	newA := lock.LockedSource{Name: "a", Type: "git", Resolved: lock.ResolvedState{Commit: "222"}}
+	newB := lock.LockedSource{Name: "b", Type: "git", Resolved: lock.ResolvedState{Commit: "333"}}
+
+	result := &UpdateResult{
+		Lockfile: &lock.Lockfile{Version: 1, Sources: []lock.LockedSource{newA, newB}},
+		Updated: []SourceUpdate{
+			{Name: "a", Before: &oldA, After: &newA},
+			{Name: "b", After: &newB},
+		},
+	}
+	if !result.Updated[0].Changed() || !result.Updated[1].Changed() {
+		t.Fatal("updates should report Changed")
+	}
+	if (SourceUpdate{Name: "a", Before: &oldA, After: &oldA}).Changed() {
+		t.Error("identical states should not report Changed")
+	}
+
+	result.Reject("a")
+	result.Reject("b")
+	result.Reject("missing")
+
+	if len(result.Updated) != 0 {
+		t.Errorf("updated = %d, want 0", len(result.Updated))
+	}
+	if len(result.Lockfile.Sources) != 1 || result.Lockfile.Sources[0].Resolved.Commit != "111" {
+		t.Errorf("sources = %+v, want only a at its previous commit", result.Lockfile.Sources)
+	}
+}
+
+func TestUpdateEngineSkipsPinned(t *testing.T) {
+	contentHash := cache.ComputeHash([]byte("new"))
+	reg := newTestRegistry(map[string]*mockResolver{
+		"local": {
+			resolved: &source.ResolvedSource{
+				Type:  "local",
+				Path:  "./a/",
+				Files: map[string]string{"a.md": contentHash},
+			},
+		},
+	})
+	eng := &UpdateEngine{Registry: reg, ProjectRoot: t.TempDir()}
+
+	cfg := config.Config{
+		Version: 1,
+		Sources: []config.Source{
+			{Name: "frozen", Type: "local", Path: "./a/"},
+			{Name: "free", Type: "local", Path: "./a/"},
+		},
+	}
+	existing := &lock.Lockfile{
+		Version: 1,
+		Sources: []lock.LockedSource{
+			{Name: "frozen", Type: "local", Resolved: lock.ResolvedState{Path: "./a/"}, Status: lock.StatusPinned, PinReason: "v2 is broken"},
+			{Name: "free", Type: "local", Resolved: lock.ResolvedState{Path: "./a/"}, Status: lock.StatusOK},
+		},
+	}
+
+	result, err := eng.Update(context.Background(), cfg, existing, UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(result.Pinned) != 1 || result.Pinned[0].Name != "frozen" || result.Pinned[0].Reason != "v2 is broken" {
+		t.Errorf("pinned = %+v", result.Pinned)
+	}
+	if len(result.Updated) != 1 || result.Updated[0].Name != "free" {
+		t.Errorf("updated = %+v", result.Updated)
+	}
+	if got := result.Lockfile.Source("frozen"); got == nil || len(got.Resolved.Files) != 0 {
+		t.Errorf("pinned source changed: %+v", got)
+	}
+
+	// Named explicitly, a pinned source is updated and stays pinned.
+	result, err = eng.Update(context.Background(), cfg, existing, UpdateOptions{SourceNames: []string{"frozen"}})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got := result.Lockfile.Source("frozen")
+	if got == nil || len(got.Resolved.Files) != 1 || !got.Pinned() || got.PinReason != "v2 is broken" {
+		t.Errorf("explicitly updated pinned source = %+v", got)
+	}
+}
+
+// releaseResolver is a mockResolver that also offers an older release.
+type releaseResolver struct {
+	mockResolver
+	release *source.ResolvedSource
+}
+
+func (r *releaseResolver) ResolveRelease(ctx context.Context, src config.Source, projectRoot string, cutoff time.Time) (string, *source.ResolvedSource, error) {
+	if r.release == nil || r.release.CommittedAt.After(cutoff) {
+		return "", nil, fmt.Errorf("%w", source.ErrNoRelease)
+	}
+	return "v1", r.release, nil
+}
+
+func TestUpdateEngineCooldown(t *testing.T) {
+	now := time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC)
+	latest := &source.ResolvedSource{Type: "git", Commit: "new", CommittedAt: now.Add(-time.Hour)}
+	release := &source.ResolvedSource{Type: "git", Commit: "old", CommittedAt: now.Add(-100 * time.Hour)}
+
+	cfg := config.Config{
+		Version: 1,
+		Update:  config.UpdateSettings{MinAge: "3d"},
+		Sources: []config.Source{{Name: "rules", Type: "git", Repo: "r", Ref: "main"}},
+	}
+	update := func(resolver source.Resolver, current *lock.Lockfile, opts UpdateOptions) *UpdateResult {
+		t.Helper()
+		reg := source.NewRegistry()
+		reg.Register("git", resolver)
+		eng := &UpdateEngine{Registry: reg, ProjectRoot: t.TempDir(), Now: func() time.Time { return now }}
+		result, err := eng.Update(context.Background(), cfg, current, opts)
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		return result
+	}
+
+	// Too young: falls back to the release and records it.
+	result := update(&releaseResolver{mockResolver: mockResolver{resolved: latest}, release: release}, nil, UpdateOptions{})
+	ls := result.Lockfile.Source("rules")
+	if ls == nil || ls.Resolved.Commit != "old" || ls.Cooldown == nil || ls.Cooldown.Tag != "v1" || ls.Cooldown.Age != "100h" || ls.Cooldown.MinAge != "72h" {
+		t.Fatalf("locked = %+v (cooldown %+v)", ls, ls.Cooldown)
+	}
+	if len(result.Cooldowns) != 1 || result.Cooldowns[0].Fallback != "v1" {
+		t.Errorf("cooldowns = %+v", result.Cooldowns)
+	}
+
+	// No release old enough: refused.
+	result = update(&releaseResolver{mockResolver: mockResolver{resolved: latest}}, nil, UpdateOptions{})
+	if len(result.Failed) != 1 || !strings.Contains(result.Failed[0].Err.Error(), "younger than min_age") {
+		t.Errorf("failed = %+v", result.Failed)
+	}
+
+	// Locked state newer than the release: kept.
+	current := &lock.Lockfile{Version: 1, Sources: []lock.LockedSource{{
+		Name: "rules", Type: "git", Status: lock.StatusOK,
+		Resolved: lock.ResolvedState{Commit: "mid"},
+		Cooldown: &lock.Cooldown{CommittedAt: now.Add(-80 * time.Hour).Format(time.RFC3339)},
+	}}}
+	result = update(&releaseResolver{mockResolver: mockResolver{resolved: latest}, release: release}, current, UpdateOptions{})
+	if got := result.Lockfile.Source("rules"); got.Resolved.Commit != "mid" || !result.Cooldowns[0].Kept {
+		t.Errorf("locked = %+v, cooldowns = %+v", got, result.Cooldowns)
+	}
+
+	// --ignore-cooldown locks the young commit and says so.
+	result = update(&releaseResolver{mockResolver: mockResolver{resolved: latest}}, nil, UpdateOptions{IgnoreCooldown: true})
+	if got := result.Lockfile.Source("rules"); got.Resolved.Commit != "new" || !got.Cooldown.Ignored || !result.Cooldowns[0].Ignored {
+		t.Errorf("locked = %+v, cooldowns = %+v", got, result.Cooldowns)
+	}
+}
+
+func TestResolvedToLockedRecordsWrittenForm(t *testing.T) {
+	t.Setenv("AS_TEST_TOKEN", "s3cret")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent-sync.yaml")
+	data := `version: 1
+sources:
+  - name: style
+    type: url
+    url: https://${AS_TEST_TOKEN}@example.com/style.md
+    checksum: sha256:abc
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	src := cfg.Sources[0]
+
+	ls := resolvedToLocked(src, &source.ResolvedSource{Name: "style", Type: "url", URL: src.URL})
+	if strings.Contains(ls.Resolved.URL, "s3cret") || ls.Resolved.URL != "https://${AS_TEST_TOKEN}@example.com/style.md" {
+		t.Errorf("locked url = %s, want the written form", ls.Resolved.URL)
+	}
+
+	_, url, _, err := expandLocked(cfg, ls)
+	if err != nil || url != src.URL {
+		t.Errorf("expandLocked url = %s, %v; want %s", url, err, src.URL)
+	}
+}
+
+func TestExpandLockedRefusesForeignReferences(t *testing.T) {
+	t.Setenv("AS_TEST_TOKEN", "s3cret")
+	cfg := &config.Config{Sources: []config.Source{
+		{Name: "style", Type: "url", URL: "https://example.com/style.md"},
+	}}
+
+	for _, ls := range []lock.LockedSource{
+		{Name: "style", Type: "url", Resolved: lock.ResolvedState{URL: "https://evil.example/${AS_TEST_TOKEN}"}},
+		{Name: "gone", Type: "git", Repo: "https://evil.example/${AS_TEST_TOKEN}"},
+	} {
+		repo, url, _, err := expandLocked(cfg, ls)
+		if err == nil || !strings.Contains(err.Error(), "do not match the config") {
+			t.Errorf("%s: err = %v, want refusal", ls.Name, err)
+		}
+		if strings.Contains(repo+url, "s3cret") {
+			t.Errorf("%s: expanded the environment: %s %s", ls.Name, repo, url)
+		}
+	}
+
+	ls := lock.LockedSource{Name: "style", Type: "url", Resolved: lock.ResolvedState{URL: "https://example.com/style.md"}}
+	if _, url, _, err := expandLocked(cfg, ls); err != nil || url != ls.Resolved.URL {
+		t.Errorf("literal url = %s, %v", url, err)
+	}
+}
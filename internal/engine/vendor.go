@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/bianoble/agent-sync/internal/cache"
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/source"
+	"github.com/bianoble/agent-sync/internal/vendored"
+)
+
+// VendorEngine copies every object referenced by the lockfile into the
+// project's vendor store so that sync needs neither git nor network.
+type VendorEngine struct {
+	Registry    *source.Registry
+	Cache       *cache.Cache
+	ProjectRoot string
+}
+
+// VendorOptions configures a vendor operation.
+type VendorOptions struct {
+	// Config resolves ${...} references in lockfile entries. May be nil.
+	Config *config.Config
+	DryRun bool
+}
+
+// VendorResult holds the outcome of a vendor operation.
+type VendorResult struct {
+	Added     []string // "source/path" of newly vendored objects
+	Removed   []string // hashes of objects no longer referenced by the lockfile
+	Errors    []SourceError
+	Unchanged int
+}
+
+// Vendor writes all locked content into the vendor store, removes objects the
+// lockfile no longer references, and rewrites the vendor index.
+func (e *VendorEngine) Vendor(ctx context.Context, lf lock.Lockfile, opts VendorOptions) (*VendorResult, error) {
+	result := &VendorResult{}
+
+	var store *cache.Cache
+	var err error
+	if opts.DryRun {
+		store, err = vendored.Open(e.ProjectRoot)
+	} else {
+		store, err = vendored.Create(e.ProjectRoot)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher := &SyncEngine{Registry: e.Registry, Cache: e.Cache, Vendor: store, ProjectRoot: e.ProjectRoot}
+	idx := &vendored.Index{Version: 1}
+	keep := make(map[string]bool)
+
+	for _, ls := range lf.Sources {
+		entry := vendored.IndexSource{Name: ls.Name, Commit: ls.Resolved.Commit, Files: make(map[string]string)}
+		for relPath, fh := range ls.Resolved.Files {
+			keep[fh.SHA256] = true
+			entry.Files[relPath] = fh.SHA256
+		}
+		idx.Sources = append(idx.Sources, entry)
+
+		var missing []string
+		for relPath, fh := range ls.Resolved.Files {
+			if store != nil && store.Has(fh.SHA256) {
+				result.Unchanged++
+				continue
+			}
+			missing = append(missing, relPath)
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		sort.Strings(missing)
+
+		if opts.DryRun {
+			for _, relPath := range missing {
+				result.Added = append(result.Added, ls.Name+"/"+relPath)
+			}
+			continue
+		}
+
+		var cfg config.Config
+		if opts.Config != nil {
+			cfg = *opts.Config
+		}
+		files, fetchErr := fetcher.fetchSourceFiles(ctx, ls, cfg)
+		if fetchErr != nil {
+			result.Errors = append(result.Errors, SourceError{Source: ls.Name, Err: fetchErr})
+			continue
+		}
+
+		for _, relPath := range missing {
+			content, ok := files[relPath]
+			if !ok {
+				result.Errors = append(result.Errors, SourceError{Source: ls.Name, Err: fmt.Errorf("no content for %s", relPath)})
+				continue
+			}
+			if putErr := store.Put(ls.Resolved.Files[relPath].SHA256, content); putErr != nil {
+				result.Errors = append(result.Errors, SourceError{Source: ls.Name, Err: fmt.Errorf("vendoring %s: %w", relPath, putErr)})
+				continue
+			}
+			result.Added = append(result.Added, ls.Name+"/"+relPath)
+		}
+	}
+
+	if store == nil {
+		return result, nil
+	}
+
+	objects, err := store.Objects()
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objects {
+		if keep[obj.Hash] {
+			continue
+		}
+		if !opts.DryRun {
+			if rmErr := store.Remove(obj.Hash); rmErr != nil {
+				return nil, rmErr
+			}
+		}
+		result.Removed = append(result.Removed, obj.Hash)
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	sort.Slice(idx.Sources, func(i, j int) bool { return idx.Sources[i].Name < idx.Sources[j].Name })
+	if err := vendored.SaveIndex(e.ProjectRoot, idx); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
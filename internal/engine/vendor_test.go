@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bianoble/agent-sync/internal/cache"
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/source"
+	"github.com/bianoble/agent-sync/internal/target"
+	"github.com/bianoble/agent-sync/internal/vendored"
+)
+
+func vendorTestLockfile(hash string) lock.Lockfile {
+	return lock.Lockfile{
+		Version: 1,
+		Sources: []lock.LockedSource{{
+			Name: "rules", Type: "git", Repo: "https://example.com/r.git", Status: "ok",
+			Resolved: lock.ResolvedState{
+				Commit: "abc123",
+				Files:  map[string]lock.FileHash{"rules.md": {SHA256: hash}},
+			},
+		}},
+	}
+}
+
+func TestVendorEngineWritesObjectsAndIndex(t *testing.T) {
+	projectRoot := t.TempDir()
+	c, _ := cache.New(t.TempDir())
+
+	content := []byte("# Rules\n")
+	hash := cache.ComputeHash(content)
+	if err := c.Put(hash, content); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stale object from an earlier lockfile should be removed.
+	store, err := vendored.Create(projectRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := []byte("stale")
+	staleHash := cache.ComputeHash(stale)
+	if putErr := store.Put(staleHash, stale); putErr != nil {
+		t.Fatal(putErr)
+	}
+
+	reg := source.NewRegistry()
+	reg.Register("git", source.OfflineResolver{})
+
+	eng := &VendorEngine{Registry: reg, Cache: c, ProjectRoot: projectRoot}
+	result, err := eng.Vendor(context.Background(), vendorTestLockfile(hash), VendorOptions{})
+	if err != nil {
+		t.Fatalf("Vendor: %v", err)
+	}
+
+	if len(result.Added) != 1 || result.Added[0] != "rules/rules.md" {
+		t.Errorf("added = %v, want [rules/rules.md]", result.Added)
+	}
+	if len(result.Removed) != 1 || result.Removed[0] != staleHash {
+		t.Errorf("removed = %v, want [%s]", result.Removed, staleHash)
+	}
+	if !store.Has(hash) {
+		t.Error("locked object should be in the vendor store")
+	}
+	if store.Has(staleHash) {
+		t.Error("stale object should be removed")
+	}
+
+	idx, err := vendored.LoadIndex(projectRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Sources) != 1 || idx.Sources[0].Files["rules.md"] != hash {
+		t.Errorf("index = %+v", idx)
+	}
+
+	// Second run is a no-op.
+	result, err = eng.Vendor(context.Background(), vendorTestLockfile(hash), VendorOptions{})
+	if err != nil {
+		t.Fatalf("second Vendor: %v", err)
+	}
+	if len(result.Added) != 0 || result.Unchanged != 1 {
+		t.Errorf("second run: added=%v unchanged=%d", result.Added, result.Unchanged)
+	}
+}
+
+func TestVendorEngineDryRunCreatesNothing(t *testing.T) {
+	projectRoot := t.TempDir()
+	c, _ := cache.New(t.TempDir())
+
+	content := []byte("x")
+	hash := cache.ComputeHash(content)
+	if err := c.Put(hash, content); err != nil {
+		t.Fatal(err)
+	}
+
+	eng := &VendorEngine{Registry: source.NewRegistry(), Cache: c, ProjectRoot: projectRoot}
+	result, err := eng.Vendor(context.Background(), vendorTestLockfile(hash), VendorOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Vendor: %v", err)
+	}
+	if len(result.Added) != 1 {
+		t.Errorf("added = %v, want 1 entry", result.Added)
+	}
+	if _, statErr := os.Stat(filepath.Join(projectRoot, vendored.Dir)); !os.IsNotExist(statErr) {
+		t.Error("dry run should not create the vendor directory")
+	}
+}
+
+func TestSyncEnginePrefersVendor(t *testing.T) {
+	projectRoot := t.TempDir()
+
+	content := []byte("# Vendored\n")
+	hash := cache.ComputeHash(content)
+	store, err := vendored.Create(projectRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if putErr := store.Put(hash, content); putErr != nil {
+		t.Fatal(putErr)
+	}
+
+	// Empty cache and an offline registry: only the vendor store can serve content.
+	c, _ := cache.New(t.TempDir())
+	reg := source.NewRegistry()
+	reg.Register("git", source.OfflineResolver{})
+
+	eng := &SyncEngine{
+		Registry:    reg,
+		Cache:       c,
+		Vendor:      store,
+		ToolMap:     target.NewToolMap(nil),
+		ProjectRoot: projectRoot,
+	}
+	cfg := config.Config{
+		Version: 1,
+		Sources: []config.Source{{Name: "rules", Type: "git", Repo: "https://example.com/r.git", Ref: "main"}},
+		Targets: []config.Target{{Source: "rules", Destination: ".out/"}},
+	}
+
+	result, err := eng.Sync(context.Background(), vendorTestLockfile(hash), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Fatalf("errors = %v", result.Errors)
+	}
+
+	got, err := os.ReadFile(filepath.Join(projectRoot, ".out", "rules.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("content = %q, want %q", got, content)
+	}
+}
@@ -70,13 +70,20 @@ func (e *VerifyEngine) Verify(ctx context.Context, lf lock.Lockfile, cfg config.
 			continue
 		}
 
-		if hasChanged(ls, resolved) {
+		switch {
+		case ls.Pinned():
+			d := SourceDelta{Source: name, Before: summarizeLocked(ls), PinReason: ls.PinReason}
+			if hasChanged(ls, resolved) {
+				d.After = summarizeResolved(resolved)
+			}
+			result.Pinned = append(result.Pinned, d)
+		case hasChanged(ls, resolved):
 			result.Changed = append(result.Changed, SourceDelta{
 				Source: name,
 				Before: summarizeLocked(ls),
 				After:  summarizeResolved(resolved),
 			})
-		} else {
+		default:
 			result.UpToDate = append(result.UpToDate, name)
 		}
 	}
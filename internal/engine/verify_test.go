@@ -494,3 +494,81 @@ func TestSummarizeResolved(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyEnginePinnedNotCountedAsChanged(t *testing.T) {
+	reg := newTestRegistry(map[string]*mockResolver{
+		"local": {
+			resolved: &source.ResolvedSource{
+				Type:  "local",
+				Path:  "./src/",
+				Files: map[string]string{"file.md": "new"},
+			},
+		},
+	})
+	eng := &VerifyEngine{Registry: reg, ProjectRoot: t.TempDir()}
+
+	cfg := config.Config{
+		Version: 1,
+		Sources: []config.Source{{Name: "src", Type: "local", Path: "./src/"}},
+	}
+	lf := lock.Lockfile{
+		Version: 1,
+		Sources: []lock.LockedSource{{
+			Name: "src", Type: "local", Status: lock.StatusPinned, PinReason: "breaks agents",
+			Resolved: lock.ResolvedState{
+				Path:  "./src/",
+				Files: map[string]lock.FileHash{"file.md": {SHA256: "old"}},
+			},
+		}},
+	}
+
+	result, err := eng.Verify(context.Background(), lf, cfg, nil)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(result.Changed) != 0 {
+		t.Errorf("changed = %d, want 0", len(result.Changed))
+	}
+	if len(result.Pinned) != 1 || result.Pinned[0].PinReason != "breaks agents" || result.Pinned[0].After == "" {
+		t.Errorf("pinned = %+v", result.Pinned)
+	}
+}
+
+func TestVerifyEngineReportsUnchangedPinned(t *testing.T) {
+	reg := newTestRegistry(map[string]*mockResolver{
+		"local": {
+			resolved: &source.ResolvedSource{
+				Type:  "local",
+				Path:  "./src/",
+				Files: map[string]string{"file.md": "same"},
+			},
+		},
+	})
+	eng := &VerifyEngine{Registry: reg, ProjectRoot: t.TempDir()}
+
+	cfg := config.Config{
+		Version: 1,
+		Sources: []config.Source{{Name: "src", Type: "local", Path: "./src/"}},
+	}
+	lf := lock.Lockfile{
+		Version: 1,
+		Sources: []lock.LockedSource{{
+			Name: "src", Type: "local", Status: lock.StatusPinned, PinReason: "breaks agents",
+			Resolved: lock.ResolvedState{
+				Path:  "./src/",
+				Files: map[string]lock.FileHash{"file.md": {SHA256: "same"}},
+			},
+		}},
+	}
+
+	result, err := eng.Verify(context.Background(), lf, cfg, nil)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(result.UpToDate) != 0 {
+		t.Errorf("up to date = %v, want the pinned source reported as pinned", result.UpToDate)
+	}
+	if len(result.Pinned) != 1 || result.Pinned[0].Source != "src" || result.Pinned[0].After != "" {
+		t.Errorf("pinned = %+v, want src with upstream unchanged", result.Pinned)
+	}
+}
@@ -0,0 +1,91 @@
+// Package flock provides advisory, whole-file locks that are released
+// automatically when the holding process exits.
+package flock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when the lock is held by another process.
+var ErrLocked = errors.New("lock is held by another process")
+
+// pollInterval is how often Acquire retries while waiting.
+const pollInterval = 100 * time.Millisecond
+
+// Lock is a held advisory lock.
+type Lock struct {
+	f *os.File
+}
+
+// TryLock takes an exclusive lock on path without blocking, creating the
+// file and its parent directory if needed. If another process holds the
+// lock, the returned error wraps ErrLocked.
+//
+// holder is written into the lock file so that waiting processes can
+// report who holds it.
+func TryLock(path, holder string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file %s: %w", path, err)
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	// Holder information is diagnostic only; failing to record it is harmless.
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(holder+"\n"), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Acquire takes an exclusive lock on path, retrying for up to wait while
+// another process holds it. With wait <= 0 it fails immediately.
+func Acquire(ctx context.Context, path, holder string, wait time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		l, err := TryLock(path, holder)
+		if !errors.Is(err, ErrLocked) || !time.Now().Before(deadline) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Holder returns the holder recorded in the lock file at path, or "" if
+// none is recorded or it cannot be read.
+func Holder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Unlock releases the lock. The lock file itself is left in place.
+func (l *Lock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	unlockErr := unlockFile(l.f)
+	closeErr := l.f.Close()
+	l.f = nil
+	if unlockErr != nil {
+		return fmt.Errorf("releasing lock: %w", unlockErr)
+	}
+	return closeErr
+}
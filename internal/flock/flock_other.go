@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package flock
+
+import "os"
+
+// Platforms without file locking proceed unlocked.
+
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
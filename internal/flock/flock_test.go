@@ -0,0 +1,82 @@
+package flock
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTryLockExcludesSecondHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "lock")
+
+	first, err := TryLock(path, "sync (pid 1)")
+	if err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+	if got := Holder(path); got != "sync (pid 1)" {
+		t.Errorf("Holder = %q", got)
+	}
+
+	if _, err := TryLock(path, "update (pid 2)"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second TryLock error = %v, want ErrLocked", err)
+	}
+
+	if err := first.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	second, err := TryLock(path, "update (pid 2)")
+	if err != nil {
+		t.Fatalf("TryLock after unlock: %v", err)
+	}
+	defer func() { _ = second.Unlock() }()
+	if got := Holder(path); got != "update (pid 2)" {
+		t.Errorf("Holder = %q", got)
+	}
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	held, err := TryLock(path, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		_ = held.Unlock()
+	}()
+
+	l, err := Acquire(context.Background(), path, "second", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	_ = l.Unlock()
+}
+
+func TestAcquireTimesOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	held, err := TryLock(path, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = held.Unlock() }()
+
+	if _, err := Acquire(context.Background(), path, "second", 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("no-wait Acquire error = %v, want ErrLocked", err)
+	}
+
+	start := time.Now()
+	if _, err := Acquire(context.Background(), path, "second", 250*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Errorf("Acquire error = %v, want ErrLocked", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Acquire gave up after %s, expected to wait", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Acquire(ctx, path, "second", time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled Acquire error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build windows
+
+package flock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+)
+
+// The locked range starts far beyond any holder text so that other
+// processes can still read who holds the lock.
+const lockOffsetHigh = 0x7fffffff
+
+func lockFile(f *os.File) error {
+	ol := &syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r != 0 {
+		return nil
+	}
+	if errors.Is(err, errorLockViolation) {
+		return fmt.Errorf("%s: %w", f.Name(), ErrLocked)
+	}
+	return fmt.Errorf("locking %s: %w", f.Name(), err)
+}
+
+func unlockFile(f *os.File) error {
+	ol := &syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+// Package journal makes multi-file writes to the project crash-safe.
+//
+// Before an operation touches any target file it records, under
+// .agent-sync/journal/, the planned changes together with the pre-image and
+// post-image of every file. The images live in a content-addressed store with
+// the same objects/xx/<sha256> layout as the user cache, so every read during
+// recovery is hash-verified. The journal is removed once the operation
+// finishes; a journal found on disk therefore marks an interrupted
+// operation, which can be rolled forward (finish it) or back (undo it).
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/cache"
+	"github.com/bianoble/agent-sync/internal/sandbox"
+	"gopkg.in/yaml.v3"
+)
+
+// Dir is the journal directory, relative to the project root.
+const Dir = ".agent-sync/journal"
+
+// journalFile is written last when preparing; its presence marks a journal
+// whose images are all durable.
+const journalFile = "journal.yaml"
+
+// ErrPending is returned by Begin when an interrupted operation has not
+// been recovered yet.
+var ErrPending = errors.New("an interrupted operation has not been recovered — run 'agent-sync recover'")
+
+// Change describes one planned file change.
+type Change struct {
+	Path    string // relative to the project root
+	Before  []byte // current content; ignored unless Existed
+	After   []byte // new content; ignored if Remove
+	Existed bool
+	Remove  bool
+}
+
+// Entry is the journaled form of a Change. Hashes refer to images in the
+// journal's object store; an empty hash means the file is absent.
+type Entry struct {
+	Path   string `yaml:"path"`
+	Before string `yaml:"before,omitempty"`
+	After  string `yaml:"after,omitempty"`
+}
+
+// Journal is a prepared record of an in-progress operation.
+type Journal struct {
+	Started   time.Time `yaml:"started"`
+	store     *cache.Cache
+	Operation string `yaml:"operation"`
+	root      string
+	Entries   []Entry `yaml:"entries"`
+	Version   int     `yaml:"version"`
+}
+
+// Result reports what recovery did.
+type Result struct {
+	Applied   []string // paths restored or completed
+	Conflicts []string // paths changed since the interruption, left untouched
+	Unchanged int      // paths already in the desired state
+}
+
+// Begin durably records changes before the caller applies them. Leftovers
+// of a journal that was never fully prepared are discarded first, since no
+// target file is touched before preparation completes.
+func Begin(projectRoot, operation string, changes []Change) (*Journal, error) {
+	existing, err := Open(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrPending
+	}
+
+	dir := filepath.Join(projectRoot, filepath.FromSlash(Dir))
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("clearing stale journal: %w", err)
+	}
+	if _, err := sandbox.ValidatePath(projectRoot, filepath.FromSlash(Dir)); err != nil {
+		return nil, err
+	}
+	store, err := cache.New(dir)
+	if err != nil {
+		return nil, fmt.Errorf("creating journal: %w", err)
+	}
+
+	j := &Journal{
+		Version:   1,
+		Operation: operation,
+		Started:   time.Now().UTC().Truncate(time.Second),
+		root:      projectRoot,
+		store:     store,
+	}
+	for _, ch := range changes {
+		entry := Entry{Path: filepath.ToSlash(ch.Path)}
+		if ch.Existed {
+			entry.Before = cache.ComputeHash(ch.Before)
+			if err := store.Put(entry.Before, ch.Before); err != nil {
+				return nil, fmt.Errorf("journaling %s: %w", ch.Path, err)
+			}
+		}
+		if !ch.Remove {
+			entry.After = cache.ComputeHash(ch.After)
+			if err := store.Put(entry.After, ch.After); err != nil {
+				return nil, fmt.Errorf("journaling %s: %w", ch.Path, err)
+			}
+		}
+		j.Entries = append(j.Entries, entry)
+	}
+
+	data, err := yaml.Marshal(j)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling journal: %w", err)
+	}
+	if err := writeDurable(dir, journalFile, data); err != nil {
+		return nil, fmt.Errorf("writing journal: %w", err)
+	}
+	return j, nil
+}
+
+// Open returns the prepared journal of an interrupted operation, or nil if
+// there is none.
+func Open(projectRoot string) (*Journal, error) {
+	dir := filepath.Join(projectRoot, filepath.FromSlash(Dir))
+	data, err := os.ReadFile(filepath.Join(dir, journalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading journal: %w", err)
+	}
+
+	var j Journal
+	if err := yaml.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("parsing journal %s: %w", filepath.Join(Dir, journalFile), err)
+	}
+	if j.Version != 1 {
+		return nil, fmt.Errorf("unsupported journal version %d — only version 1 is supported", j.Version)
+	}
+	store, err := cache.New(dir)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+	j.root = projectRoot
+	j.store = store
+	return &j, nil
+}
+
+// Complete removes the journal once the operation has finished. Completing
+// a nil journal is a no-op.
+func (j *Journal) Complete() error {
+	if j == nil {
+		return nil
+	}
+	if err := os.RemoveAll(filepath.Join(j.root, filepath.FromSlash(Dir))); err != nil {
+		return fmt.Errorf("removing journal: %w", err)
+	}
+	return nil
+}
+
+// RollForward finishes the interrupted operation by bringing every file to
+// its post-image, then removes the journal unless a file conflicts.
+func (j *Journal) RollForward() (*Result, error) {
+	return j.restore(func(e Entry) (want, other string) { return e.After, e.Before })
+}
+
+// RollBack undoes the interrupted operation by bringing every file back to
+// its pre-image, then removes the journal unless a file conflicts.
+func (j *Journal) RollBack() (*Result, error) {
+	return j.restore(func(e Entry) (want, other string) { return e.Before, e.After })
+}
+
+// restore brings each file to the image chosen by pick. A file that matches
+// neither image was changed after the interruption and is left alone; the
+// journal is then kept, so that recovery can run again once the file is
+// dealt with.
+func (j *Journal) restore(pick func(Entry) (want, other string)) (*Result, error) {
+	result := &Result{}
+	for _, e := range j.Entries {
+		want, other := pick(e)
+		relPath := filepath.FromSlash(e.Path)
+
+		current, err := currentHash(j.root, relPath)
+		if err != nil {
+			return result, err
+		}
+		switch current {
+		case want:
+			result.Unchanged++
+			continue
+		case other:
+		default:
+			result.Conflicts = append(result.Conflicts, e.Path)
+			continue
+		}
+
+		if want == "" {
+			if err := sandbox.SafeRemove(j.root, relPath); err != nil {
+				return result, fmt.Errorf("removing %s: %w", e.Path, err)
+			}
+		} else {
+			content, found, err := j.store.Get(want)
+			if err != nil {
+				return result, err
+			}
+			if !found {
+				return result, fmt.Errorf("journal image for %s (sha256:%s) is missing or corrupt", e.Path, want)
+			}
+			if err := sandbox.SafeWrite(j.root, relPath, content, 0644); err != nil {
+				return result, fmt.Errorf("restoring %s: %w", e.Path, err)
+			}
+		}
+		result.Applied = append(result.Applied, e.Path)
+	}
+
+	if len(result.Conflicts) > 0 {
+		return result, nil
+	}
+	return result, j.Complete()
+}
+
+// currentHash returns the hash of a project file, or "" if it does not exist.
+func currentHash(projectRoot, relPath string) (string, error) {
+	absPath, err := sandbox.ValidatePath(projectRoot, relPath)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(absPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", relPath, err)
+	}
+	return cache.ComputeHash(data), nil
+}
+
+// writeDurable atomically writes name in dir and fsyncs the directory, so
+// the file is either absent or complete after a crash.
+func writeDurable(dir, name string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, "."+name+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, name)); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
+}
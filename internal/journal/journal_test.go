@@ -0,0 +1,164 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, root, rel string) (string, bool) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, rel))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), true
+}
+
+// interruptedSync journals changes to two files, then applies only the
+// first, as if the process had been killed mid-write.
+func interruptedSync(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeFile(t, root, ".claude/a.md", "old a")
+
+	changes := []Change{
+		{Path: filepath.Join(".claude", "a.md"), Before: []byte("old a"), After: []byte("new a"), Existed: true},
+		{Path: filepath.Join(".claude", "b.md"), After: []byte("new b")},
+	}
+	if _, err := Begin(root, "sync", changes); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	writeFile(t, root, ".claude/a.md", "new a")
+	return root
+}
+
+func TestRollForwardCompletesInterruptedWrites(t *testing.T) {
+	root := interruptedSync(t)
+
+	j, err := Open(root)
+	if err != nil || j == nil {
+		t.Fatalf("Open = %v, %v; want pending journal", j, err)
+	}
+	if j.Operation != "sync" || len(j.Entries) != 2 {
+		t.Fatalf("journal = %+v", j)
+	}
+
+	res, err := j.RollForward()
+	if err != nil {
+		t.Fatalf("RollForward: %v", err)
+	}
+	if len(res.Applied) != 1 || res.Applied[0] != ".claude/b.md" || res.Unchanged != 1 {
+		t.Errorf("result = %+v", res)
+	}
+	if got, _ := readFile(t, root, ".claude/b.md"); got != "new b" {
+		t.Errorf("b.md = %q, want new b", got)
+	}
+
+	if j, _ := Open(root); j != nil {
+		t.Error("journal should be removed after recovery")
+	}
+}
+
+func TestRollBackRestoresPreImages(t *testing.T) {
+	root := interruptedSync(t)
+	j, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := j.RollBack()
+	if err != nil {
+		t.Fatalf("RollBack: %v", err)
+	}
+	if len(res.Applied) != 1 || res.Applied[0] != ".claude/a.md" {
+		t.Errorf("result = %+v", res)
+	}
+	if got, _ := readFile(t, root, ".claude/a.md"); got != "old a" {
+		t.Errorf("a.md = %q, want old a", got)
+	}
+	if _, exists := readFile(t, root, ".claude/b.md"); exists {
+		t.Error("b.md did not exist before the sync and should stay absent")
+	}
+}
+
+func TestRecoveryLeavesLaterEditsAlone(t *testing.T) {
+	root := interruptedSync(t)
+	writeFile(t, root, ".claude/b.md", "hand edit")
+
+	j, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := j.RollForward()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Conflicts) != 1 || res.Conflicts[0] != ".claude/b.md" {
+		t.Errorf("conflicts = %v", res.Conflicts)
+	}
+	if got, _ := readFile(t, root, ".claude/b.md"); got != "hand edit" {
+		t.Errorf("b.md = %q, hand edit must be preserved", got)
+	}
+	if j, err := Open(root); err != nil || j == nil {
+		t.Fatalf("Open = %v, %v; the journal must be kept while a file conflicts", j, err)
+	}
+
+	// Once the file is removed by hand, recovery completes it.
+	if err := os.Remove(filepath.Join(root, ".claude", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	j, _ = Open(root)
+	if res, err := j.RollForward(); err != nil || len(res.Conflicts) != 0 {
+		t.Fatalf("RollForward = %+v, %v; want no conflicts", res, err)
+	}
+	if j, err := Open(root); err != nil || j != nil {
+		t.Errorf("Open = %v, %v; the journal must be removed once recovered", j, err)
+	}
+}
+
+func TestBeginRefusesWhilePending(t *testing.T) {
+	root := interruptedSync(t)
+	if _, err := Begin(root, "sync", nil); !errors.Is(err, ErrPending) {
+		t.Errorf("Begin error = %v, want ErrPending", err)
+	}
+}
+
+func TestBeginDiscardsUnpreparedJournal(t *testing.T) {
+	root := t.TempDir()
+	// Images were being written when the process died; journal.yaml never was.
+	writeFile(t, root, filepath.Join(Dir, "objects", "ab", "stale"), "partial")
+
+	if j, err := Open(root); err != nil || j != nil {
+		t.Fatalf("Open = %v, %v; an unprepared journal is not pending", j, err)
+	}
+
+	j, err := Begin(root, "sync", []Change{{Path: "out.md", After: []byte("x")}})
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if _, exists := readFile(t, root, filepath.Join(Dir, "objects", "ab", "stale")); exists {
+		t.Error("stale images should be discarded")
+	}
+	if err := j.Complete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, Dir)); !os.IsNotExist(err) {
+		t.Error("Complete should remove the journal directory")
+	}
+}
@@ -0,0 +1,83 @@
+package lock
+
+import "sort"
+
+// SourceDiff describes how one source differs between two lockfiles.
+// Before is nil for an added source and After is nil for a removed one.
+type SourceDiff struct {
+	Before   *LockedSource
+	After    *LockedSource
+	Name     string
+	Added    []string // file paths, sorted
+	Removed  []string
+	Modified []string
+}
+
+// Diff compares two lockfiles by source name and returns the sources that
+// differ, in the order they appear in newer followed by sources only older
+// has. Either lockfile may be nil, meaning it has no sources.
+func Diff(older, newer *Lockfile) []SourceDiff {
+	if older == nil {
+		older = &Lockfile{}
+	}
+	if newer == nil {
+		newer = &Lockfile{}
+	}
+	olderByName := indexSources(older)
+	newerByName := indexSources(newer)
+
+	var diffs []SourceDiff
+	for i := range newer.Sources {
+		after := &newer.Sources[i]
+		if d, changed := diffSource(olderByName[after.Name], after); changed {
+			diffs = append(diffs, d)
+		}
+	}
+	for i := range older.Sources {
+		before := &older.Sources[i]
+		if _, ok := newerByName[before.Name]; !ok {
+			d, _ := diffSource(before, nil)
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+// DiffSource compares two states of one source. Either may be nil.
+func DiffSource(before, after *LockedSource) SourceDiff {
+	d, _ := diffSource(before, after)
+	return d
+}
+
+func diffSource(before, after *LockedSource) (SourceDiff, bool) {
+	d := SourceDiff{Before: before, After: after}
+	var beforeFiles, afterFiles map[string]FileHash
+	if before != nil {
+		d.Name = before.Name
+		beforeFiles = before.Resolved.Files
+	}
+	if after != nil {
+		d.Name = after.Name
+		afterFiles = after.Resolved.Files
+	}
+
+	for p, fh := range afterFiles {
+		old, ok := beforeFiles[p]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, p)
+		case old.SHA256 != fh.SHA256:
+			d.Modified = append(d.Modified, p)
+		}
+	}
+	for p := range beforeFiles {
+		if _, ok := afterFiles[p]; !ok {
+			d.Removed = append(d.Removed, p)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Modified)
+
+	return d, !sameSource(before, after)
+}
@@ -0,0 +1,47 @@
+package lock
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	older := &Lockfile{Version: 1, Sources: []LockedSource{
+		{Name: "rules", Type: "git", Status: "ok", Resolved: ResolvedState{Commit: "1", Files: map[string]FileHash{
+			"keep.md": {SHA256: "k"}, "edit.md": {SHA256: "e1"}, "gone.md": {SHA256: "g"},
+		}}},
+		{Name: "same", Type: "git", Status: "ok", Resolved: ResolvedState{Commit: "1"}},
+		{Name: "dropped", Type: "url", Status: "ok", Resolved: ResolvedState{SHA256: "d"}},
+	}}
+	newer := &Lockfile{Version: 1, Sources: []LockedSource{
+		{Name: "rules", Type: "git", Status: "ok", Resolved: ResolvedState{Commit: "2", Files: map[string]FileHash{
+			"keep.md": {SHA256: "k"}, "edit.md": {SHA256: "e2"}, "new.md": {SHA256: "n"},
+		}}},
+		{Name: "same", Type: "git", Status: "ok", Resolved: ResolvedState{Commit: "1"}},
+		{Name: "fresh", Type: "local", Status: "ok", Resolved: ResolvedState{Files: map[string]FileHash{"a.md": {SHA256: "a"}}}},
+	}}
+
+	diffs := Diff(older, newer)
+	if len(diffs) != 3 {
+		t.Fatalf("diffs = %+v, want rules, fresh, dropped", diffs)
+	}
+
+	rules := diffs[0]
+	if rules.Name != "rules" || rules.Before == nil || rules.After == nil {
+		t.Fatalf("diffs[0] = %+v", rules)
+	}
+	if !reflect.DeepEqual(rules.Added, []string{"new.md"}) || !reflect.DeepEqual(rules.Removed, []string{"gone.md"}) || !reflect.DeepEqual(rules.Modified, []string{"edit.md"}) {
+		t.Errorf("rules file changes = +%v -%v ~%v", rules.Added, rules.Removed, rules.Modified)
+	}
+
+	if fresh := diffs[1]; fresh.Name != "fresh" || fresh.Before != nil || !reflect.DeepEqual(fresh.Added, []string{"a.md"}) {
+		t.Errorf("diffs[1] = %+v, want added source", fresh)
+	}
+	if dropped := diffs[2]; dropped.Name != "dropped" || dropped.After != nil {
+		t.Errorf("diffs[2] = %+v, want removed source", dropped)
+	}
+
+	if diffs := Diff(newer, newer); len(diffs) != 0 {
+		t.Errorf("identical lockfiles should not differ, got %+v", diffs)
+	}
+}
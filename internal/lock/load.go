@@ -3,50 +3,101 @@ package lock
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-// Load reads and validates an agent-sync.lock file.
+// Load reads and validates an agent-sync.lock file. Older schema versions
+// are upgraded in memory; see Lockfile.Migrations.
 func Load(path string) (*Lockfile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading lockfile %s: %w", path, err)
 	}
+	return Parse(path, data)
+}
 
-	var lf Lockfile
-	if err := yaml.Unmarshal(data, &lf); err != nil {
-		return nil, fmt.Errorf("parsing lockfile %s: %w", path, err)
+// Parse decodes and validates lockfile content read from elsewhere, such
+// as an earlier git revision. name identifies it in errors.
+func Parse(name string, data []byte) (*Lockfile, error) {
+	lf, err := decode(name, data)
+	if err != nil {
+		return nil, err
 	}
 
-	if errs := Validate(&lf); len(errs) > 0 {
+	if errs := Validate(lf); len(errs) > 0 {
 		return nil, &ValidationError{Errors: errs}
 	}
 
-	return &lf, nil
+	return lf, nil
 }
 
-// Save writes a lockfile atomically using a temp file and rename.
+// Save writes a lockfile atomically: the content goes to a uniquely named
+// temp file in the same directory, is fsynced, renamed over path, and the
+// directory is fsynced so the rename survives a crash. Concurrent writers
+// never share a temp file.
 func Save(path string, lf *Lockfile) error {
 	data, err := yaml.Marshal(lf)
 	if err != nil {
 		return fmt.Errorf("marshaling lockfile: %w", err)
 	}
 
-	tmp := path + ".tmp"
-	if err := os.WriteFile(tmp, data, 0644); err != nil {
-		return fmt.Errorf("writing temp lockfile %s: %w", tmp, err)
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp lockfile in %s: %w", dir, err)
+	}
+	tmpPath := tmp.Name()
+
+	success := false
+	defer func() {
+		if !success {
+			_ = tmp.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("writing temp lockfile %s: %w", tmpPath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("syncing temp lockfile %s: %w", tmpPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temp lockfile %s: %w", tmpPath, err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return fmt.Errorf("setting lockfile permissions: %w", err)
 	}
 
-	if err := os.Rename(tmp, path); err != nil {
-		_ = os.Remove(tmp)
+	if err := os.Rename(tmpPath, path); err != nil {
 		return fmt.Errorf("renaming temp lockfile to %s: %w", path, err)
 	}
+	success = true
 
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("syncing lockfile directory %s: %w", dir, err)
+	}
 	return nil
 }
 
+// syncDir flushes a directory entry to disk. Windows cannot fsync
+// directories; renames there are already durable once they return.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
+}
+
 // ValidationError holds multiple validation failures.
 type ValidationError struct {
 	Errors []string
@@ -62,8 +113,10 @@ func Validate(lf *Lockfile) []string {
 	var errs []string
 
 	// Version (Section 14).
-	if lf.Version != 1 {
-		errs = append(errs, fmt.Sprintf("unsupported version %d — only version 1 is supported", lf.Version))
+	if lf.Version > CurrentVersion {
+		errs = append(errs, fmt.Sprintf("unsupported version %d — this agent-sync supports up to version %d; upgrade agent-sync to use this lockfile", lf.Version, CurrentVersion))
+	} else if lf.Version != CurrentVersion {
+		errs = append(errs, fmt.Sprintf("unsupported version %d — only version %d is supported", lf.Version, CurrentVersion))
 	}
 
 	// Check for duplicate source names (Section 8.3).
@@ -85,11 +138,35 @@ func Validate(lf *Lockfile) []string {
 		if src.Type == "" {
 			errs = append(errs, fmt.Sprintf("%s: 'type' is required", prefix))
 		}
+		if c := src.Resolved.Commit; c != "" && !isCommitHash(c) {
+			errs = append(errs, fmt.Sprintf("%s: resolved commit '%s' is not a full hex commit hash", prefix, c))
+		}
 
-		if src.Status == "" {
+		switch src.Status {
+		case "":
 			errs = append(errs, fmt.Sprintf("%s: 'status' is required", prefix))
+		case StatusOK, StatusPinned:
+		default:
+			errs = append(errs, fmt.Sprintf("%s: unknown status '%s' (must be '%s' or '%s')", prefix, src.Status, StatusOK, StatusPinned))
+		}
+		if src.PinReason != "" && src.Status != StatusPinned {
+			errs = append(errs, fmt.Sprintf("%s: 'pin_reason' is only valid when status is '%s'", prefix, StatusPinned))
 		}
 	}
 
 	return errs
 }
+
+// isCommitHash reports whether s is a full SHA-1 or SHA-256 commit hash in
+// lowercase hex, as git prints them.
+func isCommitHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -1,10 +1,15 @@
 package lock
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/bianoble/agent-sync/internal/schema"
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadInvalidYAML(t *testing.T) {
@@ -152,3 +157,106 @@ func TestValidationErrorContainsAllErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestSaveConcurrentWritersUseUniqueTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent-sync.lock")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lf := &Lockfile{
+				Version: 1,
+				Sources: []LockedSource{{Name: fmt.Sprintf("writer-%d", i), Type: "local", Status: "ok"}},
+			}
+			errs <- Save(path, lf)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent Save: %v", err)
+		}
+	}
+
+	if _, err := Load(path); err != nil {
+		t.Fatalf("lockfile corrupted by concurrent saves: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the lockfile to remain, found %d entries", len(entries))
+	}
+}
+
+func TestLoadMigratesOlderVersion(t *testing.T) {
+	old := migrations
+	migrations = []schema.Migration{{
+		From:    0,
+		Summary: "added a note field",
+		Apply: func(root *yaml.Node) error {
+			root.Content = append(root.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: "note"},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: "migrated"})
+			return nil
+		},
+	}}
+	defer func() { migrations = old }()
+
+	path := filepath.Join(t.TempDir(), "agent-sync.lock")
+	data := `version: 0
+sources:
+  - name: s
+    type: local
+    status: ok
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lf, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if lf.Version != CurrentVersion || len(lf.Migrations()) != 1 {
+		t.Fatalf("expected migrated lockfile, got version %d, migrations %q", lf.Version, lf.Migrations())
+	}
+
+	if err := Save(path, lf); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != CurrentVersion || len(saved.Migrations()) != 0 {
+		t.Errorf("saved lockfile should be current, got version %d, migrations %q", saved.Version, saved.Migrations())
+	}
+}
+
+func TestValidateStatus(t *testing.T) {
+	lf := &Lockfile{
+		Version: 1,
+		Sources: []LockedSource{
+			{Name: "a", Type: "git", Status: StatusPinned, PinReason: "broken upstream"},
+			{Name: "b", Type: "git", Status: "frozen"},
+			{Name: "c", Type: "git", Status: StatusOK, PinReason: "stale"},
+		},
+	}
+	errs := Validate(lf)
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v, want 2", errs)
+	}
+	if !strings.Contains(errs[0], "unknown status 'frozen'") {
+		t.Errorf("errs[0] = %q", errs[0])
+	}
+	if !strings.Contains(errs[1], "'pin_reason' is only valid") {
+		t.Errorf("errs[1] = %q", errs[1])
+	}
+}
@@ -153,6 +153,30 @@ func TestValidateDuplicateNames(t *testing.T) {
 	}
 }
 
+func TestValidateCommitHash(t *testing.T) {
+	for _, commit := range []string{"--output=/tmp/x", "3f8c9abf", "3F8C9ABF0E1D2C3B4A5968778695A4B3C2D1E0F9"} {
+		lf := &Lockfile{
+			Version: 1,
+			Sources: []LockedSource{
+				{Name: "rules", Type: "git", Status: "ok", Resolved: ResolvedState{Commit: commit}},
+			},
+		}
+		if errs := Validate(lf); !containsSubstring(errs, "not a full hex commit hash") {
+			t.Errorf("commit %q: expected commit hash error, got: %v", commit, errs)
+		}
+	}
+
+	lf := &Lockfile{
+		Version: 1,
+		Sources: []LockedSource{
+			{Name: "rules", Type: "git", Status: "ok", Resolved: ResolvedState{Commit: "3f8c9abf0e1d2c3b4a5968778695a4b3c2d1e0f9"}},
+		},
+	}
+	if errs := Validate(lf); containsSubstring(errs, "commit") {
+		t.Errorf("unexpected commit error: %v", errs)
+	}
+}
+
 func TestValidateMissingFields(t *testing.T) {
 	lf := &Lockfile{
 		Version: 1,
@@ -0,0 +1,183 @@
+package lock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MergeConflict describes a source that both sides of a merge changed in
+// different ways. A nil side means that side removed the source.
+type MergeConflict struct {
+	Ours   *LockedSource
+	Theirs *LockedSource
+	Source string
+}
+
+func (c MergeConflict) Error() string {
+	return fmt.Sprintf("source '%s': ours %s, theirs %s", c.Source, describeSide(c.Ours), describeSide(c.Theirs))
+}
+
+// Merge performs a three-way merge of lockfiles by source name. A source
+// changed (added, updated, or removed) on only one side takes that side's
+// state; a source changed to the same content on both sides merges cleanly.
+// Only a source that both sides locked to different content is a conflict;
+// the merged lockfile then keeps our state for it. base may be nil when the
+// lockfile did not exist in the common ancestor.
+//
+// Sources are compared by what they lock, not by when or how: the cooldown
+// record and pin fields differ between two updates to the same commit, and
+// are taken from the side that changed them, ours if both did. A side that
+// only pinned or unpinned a source conflicts with one that moved it, since
+// the pin was meant for the state the other side replaced.
+//
+// Merged sources follow our order, with sources added only by theirs placed
+// after the source that precedes them in theirs.
+func Merge(base, ours, theirs *Lockfile) (*Lockfile, []MergeConflict, error) {
+	if base == nil {
+		base = &Lockfile{Version: ours.Version}
+	}
+	if ours.Version != theirs.Version {
+		return nil, nil, fmt.Errorf("cannot merge lockfile version %d with version %d", ours.Version, theirs.Version)
+	}
+
+	baseByName := indexSources(base)
+	oursByName := indexSources(ours)
+	theirsByName := indexSources(theirs)
+
+	merged := &Lockfile{Version: ours.Version}
+	var conflicts []MergeConflict
+
+	// resolve picks the merged state of a source, or nil if it is removed.
+	resolve := func(name string) *LockedSource {
+		b, o, t := baseByName[name], oursByName[name], theirsByName[name]
+		switch {
+		case sameSource(o, t):
+			if o != nil && b != nil && reflect.DeepEqual(o, b) {
+				return t
+			}
+			if o != nil && b != nil && samePin(o, b) && !samePin(t, b) {
+				merged := *o
+				merged.Status, merged.PinReason = t.Status, t.PinReason
+				return &merged
+			}
+			return o
+		case sameSource(b, t) && samePin(b, t):
+			return o
+		case sameSource(b, o) && samePin(b, o):
+			return t
+		default:
+			conflicts = append(conflicts, MergeConflict{Source: name, Ours: o, Theirs: t})
+			return o
+		}
+	}
+
+	for _, ls := range ours.Sources {
+		if s := resolve(ls.Name); s != nil {
+			merged.Sources = append(merged.Sources, *s)
+		}
+	}
+
+	// Sources only theirs has: kept if theirs added them, conflicting if we
+	// removed what they changed.
+	after := ""
+	for _, ls := range theirs.Sources {
+		if _, ok := oursByName[ls.Name]; ok {
+			after = ls.Name
+			continue
+		}
+		s := resolve(ls.Name)
+		if s == nil {
+			continue
+		}
+		merged.Sources = insertAfter(merged.Sources, after, *s)
+		after = ls.Name
+	}
+
+	return merged, conflicts, nil
+}
+
+func indexSources(lf *Lockfile) map[string]*LockedSource {
+	m := make(map[string]*LockedSource, len(lf.Sources))
+	for i := range lf.Sources {
+		m[lf.Sources[i].Name] = &lf.Sources[i]
+	}
+	return m
+}
+
+// sameSource reports whether two source states lock the same content,
+// treating two absent sources as the same. The cooldown record and pin
+// fields are not compared.
+func sameSource(a, b *LockedSource) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ra, rb := a.Resolved, b.Resolved
+	return a.Type == b.Type && a.Repo == b.Repo &&
+		ra.Commit == rb.Commit && ra.Tree == rb.Tree &&
+		ra.URL == rb.URL && ra.SHA256 == rb.SHA256 && ra.Path == rb.Path &&
+		reflect.DeepEqual(ra.Files, rb.Files)
+}
+
+// samePin reports whether two source states are pinned alike. Absent
+// sources are pinned alike only to each other.
+func samePin(a, b *LockedSource) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Status == b.Status && a.PinReason == b.PinReason
+}
+
+// insertAfter inserts ls after the source named after, or first if after is
+// empty or not present.
+func insertAfter(sources []LockedSource, after string, ls LockedSource) []LockedSource {
+	i := 0
+	for j := range sources {
+		if sources[j].Name == after {
+			i = j + 1
+			break
+		}
+	}
+	sources = append(sources, LockedSource{})
+	copy(sources[i+1:], sources[i:])
+	sources[i] = ls
+	return sources
+}
+
+func describeSide(ls *LockedSource) string {
+	if ls == nil {
+		return "removed it"
+	}
+	if ls.Pinned() {
+		return describeLocked(ls) + " and pinned it"
+	}
+	return describeLocked(ls)
+}
+
+func describeLocked(ls *LockedSource) string {
+	switch {
+	case ls.Resolved.Commit != "":
+		return "locked commit " + ls.Resolved.Commit
+	case ls.Resolved.SHA256 != "":
+		return "locked sha256:" + ls.Resolved.SHA256
+	default:
+		return fmt.Sprintf("locked %d file(s) with digest %s", len(ls.Resolved.Files), filesDigest(ls.Resolved.Files))
+	}
+}
+
+// filesDigest returns a short digest identifying a set of locked files, so
+// two sides of a conflict on a local source can be told apart.
+func filesDigest(files map[string]FileHash) string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s\x00%s\n", p, files[p].SHA256)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
@@ -0,0 +1,179 @@
+package lock
+
+import (
+	"strings"
+	"testing"
+)
+
+func gitSource(name, commit string) LockedSource {
+	return LockedSource{
+		Name:   name,
+		Type:   "git",
+		Status: "ok",
+		Resolved: ResolvedState{
+			Commit: commit,
+			Files:  map[string]FileHash{"a.md": {SHA256: commit + "-hash"}},
+		},
+	}
+}
+
+func names(lf *Lockfile) string {
+	var out []string
+	for _, ls := range lf.Sources {
+		out = append(out, ls.Name+"@"+ls.Resolved.Commit)
+	}
+	return strings.Join(out, " ")
+}
+
+func TestMergeDisjointUpdates(t *testing.T) {
+	base := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1"), gitSource("b", "1"), gitSource("c", "1")}}
+	ours := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "2"), gitSource("b", "1"), gitSource("c", "1")}}
+	theirs := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1"), gitSource("b", "3")}}
+
+	merged, conflicts, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("unexpected conflicts: %v", conflicts)
+	}
+	if got := names(merged); got != "a@2 b@3" {
+		t.Errorf("merged = %q, want ours' a, theirs' b, and c removed by theirs", got)
+	}
+}
+
+func TestMergeSameChangeOnBothSides(t *testing.T) {
+	base := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1")}}
+	ours := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "2")}}
+	theirs := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "2")}}
+
+	merged, conflicts, err := Merge(base, ours, theirs)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("expected clean merge, got conflicts=%v err=%v", conflicts, err)
+	}
+	if got := names(merged); got != "a@2" {
+		t.Errorf("merged = %q", got)
+	}
+}
+
+func TestMergeSameCommitWithDifferentCooldown(t *testing.T) {
+	withCooldown := func(age string) LockedSource {
+		ls := gitSource("a", "2")
+		ls.Cooldown = &Cooldown{MinAge: "7d", CommittedAt: "2026-01-01T00:00:00Z", Age: age}
+		return ls
+	}
+	base := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1")}}
+	ours := &Lockfile{Version: 1, Sources: []LockedSource{withCooldown("8d")}}
+	theirs := &Lockfile{Version: 1, Sources: []LockedSource{withCooldown("10d")}}
+
+	merged, conflicts, err := Merge(base, ours, theirs)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("expected clean merge, got conflicts=%v err=%v", conflicts, err)
+	}
+	if got := merged.Sources[0].Cooldown.Age; got != "8d" {
+		t.Errorf("cooldown age = %q, want ours", got)
+	}
+}
+
+func TestMergeKeepsTheirPin(t *testing.T) {
+	base := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1")}}
+	ours := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1")}}
+	pinned := gitSource("a", "1")
+	pinned.Status, pinned.PinReason = StatusPinned, "waiting on review"
+	theirs := &Lockfile{Version: 1, Sources: []LockedSource{pinned}}
+
+	merged, conflicts, err := Merge(base, ours, theirs)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("expected clean merge, got conflicts=%v err=%v", conflicts, err)
+	}
+	if got := merged.Sources[0]; got.Status != StatusPinned || got.PinReason != "waiting on review" {
+		t.Errorf("merged = %+v, want theirs' pin", got)
+	}
+}
+
+func TestMergePinConflictsWithMove(t *testing.T) {
+	pinned := gitSource("a", "1")
+	pinned.Status, pinned.PinReason = StatusPinned, "waiting on review"
+	base := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1")}}
+	ours := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "2")}}
+	theirs := &Lockfile{Version: 1, Sources: []LockedSource{pinned}}
+
+	merged, conflicts, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || !strings.Contains(conflicts[0].Error(), "ours locked commit 2, theirs locked commit 1 and pinned it") {
+		t.Fatalf("conflicts = %v, want our move against their pin", conflicts)
+	}
+	if got := names(merged); got != "a@2" {
+		t.Errorf("merged = %q, want our state for the conflicting source", got)
+	}
+
+	// The same holds the other way round.
+	_, conflicts, err = Merge(base, theirs, ours)
+	if err != nil || len(conflicts) != 1 {
+		t.Errorf("reversed: conflicts = %v, err = %v; want one", conflicts, err)
+	}
+}
+
+func TestMergeKeepsTheirPinWithOurCooldown(t *testing.T) {
+	ourCooldown := gitSource("a", "1")
+	ourCooldown.Cooldown = &Cooldown{MinAge: "7d", CommittedAt: "2026-01-01T00:00:00Z", Age: "8d"}
+	pinned := gitSource("a", "1")
+	pinned.Status, pinned.PinReason = StatusPinned, "waiting on review"
+	base := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1")}}
+	ours := &Lockfile{Version: 1, Sources: []LockedSource{ourCooldown}}
+	theirs := &Lockfile{Version: 1, Sources: []LockedSource{pinned}}
+
+	merged, conflicts, err := Merge(base, ours, theirs)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("expected clean merge, got conflicts=%v err=%v", conflicts, err)
+	}
+	got := merged.Sources[0]
+	if got.Status != StatusPinned || got.PinReason != "waiting on review" || got.Cooldown == nil {
+		t.Errorf("merged = %+v, want their pin and our cooldown", got)
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	base := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1"), gitSource("b", "1")}}
+	ours := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "2")}}
+	theirs := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "3"), gitSource("b", "4")}}
+
+	merged, conflicts, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 2 {
+		t.Fatalf("conflicts = %v, want a (both updated) and b (removed vs updated)", conflicts)
+	}
+	if conflicts[0].Source != "a" || !strings.Contains(conflicts[0].Error(), "ours locked commit 2, theirs locked commit 3") {
+		t.Errorf("conflict[0] = %v", conflicts[0])
+	}
+	if conflicts[1].Source != "b" || !strings.Contains(conflicts[1].Error(), "ours removed it") {
+		t.Errorf("conflict[1] = %v", conflicts[1])
+	}
+	if got := names(merged); got != "a@2" {
+		t.Errorf("merged = %q, want our state for conflicting sources", got)
+	}
+}
+
+func TestMergeAdditionsKeepTheirPosition(t *testing.T) {
+	ours := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("a", "1"), gitSource("c", "1"), gitSource("d", "1")}}
+	theirs := &Lockfile{Version: 1, Sources: []LockedSource{gitSource("first", "1"), gitSource("a", "1"), gitSource("b", "1"), gitSource("c", "1")}}
+
+	// No common ancestor: both sides added every source.
+	merged, conflicts, err := Merge(nil, ours, theirs)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("expected clean merge, got conflicts=%v err=%v", conflicts, err)
+	}
+	if got := names(merged); got != "first@1 a@1 b@1 c@1 d@1" {
+		t.Errorf("merged = %q", got)
+	}
+}
+
+func TestMergeVersionMismatch(t *testing.T) {
+	if _, _, err := Merge(nil, &Lockfile{Version: 1}, &Lockfile{Version: 2}); err == nil {
+		t.Error("expected error merging different lockfile versions")
+	}
+}
@@ -0,0 +1,45 @@
+package lock
+
+import (
+	"fmt"
+
+	"github.com/bianoble/agent-sync/internal/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the lockfile schema version this release reads and
+// writes natively.
+const CurrentVersion = 1
+
+// migrations upgrade older lockfile versions to CurrentVersion, one version
+// per step. Every breaking schema change adds an entry (spec Section 14.3).
+var migrations []schema.Migration
+
+// Migrations returns a note for each schema migration applied in memory
+// while loading the lockfile. Saving the lockfile writes the current version.
+func (lf *Lockfile) Migrations() []string {
+	return lf.migrations
+}
+
+// decode parses a lockfile document, upgrading older schema versions in
+// memory.
+func decode(path string, data []byte) (*Lockfile, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing lockfile %s: %w", path, err)
+	}
+
+	var lf Lockfile
+	if doc.Kind == 0 {
+		return &lf, nil
+	}
+	result, err := schema.Upgrade(&doc, migrations)
+	if err != nil {
+		return nil, fmt.Errorf("lockfile %s: %w", path, err)
+	}
+	if err := doc.Decode(&lf); err != nil {
+		return nil, fmt.Errorf("parsing lockfile %s: %w", path, err)
+	}
+	lf.migrations = result.Notes(path)
+	return &lf, nil
+}
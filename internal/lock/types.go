@@ -1,10 +1,13 @@
 package lock
 
+import "sort"
+
 // Lockfile represents the agent-sync.lock file.
 // See spec Section 4.
 type Lockfile struct {
-	Sources []LockedSource `yaml:"sources"`
-	Version int            `yaml:"version"`
+	Sources    []LockedSource `yaml:"sources"`
+	migrations []string       // schema migrations applied while loading
+	Version    int            `yaml:"version"`
 }
 
 // LockedSource records the fully resolved, immutable state of a source.
@@ -15,6 +18,53 @@ type LockedSource struct {
 	Repo     string        `yaml:"repo,omitempty"`
 	Resolved ResolvedState `yaml:"resolved"`
 	Status   string        `yaml:"status"`
+
+	// PinReason explains why a pinned source is frozen.
+	PinReason string `yaml:"pin_reason,omitempty"`
+
+	// Cooldown records the min_age evaluation for a git source with a
+	// cooldown policy.
+	Cooldown *Cooldown `yaml:"cooldown,omitempty"`
+}
+
+// Cooldown records how a locked commit was checked against the update
+// cooldown (min_age) when it was locked.
+type Cooldown struct {
+	MinAge      string `yaml:"min_age"`
+	CommittedAt string `yaml:"committed_at"` // RFC 3339 committer date
+	Age         string `yaml:"age"`          // commit age when evaluated
+
+	// Tag is the older release locked instead of the ref's latest commit,
+	// which was younger than min_age.
+	Tag string `yaml:"tag,omitempty"`
+
+	// Ignored is set when --ignore-cooldown locked a commit younger than
+	// min_age.
+	Ignored bool `yaml:"ignored,omitempty"`
+}
+
+// Source statuses.
+const (
+	StatusOK = "ok"
+
+	// StatusPinned marks a source frozen at its locked state: 'update'
+	// without source names leaves it alone.
+	StatusPinned = "pinned"
+)
+
+// Pinned reports whether the source is frozen at its locked state.
+func (ls *LockedSource) Pinned() bool {
+	return ls.Status == StatusPinned
+}
+
+// Source returns the locked source with the given name, or nil.
+func (lf *Lockfile) Source(name string) *LockedSource {
+	for i := range lf.Sources {
+		if lf.Sources[i].Name == name {
+			return &lf.Sources[i]
+		}
+	}
+	return nil
 }
 
 // ResolvedState holds the resolved metadata for a source.
@@ -39,3 +89,21 @@ type ResolvedState struct {
 type FileHash struct {
 	SHA256 string `yaml:"sha256"`
 }
+
+// Hashes returns the sorted, de-duplicated content hashes of every file
+// referenced by the lockfile.
+func (lf *Lockfile) Hashes() []string {
+	seen := make(map[string]bool)
+	var hashes []string
+	for _, ls := range lf.Sources {
+		for _, fh := range ls.Resolved.Files {
+			if fh.SHA256 == "" || seen[fh.SHA256] {
+				continue
+			}
+			seen[fh.SHA256] = true
+			hashes = append(hashes, fh.SHA256)
+		}
+	}
+	sort.Strings(hashes)
+	return hashes
+}
@@ -18,7 +18,7 @@ sources:
 
     resolved:
 
-      commit: 3f8c9abf
+      commit: 3f8c9abf0e1d2c3b4a5968778695a4b3c2d1e0f9
       tree: a8bcdef
 
       files:
@@ -84,7 +84,7 @@ func TestLockfileParseSpecExample(t *testing.T) {
 	if git.Repo != "https://github.com/org/rules.git" {
 		t.Errorf("sources[0].repo = %q", git.Repo)
 	}
-	if git.Resolved.Commit != "3f8c9abf" {
+	if git.Resolved.Commit != "3f8c9abf0e1d2c3b4a5968778695a4b3c2d1e0f9" {
 		t.Errorf("sources[0].resolved.commit = %q", git.Resolved.Commit)
 	}
 	if git.Resolved.Tree != "a8bcdef" {
@@ -182,3 +182,24 @@ sources:
 		t.Errorf("version = %d, want 1", lf.Version)
 	}
 }
+
+func TestLockfileHashes(t *testing.T) {
+	lf := Lockfile{
+		Version: 1,
+		Sources: []LockedSource{
+			{Name: "a", Resolved: ResolvedState{Files: map[string]FileHash{
+				"x.md": {SHA256: "bbb"},
+				"y.md": {SHA256: "aaa"},
+			}}},
+			{Name: "b", Resolved: ResolvedState{Files: map[string]FileHash{
+				"x.md": {SHA256: "bbb"},
+				"z.md": {SHA256: ""},
+			}}},
+		},
+	}
+
+	got := lf.Hashes()
+	if len(got) != 2 || got[0] != "aaa" || got[1] != "bbb" {
+		t.Errorf("Hashes() = %v, want [aaa bbb]", got)
+	}
+}
@@ -0,0 +1,150 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// CycloneDX 1.5 JSON. Only the fields agent-sync populates are modelled.
+// serialNumber and metadata.timestamp are optional and left out to keep the
+// output deterministic.
+
+type cdxBOM struct {
+	BOMFormat   string         `json:"bomFormat"`
+	SpecVersion string         `json:"specVersion"`
+	Metadata    cdxMetadata    `json:"metadata"`
+	Components  []cdxComponent `json:"components"`
+	Version     int            `json:"version"`
+}
+
+type cdxMetadata struct {
+	Tools      cdxTools      `json:"tools"`
+	Component  cdxComponent  `json:"component"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type               string           `json:"type"`
+	BOMRef             string           `json:"bom-ref,omitempty"`
+	Name               string           `json:"name"`
+	Version            string           `json:"version,omitempty"`
+	Hashes             []cdxHash        `json:"hashes,omitempty"`
+	ExternalReferences []cdxExternalRef `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty    `json:"properties,omitempty"`
+	Components         []cdxComponent   `json:"components,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func cycloneDX(in Input, components []component) ([]byte, error) {
+	bom := cdxBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Tools: cdxTools{Components: []cdxComponent{{
+				Type:    "application",
+				Name:    "agent-sync",
+				Version: in.ToolVersion,
+			}}},
+			Component: cdxComponent{
+				Type:   "application",
+				BOMRef: "project",
+				Name:   in.Project,
+			},
+		},
+		Components: []cdxComponent{},
+	}
+	for _, l := range in.Layers {
+		bom.Metadata.Properties = append(bom.Metadata.Properties, cdxProperty{
+			Name:  "agent-sync:config-layer",
+			Value: layerDescription(l),
+		})
+	}
+
+	for _, c := range components {
+		bom.Components = append(bom.Components, cdxSource(c))
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(bom); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func cdxSource(c component) cdxComponent {
+	out := cdxComponent{
+		Type:    "data",
+		BOMRef:  "source:" + c.name,
+		Name:    c.name,
+		Version: c.version,
+	}
+	if c.sha256 != "" {
+		out.Hashes = []cdxHash{{Alg: "SHA-256", Content: c.sha256}}
+	}
+	if c.repo != "" {
+		out.ExternalReferences = append(out.ExternalReferences, cdxExternalRef{Type: "vcs", URL: c.repo})
+	}
+	if c.url != "" {
+		out.ExternalReferences = append(out.ExternalReferences, cdxExternalRef{Type: "distribution", URL: c.url})
+	}
+
+	props := []cdxProperty{{Name: "agent-sync:source-type", Value: c.sourceType}}
+	for _, p := range []struct{ name, value string }{
+		{"agent-sync:ref", c.ref},
+		{"agent-sync:commit", c.commit},
+		{"agent-sync:tree", c.tree},
+		{"agent-sync:path", c.path},
+	} {
+		if p.value != "" {
+			props = append(props, cdxProperty{Name: p.name, Value: p.value})
+		}
+	}
+	for _, tx := range c.transforms {
+		props = append(props, cdxProperty{Name: "agent-sync:transform", Value: tx})
+	}
+	out.Properties = props
+
+	for _, f := range c.files {
+		fc := cdxComponent{
+			Type:   "file",
+			BOMRef: "file:" + c.name + "/" + f.path,
+			Name:   f.path,
+			Hashes: []cdxHash{{Alg: "SHA-256", Content: f.sha256}},
+		}
+		for _, dest := range f.destinations {
+			fc.Properties = append(fc.Properties, cdxProperty{Name: "agent-sync:destination", Value: dest})
+		}
+		out.Components = append(out.Components, fc)
+	}
+	return out
+}
+
+func layerDescription(l Layer) string {
+	desc := l.Level + " " + l.Path
+	if l.SHA256 != "" {
+		desc += " sha256:" + l.SHA256
+	}
+	return desc
+}
@@ -0,0 +1,163 @@
+// Package sbom exports the agent content a project syncs as a software bill
+// of materials, in CycloneDX or SPDX JSON.
+//
+// Output is deterministic: it depends only on the lockfile, the config, and
+// the fields of Input, never on the clock or the order of map iteration, so
+// the same inputs produce byte-identical documents suitable for attaching to
+// release artifacts.
+package sbom
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/target"
+)
+
+// Format names accepted by Generate.
+const (
+	FormatCycloneDX = "cyclonedx"
+	FormatSPDX      = "spdx"
+)
+
+// Input is everything an SBOM is built from.
+type Input struct {
+	// Created is the SPDX document creation time. CycloneDX output omits
+	// timestamps altogether.
+	Created  time.Time
+	Lockfile *lock.Lockfile
+	Config   *config.Config
+	ToolMap  *target.ToolMap
+
+	// Project names the described project, usually its directory name.
+	Project string
+
+	// ToolVersion is the agent-sync version recorded as the generating tool.
+	ToolVersion string
+
+	// Layers lists the config files the config was merged from.
+	Layers []Layer
+}
+
+// Layer identifies a config file that contributed to the merged config.
+type Layer struct {
+	Level  string
+	Path   string
+	SHA256 string
+}
+
+// Generate renders the SBOM in the named format.
+func Generate(format string, in Input) ([]byte, error) {
+	if in.Lockfile == nil {
+		return nil, fmt.Errorf("sbom: no lockfile")
+	}
+	if in.Config == nil {
+		return nil, fmt.Errorf("sbom: no config")
+	}
+	components, err := collect(in)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatCycloneDX:
+		return cycloneDX(in, components)
+	case FormatSPDX:
+		return spdx(in, components)
+	default:
+		return nil, fmt.Errorf("unknown SBOM format %q (want %s or %s)", format, FormatCycloneDX, FormatSPDX)
+	}
+}
+
+// component is the format-neutral description of one locked source.
+type component struct {
+	name       string
+	sourceType string
+	version    string // commit, sha256, or empty for local sources
+	repo       string
+	ref        string
+	commit     string
+	tree       string
+	url        string
+	sha256     string
+	path       string
+	transforms []string // "template" or "custom: <command>", in config order
+	files      []file   // sorted by path
+}
+
+// file is one file of a source and the project paths it is synced to.
+type file struct {
+	path         string
+	sha256       string
+	destinations []string // slash-separated, sorted
+}
+
+// collect builds one component per locked source, in lockfile order.
+func collect(in Input) ([]component, error) {
+	tm := in.ToolMap
+	if tm == nil {
+		tm = target.NewToolMap(in.Config.ToolDefinitions)
+	}
+	destinations := make(map[string][]string)
+	for _, tgt := range in.Config.Targets {
+		resolved, err := tm.ResolveTarget(tgt)
+		if err != nil {
+			return nil, fmt.Errorf("resolving targets: %w", err)
+		}
+		for _, r := range resolved {
+			destinations[tgt.Source] = append(destinations[tgt.Source], r.Destination)
+		}
+	}
+
+	sourceRefs := make(map[string]string)
+	for _, s := range in.Config.Sources {
+		sourceRefs[s.Name] = s.Ref
+	}
+	transforms := make(map[string][]string)
+	for _, tx := range in.Config.Transforms {
+		desc := tx.Type
+		if tx.Command != "" {
+			desc += ": " + tx.Command
+		}
+		transforms[tx.Source] = append(transforms[tx.Source], desc)
+	}
+
+	components := make([]component, 0, len(in.Lockfile.Sources))
+	for _, ls := range in.Lockfile.Sources {
+		c := component{
+			name:       ls.Name,
+			sourceType: ls.Type,
+			repo:       ls.Repo,
+			ref:        sourceRefs[ls.Name],
+			commit:     ls.Resolved.Commit,
+			tree:       ls.Resolved.Tree,
+			url:        ls.Resolved.URL,
+			sha256:     ls.Resolved.SHA256,
+			path:       ls.Resolved.Path,
+			transforms: transforms[ls.Name],
+		}
+		switch {
+		case c.commit != "":
+			c.version = c.commit
+		case c.sha256 != "":
+			c.version = "sha256:" + c.sha256
+		}
+
+		for p, fh := range ls.Resolved.Files {
+			f := file{path: p, sha256: fh.SHA256}
+			for _, dest := range destinations[ls.Name] {
+				f.destinations = append(f.destinations, path.Clean(filepath.ToSlash(filepath.Join(dest, p))))
+			}
+			sort.Strings(f.destinations)
+			c.files = append(c.files, f)
+		}
+		sort.Slice(c.files, func(i, j int) bool { return c.files[i].path < c.files[j].path })
+
+		components = append(components, c)
+	}
+	return components, nil
+}
@@ -0,0 +1,133 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+)
+
+func testInput() Input {
+	return Input{
+		Created:     time.Unix(1700000000, 0),
+		Project:     "demo",
+		ToolVersion: "1.2.3",
+		Layers:      []Layer{{Level: "project", Path: "agent-sync.yaml", SHA256: "cfg"}},
+		Config: &config.Config{
+			Version: 1,
+			Sources: []config.Source{
+				{Name: "rules", Type: "git", Repo: "https://github.com/org/rules.git", Ref: "v1"},
+				{Name: "style", Type: "url", URL: "https://example.com/style.md"},
+			},
+			Targets: []config.Target{
+				{Source: "rules", Tools: []string{"cursor", "claude-code"}},
+				{Source: "style", Destination: "docs/"},
+			},
+			Transforms: []config.Transform{{Source: "rules", Type: "template"}},
+		},
+		Lockfile: &lock.Lockfile{Version: 1, Sources: []lock.LockedSource{
+			{Name: "rules", Type: "git", Repo: "https://github.com/org/rules.git", Status: "ok", Resolved: lock.ResolvedState{
+				Commit: "abc123", Tree: "def456",
+				Files: map[string]lock.FileHash{"b.md": {SHA256: "bb"}, "a.md": {SHA256: "aa"}},
+			}},
+			{Name: "style", Type: "url", Status: "ok", Resolved: lock.ResolvedState{
+				URL: "https://example.com/style.md", SHA256: "ss",
+				Files: map[string]lock.FileHash{"style.md": {SHA256: "ss"}},
+			}},
+		}},
+	}
+}
+
+func TestCycloneDX(t *testing.T) {
+	out, err := Generate(FormatCycloneDX, testInput())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	var bom cdxBOM
+	if err := json.Unmarshal(out, &bom); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if bom.BOMFormat != "CycloneDX" || len(bom.Components) != 2 {
+		t.Fatalf("bom = %+v", bom)
+	}
+
+	rules := bom.Components[0]
+	if rules.Version != "abc123" || rules.ExternalReferences[0].URL != "https://github.com/org/rules.git" {
+		t.Errorf("rules = %+v", rules)
+	}
+	if len(rules.Components) != 2 || rules.Components[0].Name != "a.md" {
+		t.Fatalf("rules files = %+v", rules.Components)
+	}
+	var dests []string
+	for _, p := range rules.Components[0].Properties {
+		dests = append(dests, p.Value)
+	}
+	if strings.Join(dests, ",") != ".claude/a.md,.cursor/rules/a.md" {
+		t.Errorf("destinations = %v", dests)
+	}
+	if !bytes.Contains(out, []byte(`"agent-sync:transform"`)) || !bytes.Contains(out, []byte(`"agent-sync:config-layer"`)) {
+		t.Error("missing transform or config layer property")
+	}
+
+	style := bom.Components[1]
+	if style.Version != "sha256:ss" || style.Hashes[0].Content != "ss" {
+		t.Errorf("style = %+v", style)
+	}
+}
+
+func TestSPDX(t *testing.T) {
+	out, err := Generate(FormatSPDX, testInput())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	var doc spdxDocument
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if doc.CreationInfo.Created != "2023-11-14T22:13:20Z" {
+		t.Errorf("created = %s", doc.CreationInfo.Created)
+	}
+	if len(doc.Packages) != 2 || doc.Packages[0].DownloadLocation != "git+https://github.com/org/rules.git@abc123" {
+		t.Fatalf("packages = %+v", doc.Packages)
+	}
+	// Two rules files synced to two tools each, plus one style file.
+	if len(doc.Files) != 5 {
+		t.Fatalf("files = %d, want 5", len(doc.Files))
+	}
+	if doc.Files[4].FileName != "./docs/style.md" {
+		t.Errorf("style file = %s", doc.Files[4].FileName)
+	}
+	if len(doc.Relationships) != 7 {
+		t.Errorf("relationships = %d, want 7", len(doc.Relationships))
+	}
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	for _, format := range []string{FormatCycloneDX, FormatSPDX} {
+		first, err := Generate(format, testInput())
+		if err != nil {
+			t.Fatalf("Generate(%s): %v", format, err)
+		}
+		for range 10 {
+			again, err := Generate(format, testInput())
+			if err != nil {
+				t.Fatalf("Generate(%s): %v", format, err)
+			}
+			if !bytes.Equal(first, again) {
+				t.Fatalf("%s output differs between runs", format)
+			}
+		}
+	}
+}
+
+func TestGenerateUnknownFormat(t *testing.T) {
+	if _, err := Generate("xml", testInput()); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
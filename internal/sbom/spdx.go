@@ -0,0 +1,202 @@
+package sbom
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SPDX 2.3 JSON. Each source is a package; each synced file is a file
+// named by its project path, contained in its source's package. A file
+// synced to several destinations appears once per destination.
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Files             []spdxFile         `json:"files"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Comment  string   `json:"comment,omitempty"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string         `json:"SPDXID"`
+	Name             string         `json:"name"`
+	VersionInfo      string         `json:"versionInfo,omitempty"`
+	DownloadLocation string         `json:"downloadLocation"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	LicenseDeclared  string         `json:"licenseDeclared"`
+	CopyrightText    string         `json:"copyrightText"`
+	SourceInfo       string         `json:"sourceInfo,omitempty"`
+	Comment          string         `json:"comment,omitempty"`
+	Checksums        []spdxChecksum `json:"checksums,omitempty"`
+	FilesAnalyzed    bool           `json:"filesAnalyzed"`
+}
+
+type spdxFile struct {
+	SPDXID           string         `json:"SPDXID"`
+	FileName         string         `json:"fileName"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	CopyrightText    string         `json:"copyrightText"`
+	Comment          string         `json:"comment,omitempty"`
+	Checksums        []spdxChecksum `json:"checksums"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const noAssertion = "NOASSERTION"
+
+func spdx(in Input, components []component) ([]byte, error) {
+	doc := spdxDocument{
+		SPDXVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        "agent-sync-" + in.Project,
+		CreationInfo: spdxCreationInfo{
+			Created:  in.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: agent-sync-" + in.ToolVersion},
+		},
+		Packages:      []spdxPackage{},
+		Files:         []spdxFile{},
+		Relationships: []spdxRelationship{},
+	}
+	var layers []string
+	for _, l := range in.Layers {
+		layers = append(layers, "config layer: "+layerDescription(l))
+	}
+	doc.CreationInfo.Comment = strings.Join(layers, "\n")
+
+	for i, c := range components {
+		pkgID := fmt.Sprintf("SPDXRef-Source-%d-%s", i+1, spdxIDPart(c.name))
+		doc.Packages = append(doc.Packages, spdxSource(pkgID, c))
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      doc.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: pkgID,
+		})
+
+		for j, f := range c.files {
+			names := f.destinations
+			comment := "source path: " + f.path
+			if len(names) == 0 {
+				names = []string{f.path}
+				comment = "not synced to any target"
+			}
+			for k, name := range names {
+				fileID := fmt.Sprintf("SPDXRef-File-%d-%d-%d", i+1, j+1, k+1)
+				doc.Files = append(doc.Files, spdxFile{
+					SPDXID:           fileID,
+					FileName:         "./" + name,
+					LicenseConcluded: noAssertion,
+					CopyrightText:    noAssertion,
+					Comment:          comment,
+					Checksums:        []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: f.sha256}},
+				})
+				doc.Relationships = append(doc.Relationships, spdxRelationship{
+					SPDXElementID:      pkgID,
+					RelationshipType:   "CONTAINS",
+					RelatedSPDXElement: fileID,
+				})
+			}
+		}
+	}
+
+	// The namespace must be unique per document; derive it from the
+	// content so that identical inputs still give identical output.
+	content, err := json.Marshal(struct {
+		Packages []spdxPackage
+		Files    []spdxFile
+		Created  string
+	}{doc.Packages, doc.Files, doc.CreationInfo.Created})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	doc.DocumentNamespace = "https://spdx.org/spdxdocs/" + doc.Name + "-" + hex.EncodeToString(sum[:16])
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func spdxSource(id string, c component) spdxPackage {
+	pkg := spdxPackage{
+		SPDXID:           id,
+		Name:             c.name,
+		VersionInfo:      c.version,
+		DownloadLocation: noAssertion,
+		LicenseConcluded: noAssertion,
+		LicenseDeclared:  noAssertion,
+		CopyrightText:    noAssertion,
+	}
+	switch {
+	case c.repo != "" && c.commit != "":
+		pkg.DownloadLocation = "git+" + strings.TrimPrefix(c.repo, "git+") + "@" + c.commit
+	case c.repo != "":
+		pkg.DownloadLocation = "git+" + strings.TrimPrefix(c.repo, "git+")
+	case c.url != "":
+		pkg.DownloadLocation = c.url
+	}
+	if c.sha256 != "" {
+		pkg.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: c.sha256}}
+	}
+
+	info := []string{"type: " + c.sourceType}
+	for _, p := range []struct{ name, value string }{
+		{"ref", c.ref},
+		{"commit", c.commit},
+		{"tree", c.tree},
+		{"path", c.path},
+	} {
+		if p.value != "" {
+			info = append(info, p.name+": "+p.value)
+		}
+	}
+	pkg.SourceInfo = strings.Join(info, "; ")
+
+	var transforms []string
+	for _, tx := range c.transforms {
+		transforms = append(transforms, "transform: "+tx)
+	}
+	pkg.Comment = strings.Join(transforms, "\n")
+	return pkg
+}
+
+// spdxIDPart reduces s to the characters allowed in an SPDX identifier.
+func spdxIDPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, s)
+}
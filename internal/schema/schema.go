@@ -0,0 +1,106 @@
+// Package schema upgrades config and lockfile documents written for an
+// older schema version to the current one (spec Section 14).
+//
+// Migrations operate on the parsed YAML node tree before it is decoded, so
+// a migration can rename, move, or drop fields that the current Go types no
+// longer know about, and comments survive when the document is written back.
+package schema
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Migration upgrades a document from version From to From+1.
+type Migration struct {
+	// Apply rewrites the top-level mapping node in place. It does not need
+	// to update the version field.
+	Apply   func(root *yaml.Node) error
+	Summary string // one line describing the change, shown to the user
+	From    int
+}
+
+// Result reports the migrations applied to a document.
+type Result struct {
+	Applied []string // summaries, in order
+	From    int
+	To      int
+}
+
+// Migrated reports whether any migration was applied.
+func (r *Result) Migrated() bool {
+	return r != nil && r.To != r.From
+}
+
+// Notes returns one human-readable line per applied migration, prefixed
+// with name (usually the file path).
+func (r *Result) Notes(name string) []string {
+	if !r.Migrated() {
+		return nil
+	}
+	notes := make([]string, 0, len(r.Applied))
+	for i, summary := range r.Applied {
+		notes = append(notes, fmt.Sprintf("%s: upgraded from version %d to %d: %s", name, r.From+i, r.From+i+1, summary))
+	}
+	return notes
+}
+
+// Upgrade applies migrations to doc, starting at its declared version, until
+// no migration starts at the resulting version. Documents without a version
+// field, and versions with no migration (including versions newer than any
+// migration knows), are left untouched for validation to report.
+func Upgrade(doc *yaml.Node, migrations []Migration) (*Result, error) {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return &Result{}, nil
+	}
+
+	versionNode := mappingValue(root, "version")
+	if versionNode == nil {
+		return &Result{}, nil
+	}
+	version, err := strconv.Atoi(versionNode.Value)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: version must be an integer, got %q", versionNode.Line, versionNode.Value)
+	}
+
+	byFrom := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		byFrom[m.From] = m
+	}
+
+	result := &Result{From: version, To: version}
+	for {
+		m, ok := byFrom[result.To]
+		if !ok {
+			break
+		}
+		if err := m.Apply(root); err != nil {
+			return nil, fmt.Errorf("migrating from version %d to %d: %w", m.From, m.From+1, err)
+		}
+		result.To++
+		result.Applied = append(result.Applied, m.Summary)
+	}
+
+	if result.Migrated() {
+		versionNode.Value = strconv.Itoa(result.To)
+		versionNode.Tag = "!!int"
+		versionNode.Style = 0
+	}
+	return result, nil
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renameKey returns a migration step that renames a top-level key.
+func renameKey(from int, oldKey, newKey string) Migration {
+	return Migration{
+		From:    from,
+		Summary: "renamed '" + oldKey + "' to '" + newKey + "'",
+		Apply: func(root *yaml.Node) error {
+			for i := 0; i+1 < len(root.Content); i += 2 {
+				if root.Content[i].Value == oldKey {
+					root.Content[i].Value = newKey
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func parse(t *testing.T, s string) *yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return &doc
+}
+
+func TestUpgradeAppliesChain(t *testing.T) {
+	doc := parse(t, "# keep me\nversion: 1\nitems: [a]\n")
+	migrations := []Migration{renameKey(2, "entries", "sources"), renameKey(1, "items", "entries")}
+
+	result, err := Upgrade(doc, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.From != 1 || result.To != 3 || !result.Migrated() {
+		t.Fatalf("result = %+v, want 1 → 3", result)
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); !strings.Contains(got, "# keep me") || !strings.Contains(got, "version: 3") || !strings.Contains(got, "sources: [a]") {
+		t.Errorf("upgraded document = %q", got)
+	}
+
+	notes := result.Notes("agent-sync.yaml")
+	if len(notes) != 2 || notes[1] != "agent-sync.yaml: upgraded from version 2 to 3: renamed 'entries' to 'sources'" {
+		t.Errorf("notes = %q", notes)
+	}
+}
+
+func TestUpgradeLeavesOtherVersionsAlone(t *testing.T) {
+	migrations := []Migration{renameKey(1, "items", "entries")}
+
+	for _, src := range []string{"items: [a]\n", "version: 2\nitems: [a]\n", "version: 9\n", "- a\n"} {
+		doc := parse(t, src)
+		result, err := Upgrade(doc, migrations)
+		if err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+		if result.Migrated() || result.Notes("f") != nil {
+			t.Errorf("%q: expected no migration, got %+v", src, result)
+		}
+	}
+}
+
+func TestUpgradeRejectsNonIntegerVersion(t *testing.T) {
+	if _, err := Upgrade(parse(t, "version: one\n"), nil); err == nil {
+		t.Error("expected error for non-integer version")
+	}
+}
@@ -0,0 +1,186 @@
+// Package signing produces and verifies detached ed25519 signatures over
+// the canonical bytes of a lockfile.
+//
+// Public keys use the OpenSSH authorized_keys format ("ssh-ed25519 AAAA...
+// comment"), so existing SSH keys can be allowed directly. Private keys may
+// be PKCS#8 PEM files (as written by 'agent-sync lock keygen' or
+// 'openssl genpkey -algorithm ed25519') or unencrypted OpenSSH ed25519 keys.
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const sshEd25519 = "ssh-ed25519"
+
+// ParsePublicKey parses an authorized_keys style ed25519 public key.
+// A trailing comment is returned separately.
+func ParsePublicKey(s string) (ed25519.PublicKey, string, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return nil, "", fmt.Errorf("public key must look like 'ssh-ed25519 AAAA...'")
+	}
+	if fields[0] != sshEd25519 {
+		return nil, "", fmt.Errorf("unsupported key type %q — only %s keys are supported", fields[0], sshEd25519)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding public key: %w", err)
+	}
+
+	r := sshReader{buf: blob}
+	keyType := r.string()
+	key := r.string()
+	if r.err != nil || string(keyType) != sshEd25519 || len(key) != ed25519.PublicKeySize || len(r.buf) != 0 {
+		return nil, "", fmt.Errorf("malformed %s public key", sshEd25519)
+	}
+	return ed25519.PublicKey(key), strings.Join(fields[2:], " "), nil
+}
+
+// FormatPublicKey renders a public key in authorized_keys format.
+func FormatPublicKey(pub ed25519.PublicKey, comment string) string {
+	s := sshEd25519 + " " + base64.StdEncoding.EncodeToString(publicKeyBlob(pub))
+	if comment != "" {
+		s += " " + comment
+	}
+	return s
+}
+
+// Fingerprint returns the OpenSSH-style SHA256 fingerprint of a public key,
+// as printed by 'ssh-keygen -l'.
+func Fingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKeyBlob(pub))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// ParsePrivateKey parses a PKCS#8 PEM or unencrypted OpenSSH ed25519
+// private key.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in private key")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		priv, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is %T — only ed25519 keys are supported", key)
+		}
+		return priv, nil
+	case "OPENSSH PRIVATE KEY":
+		return parseOpenSSHPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q", block.Type)
+	}
+}
+
+// MarshalPrivateKey encodes a private key as a PKCS#8 PEM block.
+func MarshalPrivateKey(priv ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("encoding private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// parseOpenSSHPrivateKey decodes the "openssh-key-v1" format written by
+// ssh-keygen. Only unencrypted keys are supported.
+func parseOpenSSHPrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	const magic = "openssh-key-v1\x00"
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, fmt.Errorf("malformed OpenSSH private key")
+	}
+	r := sshReader{buf: data[len(magic):]}
+	cipher := r.string()
+	_ = r.string() // kdf name
+	_ = r.string() // kdf options
+	nkeys := r.uint32()
+	_ = r.string() // public key
+	private := r.string()
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed OpenSSH private key: %w", r.err)
+	}
+	if string(cipher) != "none" {
+		return nil, fmt.Errorf("encrypted OpenSSH keys are not supported — use an unencrypted key or a PKCS#8 key from 'agent-sync lock keygen'")
+	}
+	if nkeys != 1 {
+		return nil, fmt.Errorf("OpenSSH private key file holds %d keys, expected 1", nkeys)
+	}
+
+	r = sshReader{buf: private}
+	check1, check2 := r.uint32(), r.uint32()
+	keyType := r.string()
+	_ = r.string() // public key
+	priv := r.string()
+	if r.err != nil || check1 != check2 {
+		return nil, fmt.Errorf("malformed OpenSSH private key")
+	}
+	if string(keyType) != sshEd25519 {
+		return nil, fmt.Errorf("unsupported key type %q — only %s keys are supported", keyType, sshEd25519)
+	}
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("malformed %s private key", sshEd25519)
+	}
+	return ed25519.PrivateKey(priv), nil
+}
+
+func publicKeyBlob(pub ed25519.PublicKey) []byte {
+	var b bytes.Buffer
+	writeSSHString(&b, []byte(sshEd25519))
+	writeSSHString(&b, pub)
+	return b.Bytes()
+}
+
+func writeSSHString(b *bytes.Buffer, s []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(s)))
+	b.Write(n[:])
+	b.Write(s)
+}
+
+// sshReader decodes SSH wire-format fields, remembering the first error.
+type sshReader struct {
+	err error
+	buf []byte
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 4 {
+		r.err = errors.New("unexpected end of data")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *sshReader) string() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.buf)) < uint64(n) {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	s := r.buf[:n]
+	r.buf = r.buf[n:]
+	return s
+}
@@ -0,0 +1,200 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/lock"
+	"gopkg.in/yaml.v3"
+)
+
+// SignatureSuffix is appended to the lockfile path to name its detached
+// signature file.
+const SignatureSuffix = ".sig"
+
+// domain separates lockfile signatures from signatures made with the same
+// key for any other purpose.
+const domain = "agent-sync lockfile v1\n"
+
+// ErrUnsigned is returned when a lockfile has no signature file or the
+// file holds no signatures.
+var ErrUnsigned = errors.New("lockfile is not signed")
+
+// ErrUntrusted is returned when no signature is both valid and made by an
+// allowed key.
+var ErrUntrusted = errors.New("lockfile has no valid signature from an allowed key")
+
+// SignatureFile is the detached signature file stored next to the lockfile.
+type SignatureFile struct {
+	Signatures []Signature `yaml:"signatures"`
+	Version    int         `yaml:"version"`
+}
+
+// Signature is one signer's signature over the canonical lockfile bytes.
+type Signature struct {
+	Signer    string `yaml:"signer,omitempty"`
+	PublicKey string `yaml:"public_key"`
+	Signature string `yaml:"signature"` // base64
+}
+
+// Signer identifies the key behind a valid signature.
+type Signer struct {
+	Name        string // allowed key name if trusted, else the signer recorded in the signature
+	Fingerprint string
+	Trusted     bool // key is in the allowed list
+}
+
+// SignaturePath returns the signature file path for a lockfile.
+func SignaturePath(lockfilePath string) string {
+	return lockfilePath + SignatureSuffix
+}
+
+// Canonical returns the bytes that are signed for a lockfile: its
+// normalized YAML encoding, so formatting changes do not invalidate a
+// signature but any change to its content does.
+func Canonical(lf *lock.Lockfile) ([]byte, error) {
+	data, err := yaml.Marshal(lf)
+	if err != nil {
+		return nil, fmt.Errorf("encoding lockfile: %w", err)
+	}
+	return append([]byte(domain), data...), nil
+}
+
+// Sign produces a signature over canonical lockfile bytes.
+func Sign(canonical []byte, priv ed25519.PrivateKey, signer string) Signature {
+	pub, _ := priv.Public().(ed25519.PublicKey)
+	return Signature{
+		Signer:    signer,
+		PublicKey: FormatPublicKey(pub, ""),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, canonical)),
+	}
+}
+
+// Add records sig, replacing any earlier signature by the same key.
+func (sf *SignatureFile) Add(sig Signature) {
+	for i, existing := range sf.Signatures {
+		if existing.PublicKey == sig.PublicKey {
+			sf.Signatures[i] = sig
+			return
+		}
+	}
+	sf.Signatures = append(sf.Signatures, sig)
+	sort.Slice(sf.Signatures, func(i, j int) bool { return sf.Signatures[i].PublicKey < sf.Signatures[j].PublicKey })
+}
+
+// DropInvalid removes signatures that do not verify against canonical,
+// such as those made over an earlier version of the lockfile. It returns
+// the number removed.
+func (sf *SignatureFile) DropInvalid(canonical []byte) int {
+	kept := sf.Signatures[:0]
+	for _, sig := range sf.Signatures {
+		if sig.valid(canonical) {
+			kept = append(kept, sig)
+		}
+	}
+	dropped := len(sf.Signatures) - len(kept)
+	sf.Signatures = kept
+	return dropped
+}
+
+func (sig Signature) valid(canonical []byte) bool {
+	pub, _, err := ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return false
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	return err == nil && ed25519.Verify(pub, canonical, raw)
+}
+
+// LoadSignatures reads a signature file. Returns nil, nil if it does not exist.
+func LoadSignatures(path string) (*SignatureFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading signature file %s: %w", path, err)
+	}
+	var sf SignatureFile
+	if err := yaml.Unmarshal(data, &sf); err != nil {
+		return nil, fmt.Errorf("parsing signature file %s: %w", path, err)
+	}
+	if sf.Version != 1 {
+		return nil, fmt.Errorf("signature file %s: unsupported version %d — only version 1 is supported", path, sf.Version)
+	}
+	return &sf, nil
+}
+
+// SaveSignatures writes a signature file.
+func SaveSignatures(path string, sf *SignatureFile) error {
+	data, err := yaml.Marshal(sf)
+	if err != nil {
+		return fmt.Errorf("encoding signature file: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing signature file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Check returns the signers whose signatures over canonical are valid.
+// Signers whose key is in allowed are marked trusted and named after the
+// allowed key. Invalid signatures are skipped.
+func Check(canonical []byte, sf *SignatureFile, allowed []config.AllowedKey) ([]Signer, error) {
+	if sf == nil || len(sf.Signatures) == 0 {
+		return nil, ErrUnsigned
+	}
+
+	trusted := make(map[string]string, len(allowed))
+	for _, k := range allowed {
+		pub, _, err := ParsePublicKey(k.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("allowed key '%s': %w", k.Name, err)
+		}
+		trusted[string(pub)] = k.Name
+	}
+
+	var signers []Signer
+	for _, sig := range sf.Signatures {
+		if !sig.valid(canonical) {
+			continue
+		}
+		pub, _, _ := ParsePublicKey(sig.PublicKey)
+		s := Signer{Name: sig.Signer, Fingerprint: Fingerprint(pub)}
+		if name, ok := trusted[string(pub)]; ok {
+			s.Name = name
+			s.Trusted = true
+		}
+		signers = append(signers, s)
+	}
+	return signers, nil
+}
+
+// VerifyLockfile enforces the signing policy for the lockfile at path: it
+// returns the first trusted signer, or an error wrapping ErrUnsigned or
+// ErrUntrusted.
+func VerifyLockfile(path string, lf *lock.Lockfile, settings config.SigningSettings) (*Signer, error) {
+	canonical, err := Canonical(lf)
+	if err != nil {
+		return nil, err
+	}
+	sf, err := LoadSignatures(SignaturePath(path))
+	if err != nil {
+		return nil, err
+	}
+	signers, err := Check(canonical, sf, settings.AllowedKeys)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range signers {
+		if s.Trusted {
+			return &s, nil
+		}
+	}
+	return nil, ErrUntrusted
+}
@@ -4,17 +4,32 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bianoble/agent-sync/internal/config"
 )
 
 // GitResolver resolves and fetches files from git repositories.
-type GitResolver struct{}
+type GitResolver struct {
+	// Mirrors, if set, are cloned from instead of each repository.
+	Mirrors *GitMirrors
+}
+
+// remote returns where to clone repo from: its mirror, if the resolver
+// has mirrors, or repo itself.
+func (g *GitResolver) remote(ctx context.Context, repo string) (string, error) {
+	if g.Mirrors == nil {
+		return repo, nil
+	}
+	return g.Mirrors.path(ctx, repo)
+}
 
 func (g *GitResolver) Resolve(ctx context.Context, src config.Source, projectRoot string) (*ResolvedSource, error) {
 	if src.Repo == "" {
@@ -32,7 +47,11 @@ func (g *GitResolver) Resolve(ctx context.Context, src config.Source, projectRoo
 	defer func() { _ = os.RemoveAll(tmpDir) }()
 
 	// Shallow clone with the specified ref.
-	if cloneErr := gitClone(ctx, src.Repo, src.Ref, tmpDir); cloneErr != nil {
+	remote, err := g.remote(ctx, src.Repo)
+	if err != nil {
+		return nil, &SourceError{Source: src.Name, Operation: "resolve", Err: err, Hint: "check repo URL and authentication"}
+	}
+	if cloneErr := gitClone(ctx, remote, src.Ref, tmpDir); cloneErr != nil {
 		return nil, &SourceError{Source: src.Name, Operation: "resolve", Err: cloneErr, Hint: "check repo URL, ref, and authentication"}
 	}
 
@@ -48,6 +67,11 @@ func (g *GitResolver) Resolve(ctx context.Context, src config.Source, projectRoo
 		return nil, &SourceError{Source: src.Name, Operation: "resolve", Err: fmt.Errorf("resolving tree: %w", err)}
 	}
 
+	committedAt, err := gitCommitTime(ctx, tmpDir, "HEAD")
+	if err != nil {
+		return nil, &SourceError{Source: src.Name, Operation: "resolve", Err: fmt.Errorf("reading commit date: %w", err)}
+	}
+
 	// Walk files and compute hashes.
 	files := make(map[string]string)
 	for _, pathFilter := range effectivePaths(src.Paths) {
@@ -97,15 +121,79 @@ func (g *GitResolver) Resolve(ctx context.Context, src config.Source, projectRoo
 	}
 
 	return &ResolvedSource{
-		Name:   src.Name,
-		Type:   "git",
-		Commit: commit,
-		Tree:   tree,
-		Repo:   src.Repo,
-		Files:  files,
+		Name:        src.Name,
+		Type:        "git",
+		Commit:      commit,
+		Tree:        tree,
+		Repo:        src.Repo,
+		Files:       files,
+		CommittedAt: committedAt,
 	}, nil
 }
 
+// ResolveRelease resolves src at the newest tag that is reachable from its
+// ref and whose commit was committed no later than cutoff. Tags are ordered
+// by the committer date of the commit they point to.
+func (g *GitResolver) ResolveRelease(ctx context.Context, src config.Source, projectRoot string, cutoff time.Time) (string, *ResolvedSource, error) {
+	tmpDir, err := os.MkdirTemp("", "agent-sync-git-tags-*")
+	if err != nil {
+		return "", nil, &SourceError{Source: src.Name, Operation: "resolve", Err: fmt.Errorf("creating temp dir: %w", err)}
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	remote, err := g.remote(ctx, src.Repo)
+	if err == nil {
+		err = gitHistoryClone(ctx, remote, tmpDir)
+	}
+	if err != nil {
+		return "", nil, &SourceError{Source: src.Name, Operation: "resolve", Err: err, Hint: "check repo URL and authentication"}
+	}
+
+	head, err := gitRevParse(ctx, tmpDir, src.Ref+"^{commit}")
+	if err != nil {
+		return "", nil, &SourceError{Source: src.Name, Operation: "resolve", Err: fmt.Errorf("resolving ref %s: %w", src.Ref, err)}
+	}
+
+	// One line per tag: name, then the committer date of the tagged commit
+	// for lightweight tags or of the peeled commit for annotated ones.
+	out, err := exec.CommandContext(ctx, "git", "-C", tmpDir, "for-each-ref", "--merged", head,
+		"--format=%(refname:short)%09%(committerdate:unix)%09%(*committerdate:unix)", "refs/tags").Output()
+	if err != nil {
+		return "", nil, &SourceError{Source: src.Name, Operation: "resolve", Err: fmt.Errorf("listing tags: %w", err)}
+	}
+
+	var best string
+	var bestTime int64
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		date := fields[1]
+		if fields[2] != "" {
+			date = fields[2]
+		}
+		secs, err := strconv.ParseInt(date, 10, 64)
+		if err != nil || secs > cutoff.Unix() {
+			continue
+		}
+		if best == "" || secs > bestTime || (secs == bestTime && fields[0] > best) {
+			best, bestTime = fields[0], secs
+		}
+	}
+	if best == "" {
+		return "", nil, &SourceError{Source: src.Name, Operation: "resolve", Err: fmt.Errorf("%w: no tag on %s was committed before %s", ErrNoRelease, src.Ref, cutoff.UTC().Format(time.RFC3339))}
+	}
+
+	atTag := src
+	atTag.Ref = best
+	resolved, err := g.Resolve(ctx, atTag, projectRoot)
+	if err != nil {
+		return "", nil, err
+	}
+	return best, resolved, nil
+}
+
 func (g *GitResolver) Fetch(ctx context.Context, resolved *ResolvedSource) ([]FetchedFile, error) {
 	if resolved.Repo == "" {
 		return nil, &SourceError{Source: resolved.Name, Operation: "fetch", Err: fmt.Errorf("resolved source missing repo URL")}
@@ -118,7 +206,11 @@ func (g *GitResolver) Fetch(ctx context.Context, resolved *ResolvedSource) ([]Fe
 	defer func() { _ = os.RemoveAll(tmpDir) }()
 
 	// Clone at the resolved commit.
-	if cloneErr := gitCloneAtCommit(ctx, resolved.Repo, resolved.Commit, tmpDir); cloneErr != nil {
+	remote, err := g.remote(ctx, resolved.Repo)
+	if err != nil {
+		return nil, &SourceError{Source: resolved.Name, Operation: "fetch", Err: err, Hint: "check repo access and commit SHA"}
+	}
+	if cloneErr := gitCloneAtCommit(ctx, remote, resolved.Commit, tmpDir); cloneErr != nil {
 		return nil, &SourceError{Source: resolved.Name, Operation: "fetch", Err: cloneErr, Hint: "check repo access and commit SHA"}
 	}
 
@@ -197,6 +289,93 @@ func gitRevParse(ctx context.Context, repoDir, rev string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// Log returns the commits reachable from to but not from, newest first,
+// limited to those touching the source's paths. to may be any revision,
+// such as the source's ref.
+func (g *GitResolver) Log(ctx context.Context, src config.Source, from, to string) ([]Commit, error) {
+	if src.Repo == "" {
+		return nil, &SourceError{Source: src.Name, Operation: "log", Err: fmt.Errorf("repo is required"), Hint: "add 'repo: https://...' to the source"}
+	}
+	// Revisions come from the lockfile and the command line; one that git
+	// would parse as an option could make it write files.
+	for _, rev := range []string{from, to} {
+		if rev == "" || strings.HasPrefix(rev, "-") {
+			return nil, &SourceError{Source: src.Name, Operation: "log", Err: fmt.Errorf("invalid revision %q", rev)}
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "agent-sync-git-log-*")
+	if err != nil {
+		return nil, &SourceError{Source: src.Name, Operation: "log", Err: fmt.Errorf("creating temp dir: %w", err)}
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	remote, err := g.remote(ctx, src.Repo)
+	if err == nil {
+		err = gitHistoryClone(ctx, remote, tmpDir)
+	}
+	if err != nil {
+		return nil, &SourceError{Source: src.Name, Operation: "log", Err: err, Hint: "check repo URL and authentication"}
+	}
+
+	// Fields are NUL-separated and records end in RS, so subjects and
+	// bodies may contain anything but those.
+	args := []string{"-C", tmpDir, "log", "--format=%H%x00%an%x00%ct%x00%s%x00%b%x1e", "--end-of-options", from + ".." + to}
+	if len(src.Paths) > 0 {
+		args = append(args, "--")
+		args = append(args, src.Paths...)
+	}
+	out, err := exec.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, &SourceError{Source: src.Name, Operation: "log", Err: fmt.Errorf("git log %s..%s: %w", from, to, err)}
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(string(out), "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x00", 5)
+		if len(fields) != 5 {
+			continue
+		}
+		secs, _ := strconv.ParseInt(fields[2], 10, 64)
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Date:    time.Unix(secs, 0).UTC(),
+			Subject: fields[3],
+			Body:    strings.TrimSpace(fields[4]),
+		})
+	}
+	return commits, nil
+}
+
+// gitHistoryClone makes a bare clone with full history and tags but
+// without file contents, which are fetched lazily if ever needed.
+func gitHistoryClone(ctx context.Context, repo, dest string) error {
+	cmd := exec.CommandContext(ctx, "git", "clone", "--bare", "--filter=blob:none", repo, dest)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git clone failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// gitCommitTime returns the committer date of rev.
+func gitCommitTime(ctx context.Context, repoDir, rev string) (time.Time, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", repoDir, "log", "-1", "--format=%ct", rev).Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+	secs, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing commit date %q: %w", strings.TrimSpace(string(out)), err)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
 func hashFile(path string) (string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -2,11 +2,13 @@ package source
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/bianoble/agent-sync/internal/config"
 )
@@ -151,3 +153,152 @@ func TestGitResolverNoPathFilter(t *testing.T) {
 		t.Errorf("expected 2 files, got %d: %v", len(resolved.Files), resolved.Files)
 	}
 }
+
+func TestGitResolverResolveRelease(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	workDir := t.TempDir()
+	bareRepo := t.TempDir()
+
+	commit := func(content, date string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(workDir, "r.md"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{{"add", "."}, {"commit", "-m", content}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = workDir
+			cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@test.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test.com", "GIT_COMMITTER_DATE="+date)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %s: %v", args, out, err)
+			}
+		}
+	}
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = workDir
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %v", args, out, err)
+		}
+	}
+
+	run("init", "-b", "main")
+	commit("one", "2026-01-01T00:00:00Z")
+	run("tag", "v1")
+	commit("two", "2026-02-01T00:00:00Z")
+	run("tag", "-a", "v2", "-m", "v2")
+	commit("three", "2026-03-01T00:00:00Z")
+	run("tag", "v3")
+	run("clone", "--bare", workDir, bareRepo)
+
+	r := &GitResolver{}
+	src := config.Source{Name: "rules", Type: "git", Repo: bareRepo, Ref: "main"}
+
+	latest, err := r.Resolve(context.Background(), src, t.TempDir())
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC); !latest.CommittedAt.Equal(want) {
+		t.Errorf("CommittedAt = %v, want %v", latest.CommittedAt, want)
+	}
+
+	tag, resolved, err := r.ResolveRelease(context.Background(), src, t.TempDir(), time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ResolveRelease: %v", err)
+	}
+	if tag != "v2" {
+		t.Errorf("tag = %q, want v2 (annotated tags use the tagged commit's date)", tag)
+	}
+	if resolved.Commit == latest.Commit {
+		t.Error("release should not be the latest commit")
+	}
+
+	_, _, err = r.ResolveRelease(context.Background(), src, t.TempDir(), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrNoRelease) {
+		t.Errorf("err = %v, want ErrNoRelease", err)
+	}
+}
+
+func TestGitResolverLog(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	workDir := t.TempDir()
+	bareRepo := t.TempDir()
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = workDir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@test.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %s: %v", args, out, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(file, message string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(workDir, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(workDir, file), []byte(message), 0644); err != nil {
+			t.Fatal(err)
+		}
+		run("add", ".")
+		run("commit", "-m", message)
+	}
+
+	run("init", "-b", "main")
+	commit("rules/a.md", "initial")
+	base := run("rev-parse", "HEAD")
+	commit("rules/a.md", "feat(rules): add a\n\nBREAKING CHANGE: renamed")
+	commit("other.txt", "chore: unrelated")
+	commit("rules/a.md", "fix: typo")
+	run("clone", "--bare", workDir, bareRepo)
+
+	r := &GitResolver{}
+	src := config.Source{Name: "rules", Type: "git", Repo: bareRepo, Ref: "main"}
+
+	all, err := r.Log(context.Background(), src, base, "main")
+	if err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	if len(all) != 3 || all[0].Subject != "fix: typo" || all[2].Subject != "feat(rules): add a" {
+		t.Fatalf("commits = %+v", all)
+	}
+	if all[2].Author != "test" || !strings.Contains(all[2].Body, "BREAKING CHANGE: renamed") || all[2].Date.IsZero() {
+		t.Errorf("commit = %+v", all[2])
+	}
+
+	src.Paths = []string{"rules/"}
+	filtered, err := r.Log(context.Background(), src, base, "main")
+	if err != nil {
+		t.Fatalf("Log with paths: %v", err)
+	}
+	if len(filtered) != 2 {
+		t.Errorf("filtered commits = %d, want 2 (commits outside paths excluded)", len(filtered))
+	}
+
+	if _, err := r.Log(context.Background(), src, base, "no-such-ref"); err == nil {
+		t.Error("expected error for unknown revision")
+	}
+}
+
+func TestGitResolverLogRejectsOptionRevision(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "x")
+	r := &GitResolver{}
+	src := config.Source{Name: "rules", Type: "git", Repo: t.TempDir(), Ref: "main"}
+
+	if _, err := r.Log(context.Background(), src, "--output="+out, "main"); err == nil {
+		t.Fatal("expected error for revision starting with '-'")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("git wrote %s", out)
+	}
+}
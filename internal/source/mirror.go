@@ -0,0 +1,82 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GitMirrors holds mirror clones of git repositories, made on first use,
+// so that a run covering many projects fetches each repository once. A
+// GitResolver with mirrors clones from them locally instead of from the
+// network. Close removes them.
+type GitMirrors struct {
+	repos map[string]*gitMirror
+	dir   string
+	mu    sync.Mutex
+}
+
+type gitMirror struct {
+	err  error
+	path string
+	once sync.Once
+}
+
+// NewGitMirrors returns an empty set of mirrors.
+func NewGitMirrors() *GitMirrors {
+	return &GitMirrors{repos: make(map[string]*gitMirror)}
+}
+
+// path returns the local mirror of repo, cloning it on first use. A failed
+// clone is not retried.
+func (m *GitMirrors) path(ctx context.Context, repo string) (string, error) {
+	m.mu.Lock()
+	if m.dir == "" {
+		dir, err := os.MkdirTemp("", "agent-sync-mirrors-*")
+		if err != nil {
+			m.mu.Unlock()
+			return "", fmt.Errorf("creating mirror dir: %w", err)
+		}
+		m.dir = dir
+	}
+	mirror, ok := m.repos[repo]
+	if !ok {
+		mirror = &gitMirror{path: filepath.Join(m.dir, strconv.Itoa(len(m.repos)))}
+		m.repos[repo] = mirror
+	}
+	m.mu.Unlock()
+
+	mirror.once.Do(func() {
+		cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", "--quiet", repo, mirror.path)
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			mirror.err = fmt.Errorf("git clone failed: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+	})
+	return mirror.path, mirror.err
+}
+
+// Fetched returns the number of repositories fetched so far.
+func (m *GitMirrors) Fetched() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.repos)
+}
+
+// Close removes the mirrors.
+func (m *GitMirrors) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(m.dir)
+	m.dir = ""
+	m.repos = make(map[string]*gitMirror)
+	return err
+}
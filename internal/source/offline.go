@@ -0,0 +1,31 @@
+package source
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bianoble/agent-sync/internal/config"
+)
+
+// ErrOffline is returned by resolvers that would need network access
+// while offline mode is active.
+var ErrOffline = errors.New("network access disabled (offline mode)")
+
+// OfflineResolver stands in for a network-backed resolver when offline mode
+// is active. Every operation fails immediately with ErrOffline instead of
+// attempting a clone or HTTP request.
+type OfflineResolver struct{}
+
+func (OfflineResolver) Resolve(ctx context.Context, src config.Source, projectRoot string) (*ResolvedSource, error) {
+	return nil, &SourceError{Source: src.Name, Operation: "resolve", Err: ErrOffline, Hint: offlineHint}
+}
+
+func (OfflineResolver) Fetch(ctx context.Context, resolved *ResolvedSource) ([]FetchedFile, error) {
+	return nil, &SourceError{Source: resolved.Name, Operation: "fetch", Err: ErrOffline, Hint: offlineHint}
+}
+
+const offlineHint = "seed the cache first or unset --offline / AGENT_SYNC_OFFLINE"
+
+func (OfflineResolver) Log(ctx context.Context, src config.Source, from, to string) ([]Commit, error) {
+	return nil, &SourceError{Source: src.Name, Operation: "log", Err: ErrOffline, Hint: offlineHint}
+}
@@ -2,11 +2,13 @@ package source
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/bianoble/agent-sync/internal/config"
 )
@@ -20,8 +22,40 @@ type Resolver interface {
 	Fetch(ctx context.Context, resolved *ResolvedSource) ([]FetchedFile, error)
 }
 
+// ReleaseResolver is implemented by resolvers that can resolve a source at
+// an earlier release than its ref currently points to. It backs the
+// update cooldown (min_age).
+type ReleaseResolver interface {
+	// ResolveRelease resolves src at the newest tag reachable from its ref
+	// whose commit was committed no later than cutoff, and returns the tag.
+	// It returns an error wrapping ErrNoRelease if there is no such tag.
+	ResolveRelease(ctx context.Context, src config.Source, projectRoot string, cutoff time.Time) (string, *ResolvedSource, error)
+}
+
+// HistoryResolver is implemented by resolvers whose sources have a commit
+// history, for changelogs.
+type HistoryResolver interface {
+	// Log returns the commits reachable from to but not from, newest
+	// first, limited to those touching the source's paths.
+	Log(ctx context.Context, src config.Source, from, to string) ([]Commit, error)
+}
+
+// Commit is one entry of a source's history.
+type Commit struct {
+	Date    time.Time
+	Hash    string
+	Author  string
+	Subject string
+	Body    string
+}
+
+// ErrNoRelease is returned by ResolveRelease when no tag is old enough.
+var ErrNoRelease = errors.New("no release tag old enough")
+
 // ResolvedSource holds the fully resolved, immutable state of a source.
 type ResolvedSource struct {
+	CommittedAt time.Time // git only: committer date of Commit
+
 	Files  map[string]string // relative path -> sha256 hash
 	Name   string
 	Type   string
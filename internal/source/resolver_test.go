@@ -1,9 +1,13 @@
 package source
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
+
+	"github.com/bianoble/agent-sync/internal/config"
 )
 
 func TestRegistryGetUnknown(t *testing.T) {
@@ -55,3 +59,20 @@ func TestSourceErrorUnwrap(t *testing.T) {
 		t.Error("Unwrap should return inner error")
 	}
 }
+
+func TestOfflineResolverFailsFast(t *testing.T) {
+	r := OfflineResolver{}
+
+	_, err := r.Resolve(context.Background(), config.Source{Name: "rules", Type: "git"}, t.TempDir())
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("Resolve error = %v, want ErrOffline", err)
+	}
+
+	_, err = r.Fetch(context.Background(), &ResolvedSource{Name: "rules", Type: "git"})
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("Fetch error = %v, want ErrOffline", err)
+	}
+	if !strings.Contains(err.Error(), "rules") {
+		t.Errorf("error should name the source: %v", err)
+	}
+}
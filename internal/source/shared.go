@@ -0,0 +1,137 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bianoble/agent-sync/internal/config"
+)
+
+// Shared returns a registry whose git and url resolvers resolve and fetch
+// identical sources once, however many projects define them and under
+// whatever names: sources are identical when their type, location, ref or
+// checksum, and paths match. Local sources, which belong to their project,
+// are resolved as before. Results are kept for the life of the registry.
+func (r *Registry) Shared() *Registry {
+	shared := NewRegistry()
+	for t, res := range r.resolvers {
+		switch res := res.(type) {
+		case *GitResolver:
+			shared.Register(t, &sharedGitResolver{sharedResolver: newSharedResolver(res), git: res})
+		case *URLResolver:
+			shared.Register(t, newSharedResolver(res))
+		default:
+			shared.Register(t, res)
+		}
+	}
+	return shared
+}
+
+type sharedResolver struct {
+	next     Resolver
+	resolved map[string]*sharedCall[*ResolvedSource]
+	fetched  map[string]*sharedCall[[]FetchedFile]
+	mu       sync.Mutex
+}
+
+// sharedCall is one resolve or fetch, made by the first caller and waited
+// for by the rest.
+type sharedCall[T any] struct {
+	value T
+	err   error
+	once  sync.Once
+}
+
+func newSharedResolver(next Resolver) *sharedResolver {
+	return &sharedResolver{
+		next:     next,
+		resolved: make(map[string]*sharedCall[*ResolvedSource]),
+		fetched:  make(map[string]*sharedCall[[]FetchedFile]),
+	}
+}
+
+func sharedGet[T any](mu *sync.Mutex, calls map[string]*sharedCall[T], key string) *sharedCall[T] {
+	mu.Lock()
+	defer mu.Unlock()
+	c, ok := calls[key]
+	if !ok {
+		c = &sharedCall[T]{}
+		calls[key] = c
+	}
+	return c
+}
+
+func (s *sharedResolver) Resolve(ctx context.Context, src config.Source, projectRoot string) (*ResolvedSource, error) {
+	c := sharedGet(&s.mu, s.resolved, resolveKey(src, projectRoot))
+	c.once.Do(func() { c.value, c.err = s.next.Resolve(ctx, src, projectRoot) })
+	if c.err != nil {
+		return nil, renameError(c.err, src.Name)
+	}
+	resolved := *c.value
+	resolved.Name = src.Name
+	resolved.Files = make(map[string]string, len(c.value.Files))
+	for path, hash := range c.value.Files {
+		resolved.Files[path] = hash
+	}
+	return &resolved, nil
+}
+
+func (s *sharedResolver) Fetch(ctx context.Context, resolved *ResolvedSource) ([]FetchedFile, error) {
+	c := sharedGet(&s.mu, s.fetched, fetchKey(resolved))
+	c.once.Do(func() { c.value, c.err = s.next.Fetch(ctx, resolved) })
+	if c.err != nil {
+		return nil, renameError(c.err, resolved.Name)
+	}
+	return append([]FetchedFile(nil), c.value...), nil
+}
+
+// sharedGitResolver keeps the release and history lookups of the git
+// resolver, which are not shared.
+type sharedGitResolver struct {
+	*sharedResolver
+	git *GitResolver
+}
+
+func (s *sharedGitResolver) ResolveRelease(ctx context.Context, src config.Source, projectRoot string, cutoff time.Time) (string, *ResolvedSource, error) {
+	return s.git.ResolveRelease(ctx, src, projectRoot, cutoff)
+}
+
+func (s *sharedGitResolver) Log(ctx context.Context, src config.Source, from, to string) ([]Commit, error) {
+	return s.git.Log(ctx, src, from, to)
+}
+
+// resolveKey identifies what a source resolves to, leaving out its name.
+func resolveKey(src config.Source, projectRoot string) string {
+	path := src.Path
+	if path != "" {
+		path = filepath.Join(projectRoot, path)
+	}
+	return strings.Join(append([]string{src.Type, src.Repo, src.Ref, src.URL, src.Checksum, path}, src.Paths...), "\x00")
+}
+
+// fetchKey identifies the content of a resolved source.
+func fetchKey(r *ResolvedSource) string {
+	files := make([]string, 0, len(r.Files))
+	for path, hash := range r.Files {
+		files = append(files, path+"="+hash)
+	}
+	sort.Strings(files)
+	return strings.Join(append([]string{r.Type, r.Repo, r.Commit, r.URL, r.Path}, files...), "\x00")
+}
+
+// renameError names source in a shared error, which names the source the
+// call was first made for.
+func renameError(err error, source string) error {
+	var serr *SourceError
+	if !errors.As(err, &serr) {
+		return err
+	}
+	renamed := *serr
+	renamed.Source = source
+	return &renamed
+}
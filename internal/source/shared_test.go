@@ -0,0 +1,158 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/bianoble/agent-sync/internal/config"
+)
+
+// countingResolver resolves every source to the same file, counting calls.
+type countingResolver struct {
+	resolves, fetches int
+	err               error
+}
+
+func (c *countingResolver) Resolve(ctx context.Context, src config.Source, projectRoot string) (*ResolvedSource, error) {
+	c.resolves++
+	if c.err != nil {
+		return nil, &SourceError{Source: src.Name, Operation: "resolve", Err: c.err}
+	}
+	return &ResolvedSource{Name: src.Name, Type: src.Type, URL: src.URL, Files: map[string]string{"a.md": "abc"}}, nil
+}
+
+func (c *countingResolver) Fetch(ctx context.Context, resolved *ResolvedSource) ([]FetchedFile, error) {
+	c.fetches++
+	return []FetchedFile{{RelPath: "a.md", SHA256: "abc", Content: []byte("a")}}, nil
+}
+
+func TestSharedResolvesIdenticalSourcesOnce(t *testing.T) {
+	counter := &countingResolver{}
+	shared := newSharedResolver(counter)
+	ctx := context.Background()
+
+	a, err := shared.Resolve(ctx, config.Source{Name: "rules", Type: "url", URL: "https://example.com/a.md"}, "/ws/api")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	b, err := shared.Resolve(ctx, config.Source{Name: "team-rules", Type: "url", URL: "https://example.com/a.md"}, "/ws/web")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if _, err := shared.Resolve(ctx, config.Source{Name: "other", Type: "url", URL: "https://example.com/b.md"}, "/ws/web"); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if counter.resolves != 2 {
+		t.Errorf("resolved %d times, want 2", counter.resolves)
+	}
+	if a.Name != "rules" || b.Name != "team-rules" {
+		t.Errorf("names = %q, %q; want each source's own name", a.Name, b.Name)
+	}
+
+	for _, r := range []*ResolvedSource{a, b} {
+		if _, err := shared.Fetch(ctx, r); err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+	}
+	if counter.fetches != 1 {
+		t.Errorf("fetched %d times, want 1", counter.fetches)
+	}
+}
+
+func TestSharedErrorsNameEachSource(t *testing.T) {
+	counter := &countingResolver{err: errors.New("connection refused")}
+	shared := newSharedResolver(counter)
+	ctx := context.Background()
+
+	_, _ = shared.Resolve(ctx, config.Source{Name: "rules", Type: "url", URL: "https://example.com/a.md"}, "/ws/api")
+	_, err := shared.Resolve(ctx, config.Source{Name: "team-rules", Type: "url", URL: "https://example.com/a.md"}, "/ws/web")
+	var serr *SourceError
+	if !errors.As(err, &serr) || serr.Source != "team-rules" {
+		t.Errorf("err = %v, want a source error naming team-rules", err)
+	}
+	if counter.resolves != 1 {
+		t.Errorf("resolved %d times, want 1", counter.resolves)
+	}
+}
+
+func TestSharedRegistryKeepsLocalResolver(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register("git", &GitResolver{})
+	reg.Register("local", &LocalResolver{})
+	shared := reg.Shared()
+
+	git, _ := shared.Get("git")
+	if _, ok := git.(ReleaseResolver); !ok {
+		t.Error("shared git resolver should resolve releases")
+	}
+	if _, ok := git.(HistoryResolver); !ok {
+		t.Error("shared git resolver should read history")
+	}
+	local, _ := shared.Get("local")
+	if _, ok := local.(*LocalResolver); !ok {
+		t.Errorf("local resolver = %T, want *LocalResolver", local)
+	}
+}
+
+func TestGitMirrorsFetchEachRepoOnce(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	workDir := t.TempDir()
+	bareRepo := filepath.Join(t.TempDir(), "rules.git")
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = workDir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@test.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@test.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %v", args, out, err)
+		}
+	}
+	run("init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(workDir, "r.md"), []byte("# Rules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", ".")
+	run("commit", "-m", "initial")
+	run("tag", "v1")
+	run("clone", "--bare", workDir, bareRepo)
+
+	mirrors := NewGitMirrors()
+	defer func() { _ = mirrors.Close() }()
+	r := &GitResolver{Mirrors: mirrors}
+	ctx := context.Background()
+
+	for _, ref := range []string{"main", "v1"} {
+		resolved, err := r.Resolve(ctx, config.Source{Name: "rules", Type: "git", Repo: bareRepo, Ref: ref}, t.TempDir())
+		if err != nil {
+			t.Fatalf("Resolve %s: %v", ref, err)
+		}
+		if resolved.Repo != bareRepo {
+			t.Errorf("Repo = %q, want the upstream repo, not the mirror", resolved.Repo)
+		}
+		fetched, err := r.Fetch(ctx, resolved)
+		if err != nil {
+			t.Fatalf("Fetch %s: %v", ref, err)
+		}
+		if len(fetched) != 1 || string(fetched[0].Content) != "# Rules\n" {
+			t.Errorf("fetched %v", fetched)
+		}
+	}
+	if n := mirrors.Fetched(); n != 1 {
+		t.Errorf("fetched %d repos, want 1", n)
+	}
+
+	// The mirror is used from now on: the upstream can go away.
+	if err := os.RemoveAll(bareRepo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Resolve(ctx, config.Source{Name: "rules", Type: "git", Repo: bareRepo, Ref: "v1"}, t.TempDir()); err != nil {
+		t.Errorf("Resolve after upstream removal: %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+// Package textdiff renders line-based unified diffs, in the format produced
+// by 'diff -u' and 'git diff', and pairs up the lines two texts share.
+package textdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// DevNull is the label for the missing side of an added or removed file.
+const DevNull = "/dev/null"
+
+type editKind byte
+
+const (
+	editEqual  editKind = ' '
+	editDelete editKind = '-'
+	editInsert editKind = '+'
+)
+
+// edit is one line of the edit script. aLine and bLine are the 0-based
+// positions in each input the line is at (or would be inserted at).
+type edit struct {
+	text  string
+	aLine int
+	bLine int
+	kind  editKind
+}
+
+// Unified returns a unified diff turning a into b, labelled oldName and
+// newName, or "" if the contents are equal. Binary content (containing a
+// NUL byte) is reported in a single line instead of diffed.
+func Unified(oldName, newName string, a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	if bytes.IndexByte(a, 0) >= 0 || bytes.IndexByte(b, 0) >= 0 {
+		return fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+	}
+
+	script := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks(script) {
+		writeHunk(&out, script[h[0]:h[1]])
+	}
+	return out.String()
+}
+
+// Stat returns the number of lines added and removed turning a into b.
+func Stat(a, b []byte) (added, removed int) {
+	if bytes.Equal(a, b) {
+		return 0, 0
+	}
+	for _, e := range diffLines(splitLines(a), splitLines(b)) {
+		switch e.kind {
+		case editInsert:
+			added++
+		case editDelete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// splitLines splits s into lines, each keeping its trailing newline. The
+// last line lacks one if s does not end in a newline.
+func splitLines(s []byte) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(s), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script with Myers' algorithm, in its
+// linear-space form: memory stays proportional to the input however many
+// lines differ.
+func diffLines(a, b []string) []edit {
+	var script []edit
+	x, y := 0, 0
+	Match(a, b, func(i, j int) {
+		for ; x < i; x++ {
+			script = append(script, edit{kind: editDelete, text: a[x], aLine: x, bLine: y})
+		}
+		for ; y < j; y++ {
+			script = append(script, edit{kind: editInsert, text: b[y], aLine: x, bLine: y})
+		}
+		script = append(script, edit{kind: editEqual, text: a[x], aLine: x, bLine: y})
+		x++
+		y++
+	})
+	for ; x < len(a); x++ {
+		script = append(script, edit{kind: editDelete, text: a[x], aLine: x, bLine: y})
+	}
+	for ; y < len(b); y++ {
+		script = append(script, edit{kind: editInsert, text: b[y], aLine: x, bLine: y})
+	}
+	return script
+}
+
+// Match calls keep, in order, with the indexes of each pair of equal lines
+// that a shortest edit script turning a into b leaves in place: a longest
+// common subsequence of the two. Like the diffs, it needs memory
+// proportional to the input only.
+func Match(a, b []string, keep func(i, j int)) {
+	// Compare lines by number, and leave out lines that only one side has:
+	// they cannot be kept, so the search need not consider them.
+	ids := make(map[string]int)
+	id := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, l := range lines {
+			n, ok := ids[l]
+			if !ok {
+				n = len(ids)
+				ids[l] = n
+			}
+			out[i] = n
+		}
+		return out
+	}
+	aIDs, bIDs := id(a), id(b)
+	inA, inB := make([]bool, len(ids)), make([]bool, len(ids))
+	for _, n := range aIDs {
+		inA[n] = true
+	}
+	for _, n := range bIDs {
+		inB[n] = true
+	}
+	var aIdx, bIdx, aKept, bKept []int
+	for i, n := range aIDs {
+		if inB[n] {
+			aIdx, aKept = append(aIdx, i), append(aKept, n)
+		}
+	}
+	for j, n := range bIDs {
+		if inA[n] {
+			bIdx, bKept = append(bIdx, j), append(bKept, n)
+		}
+	}
+
+	l := &lcs{a: aKept, b: bKept, keep: func(i, j int) { keep(aIdx[i], bIdx[j]) }}
+	l.compare(0, len(aKept), 0, len(bKept))
+}
+
+// lcs finds the lines a shortest edit script keeps, calling keep for each
+// pair in order.
+type lcs struct {
+	keep func(i, j int)
+	a, b []int
+}
+
+// compare finds the kept lines of a[aLo:aHi] and b[bLo:bHi]: it splits the
+// ranges at the middle of a shortest edit path and recurses on each half.
+func (l *lcs) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && l.a[aLo] == l.b[bLo] {
+		l.keep(aLo, bLo)
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && l.a[aHi-suffix-1] == l.b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	if aLo < aHi && bLo < bHi {
+		if x, y, ok := l.split(aLo, aHi, bLo, bHi); ok {
+			l.compare(aLo, x, bLo, y)
+			l.compare(x, aHi, y, bHi)
+		}
+	}
+	for i := 0; i < suffix; i++ {
+		l.keep(aHi+i, bHi+i)
+	}
+}
+
+// split returns a point on a shortest edit path through a[aLo:aHi] and
+// b[bLo:bHi], found by searching forwards from the start and backwards from
+// the end until the paths overlap. ok is false if the ranges have no line
+// in common.
+func (l *lcs) split(aLo, aHi, bLo, bHi int) (x, y int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	// forward[k] is the furthest x reached on diagonal k = x - y from the
+	// start; backward[k] is the furthest distance from the end on diagonal
+	// k of the reversed inputs.
+	forward := make([]int, 2*maxD+3)
+	backward := make([]int, 2*maxD+3)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonals that ran off the edge of the grid are not searched again.
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && l.a[aLo+x] == l.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				if r := offset + delta - k; r >= 0 && r < len(backward) && backward[r] != -1 && x >= n-backward[r] {
+					return aLo + x, bLo + y, true
+				}
+			}
+		}
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && l.a[aHi-x-1] == l.b[bHi-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				if f := offset + delta - k; f >= 0 && f < len(forward) && forward[f] != -1 && forward[f] >= n-x {
+					fx := forward[f]
+					return aLo + fx, bLo + fx - (f - offset), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// hunks returns the [start, end) ranges of script to print: every change
+// with up to contextLines of unchanged lines around it, merging changes
+// whose context would overlap.
+func hunks(script []edit) [][2]int {
+	var ranges [][2]int
+	for i, e := range script {
+		if e.kind == editEqual {
+			continue
+		}
+		start := max(i-contextLines, 0)
+		end := min(i+contextLines+1, len(script))
+		if n := len(ranges); n > 0 && start <= ranges[n-1][1] {
+			ranges[n-1][1] = end
+			continue
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+func writeHunk(out *strings.Builder, lines []edit) {
+	aStart, bStart := lines[0].aLine, lines[0].bLine
+	aLen, bLen := 0, 0
+	for _, e := range lines {
+		if e.kind != editInsert {
+			aLen++
+		}
+		if e.kind != editDelete {
+			bLen++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+	for _, e := range lines {
+		out.WriteByte(byte(e.kind))
+		out.WriteString(e.text)
+		if !strings.HasSuffix(e.text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats a hunk's line range. Lines are 1-based; an empty range
+// names the line before it.
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, length)
+	}
+}
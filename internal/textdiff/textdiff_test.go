@@ -0,0 +1,147 @@
+package textdiff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{
+			"modified line",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			"--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{"new file", "", "x\ny\n", "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n"},
+		{"removed file", "x\n", "", "--- a\n+++ b\n@@ -1 +0,0 @@\n-x\n"},
+		{
+			"missing newline",
+			"x\ny",
+			"x\ny\n",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n x\n-y\n\\ No newline at end of file\n+y\n",
+		},
+		{"binary", "a\x00", "b\x00", "Binary files a and b differ\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("a", "b", []byte(tt.a), []byte(tt.b)); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStat(t *testing.T) {
+	added, removed := Stat([]byte("1\n2\n3\n"), []byte("1\ntwo\n3\n4\n"))
+	if added != 2 || removed != 1 {
+		t.Errorf("Stat = +%d -%d, want +2 -1", added, removed)
+	}
+	if added, removed := Stat(nil, nil); added != 0 || removed != 0 {
+		t.Errorf("Stat(nil, nil) = +%d -%d", added, removed)
+	}
+}
+
+func TestDiffLinesIsShortest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 500; round++ {
+		a, b := randomLines(rng), randomLines(rng)
+		script := diffLines(a, b)
+
+		var gotA, gotB []string
+		kept := 0
+		for _, e := range script {
+			if e.kind != editInsert {
+				gotA = append(gotA, e.text)
+			}
+			if e.kind != editDelete {
+				gotB = append(gotB, e.text)
+			}
+			if e.kind == editEqual {
+				kept++
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("script for %q -> %q does not turn one into the other", a, b)
+		}
+		if want := lcsLength(a, b); kept != want {
+			t.Fatalf("script for %q -> %q keeps %d line(s), want %d", a, b, kept, want)
+		}
+	}
+}
+
+func TestDiffLinesLargeRewrite(t *testing.T) {
+	// Every line changes except the blank lines between paragraphs.
+	const n = 20000
+	var a, b strings.Builder
+	blank := 0
+	for i := 0; i < n; i++ {
+		if i%5 == 4 {
+			a.WriteString("\n")
+			b.WriteString("\n")
+			blank++
+			continue
+		}
+		fmt.Fprintf(&a, "old line %d\n", i)
+		fmt.Fprintf(&b, "new line %d\n", i)
+	}
+	added, removed := Stat([]byte(a.String()), []byte(b.String()))
+	if want := n - blank; added != want || removed != want {
+		t.Errorf("Stat = +%d -%d, want +%d -%d", added, removed, want, want)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	a := []string{"a", "b", "c", "d"}
+	b := []string{"b", "x", "c", "d", "e"}
+	var got []string
+	Match(a, b, func(i, j int) {
+		if a[i] != b[j] {
+			t.Errorf("paired %q with %q", a[i], b[j])
+		}
+		got = append(got, fmt.Sprintf("%d-%d", i, j))
+	})
+	if want := "1-0 2-2 3-3"; strings.Join(got, " ") != want {
+		t.Errorf("pairs = %v, want %s", got, want)
+	}
+}
+
+func randomLines(rng *rand.Rand) []string {
+	lines := make([]string, rng.Intn(12))
+	for i := range lines {
+		lines[i] = string(rune('a'+rng.Intn(4))) + "\n"
+	}
+	return lines
+}
+
+// lcsLength returns the length of the longest common subsequence of a and
+// b, the number of lines a shortest edit script keeps.
+func lcsLength(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
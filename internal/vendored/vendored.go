@@ -0,0 +1,91 @@
+// Package vendored manages the in-repo, content-addressed copy of locked
+// content under .agent-sync/vendor/.
+//
+// The vendor store uses the same objects/xx/<sha256> layout as the user
+// cache, so every read is hash-verified. An index records which locked
+// source and file each object belongs to.
+package vendored
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bianoble/agent-sync/internal/cache"
+	"github.com/bianoble/agent-sync/internal/sandbox"
+	"gopkg.in/yaml.v3"
+)
+
+// Dir is the vendor directory, relative to the project root.
+const Dir = ".agent-sync/vendor"
+
+// IndexFile is the name of the index file inside Dir.
+const IndexFile = "index.yaml"
+
+// Index records the locked files held in the vendor store.
+type Index struct {
+	Sources []IndexSource `yaml:"sources"`
+	Version int           `yaml:"version"`
+}
+
+// IndexSource lists the vendored files of a single locked source.
+type IndexSource struct {
+	Files  map[string]string `yaml:"files"` // relative path -> sha256
+	Name   string            `yaml:"name"`
+	Commit string            `yaml:"commit,omitempty"`
+}
+
+// Open returns the vendor store for a project, or nil if the project has
+// no vendor directory.
+func Open(projectRoot string) (*cache.Cache, error) {
+	dir := filepath.Join(projectRoot, Dir)
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening vendor directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("vendor path %s is not a directory", dir)
+	}
+	return cache.New(dir)
+}
+
+// Create opens the vendor store for a project, creating it if needed.
+// The directory must resolve inside the project root.
+func Create(projectRoot string) (*cache.Cache, error) {
+	resolved, err := sandbox.ValidatePath(projectRoot, Dir)
+	if err != nil {
+		return nil, fmt.Errorf("vendor directory: %w", err)
+	}
+	return cache.New(resolved)
+}
+
+// LoadIndex reads the vendor index. A missing index yields an empty one.
+func LoadIndex(projectRoot string) (*Index, error) {
+	path := filepath.Join(projectRoot, Dir, IndexFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Index{Version: 1}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading vendor index: %w", err)
+	}
+
+	var idx Index
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parsing vendor index %s: %w", path, err)
+	}
+	return &idx, nil
+}
+
+// SaveIndex writes the vendor index atomically.
+func SaveIndex(projectRoot string, idx *Index) error {
+	data, err := yaml.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("marshaling vendor index: %w", err)
+	}
+	return sandbox.SafeWrite(projectRoot, filepath.Join(Dir, IndexFile), data, 0644)
+}
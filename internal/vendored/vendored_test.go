@@ -0,0 +1,76 @@
+package vendored
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMissing(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if store != nil {
+		t.Error("expected nil store when the vendor directory does not exist")
+	}
+}
+
+func TestCreateAndOpen(t *testing.T) {
+	root := t.TempDir()
+
+	created, err := Create(root)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(root, Dir, "objects")); statErr != nil {
+		t.Fatalf("objects directory not created: %v", statErr)
+	}
+
+	opened, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if opened == nil || opened.Path() != filepath.Join(root, Dir) {
+		t.Errorf("Open returned %v, want store at %s", opened, created.Path())
+	}
+}
+
+func TestOpenNotADirectory(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".agent-sync"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, Dir), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(root); err == nil {
+		t.Error("expected error when the vendor path is a file")
+	}
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	root := t.TempDir()
+
+	idx, err := LoadIndex(root)
+	if err != nil {
+		t.Fatalf("LoadIndex (missing): %v", err)
+	}
+	if idx.Version != 1 || len(idx.Sources) != 0 {
+		t.Errorf("missing index = %+v, want empty version 1", idx)
+	}
+
+	idx.Sources = []IndexSource{{Name: "rules", Commit: "abc", Files: map[string]string{"a.md": "1111"}}}
+	if err := SaveIndex(root, idx); err != nil {
+		t.Fatalf("SaveIndex: %v", err)
+	}
+
+	got, err := LoadIndex(root)
+	if err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+	if len(got.Sources) != 1 || got.Sources[0].Files["a.md"] != "1111" {
+		t.Errorf("round trip = %+v", got)
+	}
+}
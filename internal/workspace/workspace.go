@@ -0,0 +1,285 @@
+// Package workspace finds the agent-sync projects of a monorepo, so that
+// commands can run across all of them.
+//
+// A workspace is a directory tree holding several projects, each with its
+// own agent-sync.yaml and lockfile. An agent-sync.workspace.yaml at its
+// root lists the member projects; without one, every project below the
+// current directory is a member.
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the workspace file.
+const FileName = "agent-sync.workspace.yaml"
+
+// ConfigFile and LockFile are the names of a member's config and lockfile.
+const (
+	ConfigFile = "agent-sync.yaml"
+	LockFile   = "agent-sync.lock"
+)
+
+// CurrentVersion is the workspace file version this agent-sync reads.
+const CurrentVersion = 1
+
+// File is the content of a workspace file.
+type File struct {
+	// Members are the member project directories, relative to the
+	// workspace root, as paths or globs. Empty means every project below
+	// the root.
+	Members []string `yaml:"members,omitempty"`
+
+	// Exclude drops members whose directory matches one of these paths or
+	// globs.
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	Version int `yaml:"version"`
+}
+
+// Workspace is a set of member projects.
+type Workspace struct {
+	// Path is the workspace file, or empty if the members were discovered
+	// without one.
+	Path string
+
+	// Root is the directory member names are relative to.
+	Root string
+
+	Members []Member
+}
+
+// Member is a project in a workspace.
+type Member struct {
+	// Name is the project directory relative to the workspace root, with
+	// forward slashes; "." for the root itself.
+	Name string
+
+	// Dir is the project directory.
+	Dir string
+}
+
+// ConfigPath returns the path of the member's agent-sync.yaml.
+func (m Member) ConfigPath() string {
+	return filepath.Join(m.Dir, ConfigFile)
+}
+
+// LockfilePath returns the path of the member's lockfile.
+func (m Member) LockfilePath() string {
+	return filepath.Join(m.Dir, LockFile)
+}
+
+// Open returns the workspace containing dir: the one described by the
+// nearest workspace file in dir or a parent directory, or, if there is
+// none, every project below dir.
+func Open(dir string) (*Workspace, error) {
+	path, err := Find(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return Discover(dir, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// Find returns the nearest workspace file in dir or a parent directory.
+// It returns an error wrapping os.ErrNotExist if there is none.
+func Find(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", dir, err)
+	}
+	for {
+		candidate := filepath.Join(abs, FileName)
+		if _, err := os.Stat(candidate); err == nil {
+			return relative(candidate), nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", fmt.Errorf("no %s in %s or a parent directory: %w", FileName, dir, os.ErrNotExist)
+		}
+		abs = parent
+	}
+}
+
+// Load reads a workspace file and finds its members.
+func Load(path string) (*Workspace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading workspace %s: %w", path, err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("workspace %s: %w", path, err)
+	}
+
+	root := filepath.Dir(path)
+	var ws *Workspace
+	if len(f.Members) == 0 {
+		ws, err = Discover(root, f.Exclude)
+	} else {
+		ws, err = match(root, f.Members, f.Exclude)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("workspace %s: %w", path, err)
+	}
+	ws.Path = path
+	return ws, nil
+}
+
+// Parse decodes and checks the content of a workspace file.
+func Parse(data []byte) (*File, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	switch {
+	case f.Version == 0:
+		return nil, errors.New("'version' is required — add 'version: 1'")
+	case f.Version > CurrentVersion:
+		return nil, fmt.Errorf("version %d is newer than this agent-sync supports (%d) — upgrade agent-sync", f.Version, CurrentVersion)
+	}
+	for _, pattern := range append(append([]string(nil), f.Members...), f.Exclude...) {
+		if err := checkPattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return &f, nil
+}
+
+// checkPattern rejects member paths that are malformed or leave the
+// workspace root.
+func checkPattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	clean := path.Clean(filepath.ToSlash(pattern))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("member '%s' must be a path inside the workspace root", pattern)
+	}
+	return nil
+}
+
+// match finds the members listed in a workspace file. A plain path must be
+// a project; a glob may match directories that are not.
+func match(root string, members, exclude []string) (*Workspace, error) {
+	ws := &Workspace{Root: root}
+	seen := make(map[string]bool)
+	for _, pattern := range members {
+		dirs, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, fmt.Errorf("member '%s': %w", pattern, err)
+		}
+		glob := strings.ContainsAny(pattern, "*?[")
+		if len(dirs) == 0 && !glob {
+			return nil, fmt.Errorf("member '%s': directory not found", pattern)
+		}
+		for _, dir := range dirs {
+			m, err := member(root, dir)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := os.Stat(m.ConfigPath()); err != nil {
+				if glob && errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("member '%s': %w", m.Name, err)
+			}
+			if !seen[m.Name] && !excluded(m.Name, exclude) {
+				seen[m.Name] = true
+				ws.Members = append(ws.Members, m)
+			}
+		}
+	}
+	sortMembers(ws.Members)
+	return ws, nil
+}
+
+// Discover returns a workspace of every project below root, except those
+// matching exclude. Hidden directories, such as .git and .agent-sync, and
+// node_modules are not searched.
+func Discover(root string, exclude []string) (*Workspace, error) {
+	ws := &Workspace{Root: root}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+			return filepath.SkipDir
+		}
+		m, err := member(root, p)
+		if err != nil {
+			return err
+		}
+		if excluded(m.Name, exclude) {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(m.ConfigPath()); err == nil {
+			ws.Members = append(ws.Members, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("discovering projects in %s: %w", root, err)
+	}
+	sortMembers(ws.Members)
+	return ws, nil
+}
+
+func member(root, dir string) (Member, error) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return Member{}, err
+	}
+	return Member{Name: filepath.ToSlash(rel), Dir: relative(dir)}, nil
+}
+
+func excluded(name string, exclude []string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := path.Match(path.Clean(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sortMembers(members []Member) {
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+}
+
+// relative returns path relative to the working directory if it is below
+// it, for display.
+func relative(p string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return rel
+}
@@ -0,0 +1,143 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func names(ws *Workspace) []string {
+	var out []string
+	for _, m := range ws.Members {
+		out = append(out, m.Name)
+	}
+	return out
+}
+
+var projects = map[string]string{
+	"services/api/agent-sync.yaml":      "version: 1\n",
+	"services/web/agent-sync.yaml":      "version: 1\n",
+	"services/legacy/agent-sync.yaml":   "version: 1\n",
+	"services/docs/README.md":           "not a project\n",
+	"tools/cli/agent-sync.yaml":         "version: 1\n",
+	"node_modules/x/agent-sync.yaml":    "version: 1\n",
+	".git/agent-sync.yaml":              "version: 1\n",
+	"services/api/.agent-sync/lock.txt": "",
+}
+
+func TestLoadMembers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, projects)
+	writeFiles(t, dir, map[string]string{FileName: `version: 1
+members:
+  - services/*
+  - tools/cli
+  - services/api
+exclude:
+  - services/legacy
+`})
+
+	ws, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []string{"services/api", "services/web", "tools/cli"}
+	if got := names(ws); !reflect.DeepEqual(got, want) {
+		t.Errorf("members = %v, want %v", got, want)
+	}
+	if got := ws.Members[0].ConfigPath(); got != filepath.Join(dir, "services", "api", ConfigFile) {
+		t.Errorf("ConfigPath = %q", got)
+	}
+}
+
+func TestLoadDiscoversWithoutMembers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, projects)
+	writeFiles(t, dir, map[string]string{FileName: "version: 1\nexclude: [services/legacy]\n"})
+
+	ws, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []string{"services/api", "services/web", "tools/cli"}
+	if got := names(ws); !reflect.DeepEqual(got, want) {
+		t.Errorf("members = %v, want %v", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"missing version", "members: [services/api]\n", "'version' is required"},
+		{"newer version", "version: 2\n", "version 2 is newer"},
+		{"unknown field", "version: 1\nmember: [x]\n", "field member not found"},
+		{"outside root", "version: 1\nmembers: [../other]\n", "must be a path inside the workspace root"},
+		{"missing member", "version: 1\nmembers: [services/nope]\n", "member 'services/nope': directory not found"},
+		{"not a project", "version: 1\nmembers: [services/docs]\n", "member 'services/docs'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, projects)
+			writeFiles(t, dir, map[string]string{FileName: tt.content})
+			_, err := Load(filepath.Join(dir, FileName))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindWalksUp(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, projects)
+	writeFiles(t, dir, map[string]string{FileName: "version: 1\n"})
+
+	got, err := Find(filepath.Join(dir, "services", "api"))
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if abs, _ := filepath.Abs(got); abs != filepath.Join(dir, FileName) {
+		t.Errorf("Find = %q, want %q", got, filepath.Join(dir, FileName))
+	}
+
+	if _, err := Find(t.TempDir()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Find without a workspace file: got %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestOpenDiscoversWithoutFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, projects)
+
+	ws, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if ws.Path != "" {
+		t.Errorf("Path = %q, want none", ws.Path)
+	}
+	want := []string{"services/api", "services/legacy", "services/web", "tools/cli"}
+	if got := names(ws); !reflect.DeepEqual(got, want) {
+		t.Errorf("members = %v, want %v", got, want)
+	}
+}
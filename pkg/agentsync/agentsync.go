@@ -33,18 +33,26 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/bianoble/agent-sync/internal/cache"
 	"github.com/bianoble/agent-sync/internal/config"
 	"github.com/bianoble/agent-sync/internal/engine"
+	"github.com/bianoble/agent-sync/internal/flock"
 	"github.com/bianoble/agent-sync/internal/lock"
+	"github.com/bianoble/agent-sync/internal/signing"
 	"github.com/bianoble/agent-sync/internal/source"
 	"github.com/bianoble/agent-sync/internal/target"
+	"github.com/bianoble/agent-sync/internal/vendored"
 )
 
 // SyncOptions configures a sync operation.
 type SyncOptions struct {
 	DryRun bool
+
+	// Atomic writes nothing unless every source succeeds. When false, the
+	// config's sync.atomic setting applies.
+	Atomic bool
 }
 
 // PruneOptions configures a prune operation.
@@ -52,6 +60,13 @@ type PruneOptions struct {
 	DryRun bool
 }
 
+// RecoverOptions configures a recover operation.
+type RecoverOptions struct {
+	// Rollback restores files to their state before the interrupted
+	// operation instead of completing it.
+	Rollback bool
+}
+
 // Syncer synchronizes files to targets using the lockfile as the source of truth.
 // See spec Section 10.1.
 type Syncer interface {
@@ -126,6 +141,23 @@ type Options struct {
 	// NoInherit disables hierarchical config resolution.
 	// When true, only ConfigPath is loaded (no system/user merging).
 	NoInherit bool
+
+	// Profile selects a config profile for Sync, Check, and Verify. Update
+	// and Prune always cover the sources of every profile.
+	Profile string
+
+	// AllowCommands lets command variable providers in the config run, and
+	// file providers in the project config read outside the project root.
+	AllowCommands bool
+
+	// Offline forbids network access. Git and URL sources are served only
+	// from the cache; anything missing is reported instead of fetched.
+	Offline bool
+
+	// LockWait is how long mutating operations (Sync, Prune, Update) wait
+	// for another agent-sync operation on the same project to release the
+	// project lock. Zero fails immediately with a ProjectLockedError.
+	LockWait time.Duration
 }
 
 // Client is the main entry point for the agent-sync library.
@@ -138,7 +170,11 @@ type Client struct {
 	lockfilePath     string
 	systemConfigPath string
 	userConfigPath   string
+	profile          string
 	noInherit        bool
+	lockWait         time.Duration
+	offline          bool
+	allowCommands    bool
 }
 
 // New creates a new agent-sync Client.
@@ -169,8 +205,13 @@ func New(opts Options) (*Client, error) {
 	}
 
 	reg := source.NewRegistry()
-	reg.Register("git", &source.GitResolver{})
-	reg.Register("url", &source.URLResolver{})
+	if opts.Offline {
+		reg.Register("git", source.OfflineResolver{})
+		reg.Register("url", source.OfflineResolver{})
+	} else {
+		reg.Register("git", &source.GitResolver{})
+		reg.Register("url", &source.URLResolver{})
+	}
 	reg.Register("local", &source.LocalResolver{})
 
 	return &Client{
@@ -179,23 +220,97 @@ func New(opts Options) (*Client, error) {
 		lockfilePath:     opts.LockfilePath,
 		systemConfigPath: opts.SystemConfigPath,
 		userConfigPath:   opts.UserConfigPath,
+		profile:          opts.Profile,
 		noInherit:        opts.NoInherit,
+		offline:          opts.Offline,
+		allowCommands:    opts.AllowCommands,
+		lockWait:         opts.LockWait,
 		registry:         reg,
 		cache:            c,
 	}, nil
 }
 
+// projectCache returns the cache with the configured remote cache attached,
+// unless the client is offline.
+func (c *Client) projectCache(cfg *config.Config) (*cache.Cache, error) {
+	if cfg.Cache.RemoteCache == "" || c.offline {
+		return c.cache, nil
+	}
+	remote, err := cache.NewRemote(cfg.Cache.RemoteCache)
+	if err != nil {
+		return nil, err
+	}
+	scoped, err := cache.New(c.cache.Path())
+	if err != nil {
+		return nil, fmt.Errorf("initializing cache: %w", err)
+	}
+	scoped.SetRemote(remote)
+	return scoped, nil
+}
+
+// lock takes the project operation lock for a mutating operation and rolls
+// forward any operation that a previous run left unfinished.
+func (c *Client) lock(ctx context.Context, operation string) (*flock.Lock, error) {
+	l, err := engine.LockProject(ctx, c.projectRoot, operation, c.lockWait)
+	if err != nil {
+		return nil, err
+	}
+	eng := &engine.RecoverEngine{ProjectRoot: c.projectRoot}
+	if _, err := eng.Recover(ctx, engine.RecoverOptions{}); err != nil {
+		_ = l.Unlock()
+		return nil, fmt.Errorf("recovering interrupted operation: %w", err)
+	}
+	return l, nil
+}
+
+// Recover finishes, or with opts.Rollback undoes, an operation that was
+// interrupted mid-write. It returns nil if there is nothing to recover.
+func (c *Client) Recover(ctx context.Context, opts RecoverOptions) (*RecoverResult, error) {
+	l, err := engine.LockProject(ctx, c.projectRoot, "recover", c.lockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = l.Unlock() }()
+
+	eng := &engine.RecoverEngine{ProjectRoot: c.projectRoot}
+	return eng.Recover(ctx, engine.RecoverOptions{Rollback: opts.Rollback})
+}
+
+// enforceSigning refuses a lockfile without a valid signature from an
+// allowed key when the config requires one.
+func (c *Client) enforceSigning(cfg *config.Config, lf *lock.Lockfile) error {
+	if !cfg.Signing.Require {
+		return nil
+	}
+	if _, err := signing.VerifyLockfile(c.lockfilePath, lf, cfg.Signing); err != nil {
+		return fmt.Errorf("%s: %w", c.lockfilePath, err)
+	}
+	return nil
+}
+
+// loadConfig loads the config with the client's profile applied.
 func (c *Client) loadConfig() (*config.Config, error) {
+	cfg, err := c.loadLockConfig()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.WithProfile(c.profile)
+}
+
+// loadLockConfig loads the config with the sources of every profile, for
+// operations that write the lockfile or remove what it no longer covers.
+func (c *Client) loadLockConfig() (*config.Config, error) {
 	result, err := config.LoadHierarchical(config.HierarchicalOptions{
 		ProjectPath:      c.configPath,
 		SystemConfigPath: c.systemConfigPath,
 		UserConfigPath:   c.userConfigPath,
 		NoInherit:        c.noInherit,
+		AllowCommands:    c.allowCommands,
 	})
 	if err != nil {
 		return nil, err
 	}
-	return result.Config, nil
+	return result.Config.Union(), nil
 }
 
 func (c *Client) loadLockfile() (*lock.Lockfile, error) {
@@ -215,6 +330,14 @@ func (c *Client) toolMap(cfg *config.Config) *target.ToolMap {
 
 // Sync synchronizes files to targets using the lockfile as the source of truth.
 func (c *Client) Sync(ctx context.Context, opts SyncOptions) (*SyncResult, error) {
+	if !opts.DryRun {
+		l, err := c.lock(ctx, "sync")
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = l.Unlock() }()
+	}
+
 	cfg, err := c.loadConfig()
 	if err != nil {
 		return nil, err
@@ -223,15 +346,32 @@ func (c *Client) Sync(ctx context.Context, opts SyncOptions) (*SyncResult, error
 	if err != nil {
 		return nil, err
 	}
+	if err := c.enforceSigning(cfg, lf); err != nil {
+		return nil, err
+	}
+
+	vendorStore, err := vendored.Open(c.projectRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheStore, err := c.projectCache(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	eng := &engine.SyncEngine{
 		Registry:    c.registry,
-		Cache:       c.cache,
+		Cache:       cacheStore,
+		Vendor:      vendorStore,
 		ToolMap:     c.toolMap(cfg),
 		ProjectRoot: c.projectRoot,
 	}
 
-	return eng.Sync(ctx, *lf, *cfg, engine.SyncOptions{DryRun: opts.DryRun})
+	return eng.Sync(ctx, *lf, *cfg, engine.SyncOptions{
+		DryRun: opts.DryRun,
+		Atomic: opts.Atomic || cfg.Sync.IsAtomic(),
+	})
 }
 
 // Check verifies that target files match the lockfile.
@@ -244,6 +384,9 @@ func (c *Client) Check(ctx context.Context) (*CheckResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := c.enforceSigning(cfg, lf); err != nil {
+		return nil, err
+	}
 
 	eng := &engine.CheckEngine{
 		ToolMap:     c.toolMap(cfg),
@@ -274,7 +417,15 @@ func (c *Client) Verify(ctx context.Context, sourceNames []string) (*VerifyResul
 
 // Prune removes files no longer referenced in the configuration.
 func (c *Client) Prune(ctx context.Context, opts PruneOptions) (*PruneResult, error) {
-	cfg, err := c.loadConfig()
+	if !opts.DryRun {
+		l, err := c.lock(ctx, "prune")
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = l.Unlock() }()
+	}
+
+	cfg, err := c.loadLockConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -293,7 +444,15 @@ func (c *Client) Prune(ctx context.Context, opts PruneOptions) (*PruneResult, er
 
 // Update resolves sources against upstream and updates the lockfile.
 func (c *Client) Update(ctx context.Context, opts UpdateOptions) (*UpdateResult, error) {
-	cfg, err := c.loadConfig()
+	if !opts.DryRun {
+		l, err := c.lock(ctx, "update")
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = l.Unlock() }()
+	}
+
+	cfg, err := c.loadLockConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -302,9 +461,14 @@ func (c *Client) Update(ctx context.Context, opts UpdateOptions) (*UpdateResult,
 		return nil, err
 	}
 
+	cacheStore, err := c.projectCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	eng := &engine.UpdateEngine{
 		Registry:    c.registry,
-		Cache:       c.cache,
+		Cache:       cacheStore,
 		ProjectRoot: c.projectRoot,
 	}
 
@@ -2,10 +2,12 @@ package agentsync
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/bianoble/agent-sync/internal/engine"
 	"github.com/bianoble/agent-sync/internal/lock"
 )
 
@@ -415,3 +417,62 @@ func TestToolMap(t *testing.T) {
 		t.Fatal("expected non-nil toolMap")
 	}
 }
+
+func TestClientUpdateRespectsProjectLock(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := writeConfig(t, dir)
+	setupRulesDir(t, dir)
+	client := newTestClient(t, dir, cfgPath)
+
+	held, err := engine.LockProject(context.Background(), dir, "sync", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Update(context.Background(), UpdateOptions{})
+	var locked *ProjectLockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("Update error = %v, want ProjectLockedError", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "agent-sync.lock")); !os.IsNotExist(statErr) {
+		t.Error("lockfile must not be written while another operation holds the lock")
+	}
+
+	// Dry runs do not write and so do not need the lock.
+	if _, err := client.Update(context.Background(), UpdateOptions{DryRun: true}); err != nil {
+		t.Fatalf("dry-run Update: %v", err)
+	}
+
+	if err := held.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Update(context.Background(), UpdateOptions{}); err != nil {
+		t.Fatalf("Update after unlock: %v", err)
+	}
+}
+
+func TestClientValidationErrorPositions(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "agent-sync.yaml")
+	content := `version: 1
+sources:
+  - name: rules
+    type: local
+targets:
+  - source: rules
+    destination: .out/
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := newTestClient(t, dir, cfgPath).Update(context.Background(), UpdateOptions{})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 {
+		t.Fatalf("err = %v, want one validation error", err)
+	}
+	fe := verr.Errors[0]
+	if fe.Path != "sources[0]" || fe.Origin.File != cfgPath || fe.Origin.Line != 3 {
+		t.Errorf("error = %+v, want sources[0] at line 3 of %s", fe, cfgPath)
+	}
+}
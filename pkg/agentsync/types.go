@@ -1,6 +1,9 @@
 package agentsync
 
-import "github.com/bianoble/agent-sync/internal/engine"
+import (
+	"github.com/bianoble/agent-sync/internal/config"
+	"github.com/bianoble/agent-sync/internal/engine"
+)
 
 // Type aliases re-export engine result types as the public API.
 // Users import "github.com/bianoble/agent-sync/pkg/agentsync" and use
@@ -14,3 +17,13 @@ type SyncResult = engine.SyncResult
 type CheckResult = engine.CheckResult
 type VerifyResult = engine.VerifyResult
 type PruneResult = engine.PruneResult
+type ProjectLockedError = engine.ProjectLockedError
+type RecoverResult = engine.RecoverResult
+
+// Config errors. A config that fails to load or validate returns a
+// *ValidationError; each of its Errors names the field path and, when
+// known, the file position of one failure.
+
+type ValidationError = config.ValidationError
+type FieldError = config.FieldError
+type Origin = config.Origin